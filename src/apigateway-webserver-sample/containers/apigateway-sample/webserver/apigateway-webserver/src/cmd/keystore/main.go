@@ -0,0 +1,63 @@
+// Writes the encrypted keystore read by the keystore credential provider.
+//
+// Usage:
+//
+//	keystore -generate-key
+//	CCF_KEYSTORE_KEY=... CCF_CLIENT_ID=... CCF_CLIENT_SECRET=... keystore -out credentials.keystore
+//
+// The key is read from CCF_KEYSTORE_KEY or CCF_KEYSTORE_KEY_FILE, like the webserver does.
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"apigateway-webserver/src/pkg/entities/appcenter"
+)
+
+func main() {
+	generateKey := flag.Bool("generate-key", false, "print a new random base64 key and exit")
+	out := flag.String("out", "", "path of the keystore file to write")
+	flag.Parse()
+
+	if *generateKey {
+		key, err := appcenter.NewKeystoreKey()
+		if err != nil {
+			log.Fatal("Generating the key: ", err)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(key))
+		return
+	}
+
+	if *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	key, err := appcenter.ReadKeystoreKey()
+	if err != nil {
+		log.Fatal("Reading the key: ", err)
+	}
+
+	credentials, err := appcenter.NewEnvCredentialProvider().ClientCredentials()
+	if err != nil {
+		log.Fatal("Reading the client credentials: ", err)
+	}
+
+	content, err := appcenter.SealKeystore(key, credentials)
+	if err != nil {
+		log.Fatal("Encrypting the keystore: ", err)
+	}
+
+	// Written next to the target and renamed, so the webserver never reads a half written keystore
+	tmp := *out + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		log.Fatal("Writing the keystore: ", err)
+	}
+	if err := os.Rename(tmp, *out); err != nil {
+		log.Fatal("Writing the keystore: ", err)
+	}
+}
@@ -1,35 +1,45 @@
 package main
 
 import (
-	"log"
-	"net/http"
-	"strconv"
+	"context"
+	"log/slog"
+	"os"
 
-	"apigateway-webserver/src/pkg/handlers"
+	"apigateway-webserver/src/pkg/logging"
+	"apigateway-webserver/src/pkg/server"
+	"apigateway-webserver/src/pkg/tracing"
 )
 
-// Handlers
-var homeHandler *handlers.HomeHandler
-var loginHandler *handlers.LoginHandler
-var viewHandler *handlers.ViewHandler
-var eventHandler *handlers.EventHandler
-
 func main() {
-	// Initialize handlers
-	homeHandler = handlers.NewHomeHandler()
-	loginHandler = handlers.NewLoginHandler()
-	http.HandleFunc("/", homeHandler.Handle)
-	http.HandleFunc("/_login/", loginHandler.Handle)
-
-	viewHandler = handlers.NewViewHandler()
-	eventHandler = handlers.NewEventHandler()
-	http.HandleFunc("/view_events/", viewHandler.Handle)
-	http.HandleFunc("/view_events/_events_start/", eventHandler.StartSubscriptionHandle)
-	http.HandleFunc("/view_events/_events_request/", eventHandler.RequestEventsHandle)
-
-	err := http.ListenAndServe(":"+strconv.Itoa(8080), nil)
+	if err := logging.Setup(); err != nil {
+		fatal("Error while configuring the logs", err)
+	}
+
+	config, err := server.LoadConfig(os.Args[1:])
+	if err != nil {
+		fatal("Error while reading the webserver configuration", err)
+	}
+
+	router, err := server.NewRouter(config)
+	if err != nil {
+		fatal("Error while creating the webserver routes", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background())
 	if err != nil {
-		log.Fatal("Error while starting the webserver: ", err)
-		return
+		fatal("Error while configuring the traces", err)
+	}
+
+	srv := server.New(config, router)
+	if err := server.Run(config, srv); err != nil {
+		fatal("Error while running the webserver", err)
 	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Warn("Sending the last traces", "error", err)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
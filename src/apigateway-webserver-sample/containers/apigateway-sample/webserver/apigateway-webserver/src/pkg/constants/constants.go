@@ -9,7 +9,101 @@ const (
 	IdpWellKnownOpenIdConfig = "/idp/.well-known/openid-configuration"
 	EnabledCameras           = "/api/rest/v1/cameras"
 	AnalyticEventTypes       = "/api/rest/v1/analyticsEvents"
+	UserDefinedEvents        = "/api/rest/v1/userDefinedEvents"
+	Events                   = "/api/rest/v1/events"
+	Alarms                   = "/api/rest/v1/alarms"
+	Bookmarks                = "/api/rest/v1/bookmarks"
+
+	// Configuration API, every resource type is a collection under this path (e.g. /api/rest/v1/hardware)
+	ConfigApi         = "/api/rest/v1/"
+	ConfigTasks       = "tasks"
+	ConfigTasksParam  = "tasks"
+	ConfigTaskParam   = "task"
+	ConfigNoDataParam = "noData"
+
+	// Configuration API resource types of the recording servers hierarchy
+	RecordingServersResource = "recordingServers"
+	HardwareResource         = "hardware"
+	CamerasResource          = "cameras"
+	MicrophonesResource      = "microphones"
+	InputEventsResource      = "inputEvents"
+	OutputsResource          = "outputs"
+	CameraGroupsResource     = "cameraGroups"
+	PtzPresetsResource       = "ptzPresets"
+	AnalyticEventsResource   = "analyticsEvents"
+
+	// Configuration API resource type of the VMS roles, and child item type of their members
+	RolesResource     = "roles"
+	RoleUsersResource = "users"
+
+	// Task moving a PTZ camera to a preset position
+	PtzPresetActivateTask = "Activate"
+
+	// Bookmarks task searching the bookmarks of a set of cameras within a time range
+	BookmarksSearchTask = "searchFromTo"
+
+	// Environment variable with the path of the configuration cache file, and the path used when it is not set
+	ConfigCachePathEnv     = "CONFIG_CACHE_PATH"
+	ConfigCacheDefaultPath = "config-cache.db"
+
+	// Scope of the tokens giving access to the management server
+	ManagementServerScope = "managementserver"
+
+	// Environment variable with the path of the token profiles file, see vms.TokenProfiles
+	TokenProfilesPathEnv = "TOKEN_PROFILES_PATH"
+
+	// Environment variable with the path of the role mappings file, see vms.RoleMappings
+	RoleMappingsPathEnv = "ROLE_MAPPINGS_PATH"
+
+	// Environment variables selecting where the client credentials are read from: env, file or keystore.
+	// Without CREDENTIALS_PROVIDER, the provider is chosen from the variables that are set
+	CredentialsProviderEnv = "CREDENTIALS_PROVIDER"
+	ClientIdEnv            = "CCF_CLIENT_ID"
+	ClientSecretEnv        = "CCF_CLIENT_SECRET"
+	// Paths of the files holding the client id and secret, e.g. mounted from a Kubernetes secret
+	ClientIdFileEnv     = "CCF_CLIENT_ID_FILE"
+	ClientSecretFileEnv = "CCF_CLIENT_SECRET_FILE"
+	// Path of the encrypted keystore holding the client id and secret, and the base64 key decrypting it or the path of a file holding that key
+	KeystorePathEnv    = "CCF_KEYSTORE_PATH"
+	KeystoreKeyEnv     = "CCF_KEYSTORE_KEY"
+	KeystoreKeyFileEnv = "CCF_KEYSTORE_KEY_FILE"
+
+	// Environment variables configuring the HTTP server, see server.LoadConfig
+	ServerConfigPathEnv  = "SERVER_CONFIG_PATH"
+	ListenAddrEnv        = "LISTEN_ADDR"
+	BasePathEnv          = "BASE_PATH"
+	ReadTimeoutEnv       = "READ_TIMEOUT"
+	ReadHeaderTimeoutEnv = "READ_HEADER_TIMEOUT"
+	WriteTimeoutEnv      = "WRITE_TIMEOUT"
+	IdleTimeoutEnv       = "IDLE_TIMEOUT"
+	ShutdownTimeoutEnv   = "SHUTDOWN_TIMEOUT"
+	ReadinessServerEnv   = "READINESS_SERVER"
+	ReadinessTimeoutEnv  = "READINESS_TIMEOUT"
+	ReadinessCacheTTLEnv = "READINESS_CACHE_TTL"
+	DiscoveryTimeoutEnv  = "DISCOVERY_TIMEOUT"
+	TokenTimeoutEnv      = "TOKEN_TIMEOUT"
+	ListTimeoutEnv       = "LIST_TIMEOUT"
+	TLSCertFileEnv       = "TLS_CERT_FILE"
+	TLSKeyFileEnv        = "TLS_KEY_FILE"
+	HTTPRedirectAddrEnv  = "HTTP_REDIRECT_ADDR"
+	HSTSMaxAgeEnv        = "HSTS_MAX_AGE"
+	SecureHeadersEnv     = "SECURE_HEADERS"
+
+	// Environment variables configuring the logs, see logging.Setup
+	LogLevelEnv  = "LOG_LEVEL"
+	LogLevelsEnv = "LOG_LEVELS"
+
+	// Environment variables of OpenTelemetry selecting the trace exporter, see tracing.Setup
+	OtelTracesExporterEnv             = "OTEL_TRACES_EXPORTER"
+	OtelExporterOtlpEndpointEnv       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	OtelExporterOtlpTracesEndpointEnv = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+
+	// Cookie naming the session of the browser or script, set at login and checked by the routes requiring a role
+	SessionCookieName = "apigateway_session"
 
 	// WebSocket endpoints
 	EventsWebsocket = "/api/ws/events/v1/"
+
+	// WebSocket subscription resource types
+	AlarmsResourceType = "alarms"
 )
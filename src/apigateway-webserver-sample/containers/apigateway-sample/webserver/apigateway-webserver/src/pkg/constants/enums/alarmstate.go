@@ -0,0 +1,48 @@
+package enums
+
+import "fmt"
+
+// Alarm states as defined by the VMS. The values are the state levels used by the alarms API.
+type AlarmState int
+
+const (
+	AlarmNew        AlarmState = 1
+	AlarmInProgress AlarmState = 4
+	AlarmOnHold     AlarmState = 9
+	AlarmClosed     AlarmState = 11
+)
+
+var (
+	alarmStateMap = map[string]AlarmState{
+		"New":        AlarmNew,
+		"InProgress": AlarmInProgress,
+		"OnHold":     AlarmOnHold,
+		"Closed":     AlarmClosed,
+	}
+)
+
+func (a AlarmState) String() string {
+	for name, state := range alarmStateMap {
+		if state == a {
+			return name
+		}
+	}
+	return fmt.Sprintf("AlarmState(%d)", int(a))
+}
+
+func ParseAlarmState(str string) (AlarmState, error) {
+	a, ok := alarmStateMap[str]
+	if !ok {
+		return 0, fmt.Errorf("invalid AlarmState: %s", str)
+	}
+	return a, nil
+}
+
+func GetAlarmStates() []string {
+	return []string{
+		AlarmNew.String(),
+		AlarmInProgress.String(),
+		AlarmOnHold.String(),
+		AlarmClosed.String(),
+	}
+}
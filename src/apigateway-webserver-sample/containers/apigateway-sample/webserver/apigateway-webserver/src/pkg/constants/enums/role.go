@@ -0,0 +1,53 @@
+package enums
+
+import "fmt"
+
+// Roles of the users of the webserver, each one having the rights of the roles below it.
+type Role int
+
+const (
+	// Watches the events and alarms, and browses the configuration
+	RoleViewer Role = iota + 1
+	// Also triggers events, camera actions and outputs, creates bookmarks and handles alarms
+	RoleOperator
+	// Also changes the configuration of the VMS and manages the sessions
+	RoleAdmin
+)
+
+var (
+	roleMap = map[string]Role{
+		"viewer":   RoleViewer,
+		"operator": RoleOperator,
+		"admin":    RoleAdmin,
+	}
+)
+
+func (r Role) String() string {
+	for name, role := range roleMap {
+		if role == r {
+			return name
+		}
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+func ParseRole(str string) (Role, error) {
+	r, ok := roleMap[str]
+	if !ok {
+		return 0, fmt.Errorf("invalid Role: %s", str)
+	}
+	return r, nil
+}
+
+func GetRoles() []string {
+	return []string{
+		RoleViewer.String(),
+		RoleOperator.String(),
+		RoleAdmin.String(),
+	}
+}
+
+// Tells whether the role has the rights of the required role.
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
@@ -0,0 +1,14 @@
+package api
+
+import "encoding/json"
+
+// Triggers an event, e.g. a user-defined event.
+type EventTriggerRequest struct {
+	EventTypeId string `json:"eventTypeId"`
+	// Source of the event, the event has no source when empty
+	CameraId string `json:"cameraId,omitempty"`
+	// Additional event data
+	Data json.RawMessage `json:"data,omitempty"`
+	// Site of the event type and camera, the first site of the session when empty
+	Site string `json:"site,omitempty"`
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Media type of the error responses of the API.
+const ProblemContentType = "application/problem+json"
+
+// Error response of the API, following the problem details of RFC 7807.
+// Problems without a more specific type are about:blank, their title is the text of the status.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Path of the request that failed
+	Instance string `json:"instance,omitempty"`
+	// Id of the request in the logs of the webserver, also sent in the X-Request-ID header
+	RequestID string `json:"requestId,omitempty"`
+}
+
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal problem: %w", err)
+	}
+	return string(jsonData), nil
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"strings"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// Logs a user in to a management server. Fields whose JSON name has omitempty are optional.
+type LoginRequest struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Host and port of the management server
+	Hostname string `json:"hostname"`
+	Secure   bool   `json:"secure,omitempty"`
+	// LoginForm or ClientCredentialsFlow
+	CredentialsFlowType string `json:"credentialsFlowType,omitempty"`
+	// Adds the server to the current session of the user instead of replacing it
+	AddSite bool `json:"addSite,omitempty"`
+}
+
+// Sites a user is logged in to.
+type Session struct {
+	Username string `json:"username"`
+	// Role of the user in the session, the lowest role of its sites
+	Role  string         `json:"role"`
+	Sites []*SessionSite `json:"sites"`
+}
+
+func NewSession(username string, role enums.Role) *Session {
+	return &Session{
+		Username: username,
+		Role:     role.String(),
+		Sites:    []*SessionSite{},
+	}
+}
+
+func (s *Session) Add(site *SessionSite) {
+	if site != nil {
+		s.Sites = append(s.Sites, site)
+	}
+}
+
+// Management server of a session, with the role of the user on it, the features it supports and the scopes of its token.
+type SessionSite struct {
+	Site            string            `json:"site"`
+	Role            string            `json:"role"`
+	Capabilities    *vms.Capabilities `json:"capabilities"`
+	RequestedScopes []string          `json:"requestedScopes"`
+	GrantedScopes   []string          `json:"grantedScopes"`
+}
+
+func NewSessionSite(name string, role enums.Role, server *vms.Server, token vms.Token) *SessionSite {
+	site := &SessionSite{
+		Site:            name,
+		Role:            role.String(),
+		Capabilities:    server.Capabilities(),
+		RequestedScopes: []string{},
+		GrantedScopes:   strings.Fields(token.GetSchema().Scope),
+	}
+	if profile := server.TokenProfile(); profile != nil {
+		site.RequestedScopes = profile.Scopes
+	}
+	return site
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"apigateway-webserver/src/pkg/entities/events"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// Subscribes to the events of a type coming from a camera or from every camera of a group.
+type SubscriptionRequest struct {
+	// Camera sending the events, cameraGroupId is used instead to subscribe to all cameras of a group
+	CameraId      string `json:"cameraId,omitempty"`
+	CameraGroupId string `json:"cameraGroupId,omitempty"`
+	EventTypeId   string `json:"eventTypeId"`
+	// Site of the camera or group, the first site of the session when empty
+	Site string `json:"site,omitempty"`
+	// Site the event type was selected from, when it isn't the site of the camera the event type is looked up by name
+	EventTypeSite string `json:"eventTypeSite,omitempty"`
+}
+
+// Events session started on a site, replacing the previous subscription of the site.
+type Subscription struct {
+	Site    string                    `json:"site"`
+	Session *events.WsCommandResponse `json:"session"`
+	// Cameras of the group, when subscribed to a camera group
+	GroupCameras []*vms.Camera `json:"groupCameras,omitempty"`
+}
@@ -0,0 +1,235 @@
+package appcenter
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"apigateway-webserver/src/pkg/constants"
+)
+
+// Interval between two reads of the credentials of the providers watching files. Read when a provider is created.
+var credentialsPollInterval = 10 * time.Second
+
+// Client id and secret of the OAuth 2 Client Credentials Flow.
+type ClientCredentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+}
+
+// Logs the client id without the secret.
+func (cc ClientCredentials) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("clientId", cc.ClientID))
+}
+
+// Ensures every source of client credentials can be read and can tell when the credentials were rotated.
+type CredentialProvider interface {
+	// Returns the name of the provider, e.g. "file"
+	Name() string
+
+	// Returns the current client credentials.
+	ClientCredentials() (ClientCredentials, error)
+
+	// Registers a function called with the previous and the new credentials every time they change.
+	// Providers whose credentials can't change never call it.
+	OnChange(onChange func(previous, current ClientCredentials))
+}
+
+var (
+	credentialProviderInstance CredentialProvider
+	// Functions registered with OnCredentialsChange, given to the provider once it is created
+	credentialListeners  []func(previous, current ClientCredentials)
+	credentialProviderMu sync.Mutex
+)
+
+// Returns the provider shared by the whole process. It is selected by the CREDENTIALS_PROVIDER environment variable,
+// or when it is not set, from the variables that are set: the keystore first, then the files, then the environment.
+// A provider that can't be created, e.g. a keystore not mounted yet, is created again on the next call.
+func GetCredentialProvider() (CredentialProvider, error) {
+	credentialProviderMu.Lock()
+	defer credentialProviderMu.Unlock()
+	if credentialProviderInstance != nil {
+		return credentialProviderInstance, nil
+	}
+
+	provider, err := newCredentialProvider()
+	if err != nil {
+		return nil, err
+	}
+	for _, onChange := range credentialListeners {
+		provider.OnChange(onChange)
+	}
+	logger.Info("Client credentials provider selected", "provider", provider.Name())
+	credentialProviderInstance = provider
+	return provider, nil
+}
+
+// Registers a function called every time the client credentials of the process change, see CredentialProvider.OnChange.
+// It is given to the provider as soon as it is created, whether it already is or not.
+func OnCredentialsChange(onChange func(previous, current ClientCredentials)) {
+	credentialProviderMu.Lock()
+	defer credentialProviderMu.Unlock()
+	credentialListeners = append(credentialListeners, onChange)
+	if credentialProviderInstance != nil {
+		credentialProviderInstance.OnChange(onChange)
+	}
+}
+
+func newCredentialProvider() (CredentialProvider, error) {
+	name := os.Getenv(constants.CredentialsProviderEnv)
+	if name == "" {
+		switch {
+		case os.Getenv(constants.KeystorePathEnv) != "":
+			name = "keystore"
+		case os.Getenv(constants.ClientSecretFileEnv) != "":
+			name = "file"
+		default:
+			name = "env"
+		}
+	}
+
+	switch name {
+	case "env":
+		return NewEnvCredentialProvider(), nil
+	case "file":
+		return NewFileCredentialProvider(os.Getenv(constants.ClientIdFileEnv), os.Getenv(constants.ClientSecretFileEnv))
+	case "keystore":
+		return NewKeystoreCredentialProvider(os.Getenv(constants.KeystorePathEnv))
+	default:
+		return nil, fmt.Errorf("unknown credentials provider %q, expected env, file or keystore", name)
+	}
+}
+
+// Reads the credentials from the CCF_CLIENT_ID and CCF_CLIENT_SECRET environment variables, which can't change while running.
+type envCredentialProvider struct{}
+
+func NewEnvCredentialProvider() CredentialProvider {
+	return &envCredentialProvider{}
+}
+
+func (ecp *envCredentialProvider) Name() string {
+	return "env"
+}
+
+func (ecp *envCredentialProvider) ClientCredentials() (ClientCredentials, error) {
+	clientID, available := os.LookupEnv(constants.ClientIdEnv)
+	if !available {
+		return ClientCredentials{}, fmt.Errorf("environment variable %s not set", constants.ClientIdEnv)
+	}
+
+	clientSecret, available := os.LookupEnv(constants.ClientSecretEnv)
+	if !available {
+		return ClientCredentials{}, fmt.Errorf("environment variable %s not set", constants.ClientSecretEnv)
+	}
+	return ClientCredentials{ClientID: clientID, ClientSecret: clientSecret}, nil
+}
+
+func (ecp *envCredentialProvider) OnChange(onChange func(previous, current ClientCredentials)) {}
+
+// Reads the credentials with the given function and reads them again periodically, to notice when they were rotated.
+// Files are polled rather than watched for events: mounted secrets are replaced through symbolic links, which file events don't follow reliably.
+type pollingCredentialProvider struct {
+	name     string
+	read     func() (ClientCredentials, error)
+	interval time.Duration
+
+	mu        sync.Mutex
+	current   ClientCredentials
+	listeners []func(previous, current ClientCredentials)
+}
+
+// Reads the credentials once, failing when they can't be read, then keeps reading them in the background.
+func newPollingCredentialProvider(name string, read func() (ClientCredentials, error)) (CredentialProvider, error) {
+	current, err := read()
+	if err != nil {
+		return nil, err
+	}
+
+	pcp := &pollingCredentialProvider{
+		name:     name,
+		read:     read,
+		interval: credentialsPollInterval,
+		current:  current,
+	}
+	go pcp.poll()
+	return pcp, nil
+}
+
+func (pcp *pollingCredentialProvider) poll() {
+	ticker := time.NewTicker(pcp.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		current, err := pcp.read()
+		if err != nil {
+			// Half written files are read again on the next tick, the previous credentials are kept meanwhile
+			logger.Warn("Reading the client credentials", "provider", pcp.name, "error", err)
+			continue
+		}
+
+		pcp.mu.Lock()
+		previous := pcp.current
+		pcp.current = current
+		listeners := pcp.listeners
+		pcp.mu.Unlock()
+
+		if previous == current {
+			continue
+		}
+		logger.Info("Client credentials changed", "provider", pcp.name)
+		for _, listener := range listeners {
+			listener(previous, current)
+		}
+	}
+}
+
+func (pcp *pollingCredentialProvider) Name() string {
+	return pcp.name
+}
+
+func (pcp *pollingCredentialProvider) ClientCredentials() (ClientCredentials, error) {
+	pcp.mu.Lock()
+	defer pcp.mu.Unlock()
+	return pcp.current, nil
+}
+
+func (pcp *pollingCredentialProvider) OnChange(onChange func(previous, current ClientCredentials)) {
+	pcp.mu.Lock()
+	defer pcp.mu.Unlock()
+	pcp.listeners = append(pcp.listeners, onChange)
+}
+
+// Reads the client id and secret from two files, e.g. the keys of a Kubernetes secret mounted as a volume.
+// The files are read again periodically, so rotating the secret doesn't need a restart.
+func NewFileCredentialProvider(clientIDPath, clientSecretPath string) (CredentialProvider, error) {
+	if clientIDPath == "" || clientSecretPath == "" {
+		return nil, fmt.Errorf("environment variables %s and %s must both be set", constants.ClientIdFileEnv, constants.ClientSecretFileEnv)
+	}
+
+	return newPollingCredentialProvider("file", func() (ClientCredentials, error) {
+		clientID, err := readSecretFile(clientIDPath)
+		if err != nil {
+			return ClientCredentials{}, err
+		}
+		clientSecret, err := readSecretFile(clientSecretPath)
+		if err != nil {
+			return ClientCredentials{}, err
+		}
+		return ClientCredentials{ClientID: clientID, ClientSecret: clientSecret}, nil
+	})
+}
+
+// Reads a file holding a single value, ignoring the surrounding white space added by editors.
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := string(bytes.TrimSpace(content))
+	if value == "" {
+		return "", fmt.Errorf("file %s is empty", path)
+	}
+	return value, nil
+}
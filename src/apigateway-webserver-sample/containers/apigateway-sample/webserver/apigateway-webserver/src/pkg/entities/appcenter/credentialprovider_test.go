@@ -0,0 +1,154 @@
+package appcenter
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"apigateway-webserver/src/pkg/constants"
+)
+
+// Reads the credentials again every few milliseconds instead of every few seconds.
+func pollQuickly(t *testing.T) {
+	t.Helper()
+	interval := credentialsPollInterval
+	credentialsPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { credentialsPollInterval = interval })
+}
+
+func writeFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := NewKeystoreKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func sealKeystore(t *testing.T, key []byte, credentials ClientCredentials) []byte {
+	t.Helper()
+	content, err := SealKeystore(key, credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// Waits for the provider to report a change of its credentials.
+func waitForChange(t *testing.T, changes chan [2]ClientCredentials, previous, current ClientCredentials) {
+	t.Helper()
+	select {
+	case change := <-changes:
+		if change[0] != previous || change[1] != current {
+			t.Fatalf("changed from %v to %v, expected from %v to %v", change[0].ClientID, change[1].ClientID, previous.ClientID, current.ClientID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("credentials change not reported")
+	}
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	key := newTestKey(t)
+	credentials := ClientCredentials{ClientID: "client", ClientSecret: "secret"}
+
+	opened, err := OpenKeystore(key, sealKeystore(t, key, credentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != credentials {
+		t.Fatalf("opened %v, expected %v", opened.ClientID, credentials.ClientID)
+	}
+}
+
+func TestKeystoreRejectsWrongKeys(t *testing.T) {
+	key := newTestKey(t)
+	content := sealKeystore(t, key, ClientCredentials{ClientID: "client", ClientSecret: "secret"})
+
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{"other key", newTestKey(t)},
+		{"short key", key[:16]},
+		{"no key", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := OpenKeystore(test.key, content); err == nil {
+				t.Fatal("keystore opened with the wrong key")
+			}
+		})
+	}
+
+	if _, err := SealKeystore(key[:16], ClientCredentials{ClientID: "client", ClientSecret: "secret"}); err == nil {
+		t.Fatal("keystore sealed with a short key")
+	}
+}
+
+// Rewriting the secret files reports the new credentials to the listeners.
+func TestFileCredentialProviderReportsChanges(t *testing.T) {
+	pollQuickly(t)
+	dir := t.TempDir()
+	idPath, secretPath := filepath.Join(dir, "client-id"), filepath.Join(dir, "client-secret")
+	writeFile(t, idPath, []byte("client\n"))
+	writeFile(t, secretPath, []byte("secret\n"))
+
+	provider, err := NewFileCredentialProvider(idPath, secretPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan [2]ClientCredentials, 10)
+	provider.OnChange(func(previous, current ClientCredentials) {
+		changes <- [2]ClientCredentials{previous, current}
+	})
+
+	previous := ClientCredentials{ClientID: "client", ClientSecret: "secret"}
+	if credentials, _ := provider.ClientCredentials(); credentials != previous {
+		t.Fatalf("read %v, expected %v", credentials.ClientID, previous.ClientID)
+	}
+
+	writeFile(t, secretPath, []byte("rotated"))
+	current := ClientCredentials{ClientID: "client", ClientSecret: "rotated"}
+	waitForChange(t, changes, previous, current)
+	if credentials, _ := provider.ClientCredentials(); credentials != current {
+		t.Fatal("provider kept the previous credentials")
+	}
+}
+
+// Writing a new keystore reports its credentials, a keystore sealed with another key is ignored.
+func TestKeystoreCredentialProviderReportsChanges(t *testing.T) {
+	pollQuickly(t)
+	key := newTestKey(t)
+	t.Setenv(constants.KeystoreKeyEnv, base64.StdEncoding.EncodeToString(key))
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	previous := ClientCredentials{ClientID: "client", ClientSecret: "secret"}
+	writeFile(t, path, sealKeystore(t, key, previous))
+
+	provider, err := NewKeystoreCredentialProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan [2]ClientCredentials, 10)
+	provider.OnChange(func(previous, current ClientCredentials) {
+		changes <- [2]ClientCredentials{previous, current}
+	})
+
+	writeFile(t, path, sealKeystore(t, newTestKey(t), ClientCredentials{ClientID: "forged", ClientSecret: "forged"}))
+	time.Sleep(100 * time.Millisecond)
+	if credentials, _ := provider.ClientCredentials(); credentials != previous {
+		t.Fatal("credentials of a keystore sealed with another key used")
+	}
+
+	current := ClientCredentials{ClientID: "rotated", ClientSecret: "rotated"}
+	writeFile(t, path, sealKeystore(t, key, current))
+	waitForChange(t, changes, previous, current)
+}
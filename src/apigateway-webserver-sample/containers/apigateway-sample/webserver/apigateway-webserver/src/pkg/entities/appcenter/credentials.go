@@ -1,13 +1,12 @@
 package appcenter
 
 import (
-	"fmt"
-	"os"
-
 	"apigateway-webserver/src/pkg/constants/enums"
 )
 
-func ReadCredentialsFromEnv(username, password string, flowType enums.CredentialsFlowType) (string, string, error) {
+// Returns the username and password to log in with. For the client credentials flow they are the client id and secret
+// read from the credential provider of the process, see GetCredentialProvider.
+func ReadCredentials(username, password string, flowType enums.CredentialsFlowType) (string, string, error) {
 	if flowType == enums.ClientCredentialsFlow {
 		// For credentials to be available for the app the clientCredentialsFlow must be defined in app-definition.yaml like this:
 		//
@@ -16,16 +15,16 @@ func ReadCredentialsFromEnv(username, password string, flowType enums.Credential
 		//    clientName: "apigateway-sample-service"
 		//    clientScopes: [ "managementserver" ]
 
-		clientID, available := os.LookupEnv("CCF_CLIENT_ID")
-		if !available {
-			return "", "", fmt.Errorf("environment variable CCF_CLIENT_ID not set")
+		provider, err := GetCredentialProvider()
+		if err != nil {
+			return "", "", err
 		}
 
-		clientSecret, available := os.LookupEnv("CCF_CLIENT_SECRET")
-		if !available {
-			return "", "", fmt.Errorf("environment variable CCF_CLIENT_SECRET not set")
+		credentials, err := provider.ClientCredentials()
+		if err != nil {
+			return "", "", err
 		}
-		return clientID, clientSecret, nil
+		return credentials.ClientID, credentials.ClientSecret, nil
 	}
 	return username, password, nil
 }
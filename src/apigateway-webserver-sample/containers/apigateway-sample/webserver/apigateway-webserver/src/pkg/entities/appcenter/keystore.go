@@ -0,0 +1,145 @@
+package appcenter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"apigateway-webserver/src/pkg/constants"
+)
+
+// Version of the keystore format written by SealKeystore.
+const KeystoreVersion = 1
+
+// Size of the AES-256 key encrypting the keystore.
+const KeystoreKeySize = 32
+
+// Content of the keystore file. The client credentials are encrypted with AES-256-GCM.
+type keystoreFile struct {
+	Version    int    `json:"version"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func newKeystoreCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeystoreKeySize {
+		return nil, fmt.Errorf("keystore key must be %d bytes, got %d", KeystoreKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Returns a new random key for SealKeystore.
+func NewKeystoreKey() ([]byte, error) {
+	key := make([]byte, KeystoreKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Encrypts the client credentials with the key, returning the content of the keystore file.
+func SealKeystore(key []byte, credentials ClientCredentials) ([]byte, error) {
+	aead, err := newKeystoreCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal client credentials: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&keystoreFile{
+		Version:    KeystoreVersion,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+	}, "", "  ")
+}
+
+// Decrypts the content of a keystore file written by SealKeystore.
+func OpenKeystore(key []byte, content []byte) (ClientCredentials, error) {
+	var file keystoreFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return ClientCredentials{}, fmt.Errorf("failed to unmarshal keystore: %w", err)
+	}
+	if file.Version != KeystoreVersion {
+		return ClientCredentials{}, fmt.Errorf("unsupported keystore version %d", file.Version)
+	}
+
+	aead, err := newKeystoreCipher(key)
+	if err != nil {
+		return ClientCredentials{}, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return ClientCredentials{}, errors.New("invalid keystore nonce")
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return ClientCredentials{}, errors.New("failed to decrypt keystore, wrong key or corrupted file")
+	}
+
+	var credentials ClientCredentials
+	if err := json.Unmarshal(plaintext, &credentials); err != nil {
+		return ClientCredentials{}, fmt.Errorf("failed to unmarshal client credentials: %w", err)
+	}
+	if credentials.ClientID == "" || credentials.ClientSecret == "" {
+		return ClientCredentials{}, errors.New("keystore without client id or secret")
+	}
+	return credentials, nil
+}
+
+// Reads the base64 keystore key from the CCF_KEYSTORE_KEY environment variable, or from the file named by CCF_KEYSTORE_KEY_FILE.
+func ReadKeystoreKey() ([]byte, error) {
+	encoded := os.Getenv(constants.KeystoreKeyEnv)
+	if path := os.Getenv(constants.KeystoreKeyFileEnv); encoded == "" && path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(content)
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("environment variable %s or %s not set", constants.KeystoreKeyEnv, constants.KeystoreKeyFileEnv)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore key: %w", err)
+	}
+	return key, nil
+}
+
+// Reads the client credentials from an encrypted keystore file. The file is read again periodically,
+// so writing a new keystore rotates the credentials without a restart. The key is only read once.
+func NewKeystoreCredentialProvider(path string) (CredentialProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("environment variable %s not set", constants.KeystorePathEnv)
+	}
+
+	key, err := ReadKeystoreKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return newPollingCredentialProvider("keystore", func() (ClientCredentials, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return ClientCredentials{}, err
+		}
+		return OpenKeystore(key, content)
+	})
+}
@@ -11,6 +11,8 @@ type AnalyticsEvent struct {
 	Source    string `json:"source"`
 	Timestamp string `json:"time"`
 	Datatype  string `json:"datatype"`
+	// Hostname of the management server the event comes from, set when events of several sites are merged
+	Site string `json:"site,omitempty"`
 }
 
 type AnalyticsEvents struct {
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Event as stored by the Events REST API.
+type Event struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Source string `json:"source"`
+	Time   string `json:"time"`
+	// Additional event data, only available when requested (include=data)
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Event) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+type EventsList struct {
+	Events []*Event
+}
+
+func NewEventsList() *EventsList {
+	return &EventsList{
+		Events: []*Event{},
+	}
+}
+
+func (el *EventsList) Add(e *Event) {
+	if e != nil {
+		el.Events = append(el.Events, e)
+	}
+}
+
+func (el *EventsList) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(el.Events)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal events: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// Request body used to trigger an event.
+type EventTriggerRequest struct {
+	// Id of the event type, e.g. a user-defined event
+	Type string `json:"type"`
+	// Optional source of the event, e.g. cameras/{id}
+	Source string `json:"source,omitempty"`
+	// Optional additional event data
+	Data json.RawMessage `json:"data,omitempty"`
+}
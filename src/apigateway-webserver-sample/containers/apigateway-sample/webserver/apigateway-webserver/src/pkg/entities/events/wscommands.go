@@ -7,11 +7,12 @@ import (
 
 // WebSocket command request.
 type WsCommandRequest struct {
-	Command     string               `json:"command"`
-	CommandID   int                  `json:"commandId"`
-	SessionID   string               `json:"sessionId"`
-	LastEventID string               `json:"eventId"`
-	Filters     []SubscriptionFilter `json:"filters"`
+	Command        string               `json:"command"`
+	CommandID      int                  `json:"commandId"`
+	SessionID      string               `json:"sessionId"`
+	LastEventID    string               `json:"eventId"`
+	Filters        []SubscriptionFilter `json:"filters"`
+	SubscriptionID string               `json:"subscriptionId,omitempty"`
 }
 
 // WebSocket command response.
@@ -19,7 +20,9 @@ type WsCommandResponse struct {
 	SessionID string `json:"sessionId"`
 	CommandID int    `json:"commandId"`
 	Status    int    `json:"status"`
-	Error     struct {
+	// Id of the subscription created by an addSubscription command, needed to remove it
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	Error          struct {
 		ErrorText string `json:"errorText"`
 	} `json:"error"`
 }
@@ -43,3 +46,23 @@ type SubscriptionFilter struct {
 type SubscriptionFilters struct {
 	Filters []SubscriptionFilter `json:"filters"`
 }
+
+// Creates a filter including the events of the given types from the given cameras.
+func NewCamerasFilter(cameraIDs []string, eventTypeIDs ...string) SubscriptionFilter {
+	return SubscriptionFilter{
+		Modifier:      "include",
+		ResourceTypes: []string{"cameras"},
+		SourceIDs:     cameraIDs,
+		EventTypes:    eventTypeIDs,
+	}
+}
+
+// Creates a filter including all events of all sources of the given resource types (e.g. "alarms").
+func NewResourceTypesFilter(resourceTypes ...string) SubscriptionFilter {
+	return SubscriptionFilter{
+		Modifier:      "include",
+		ResourceTypes: resourceTypes,
+		SourceIDs:     []string{"*"},
+		EventTypes:    []string{"*"},
+	}
+}
@@ -0,0 +1,100 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+)
+
+type Alarm struct {
+	ID           string           `json:"id"`
+	LocalID      int              `json:"localId"`
+	Name         string           `json:"name"`
+	Message      string           `json:"message"`
+	Description  string           `json:"description"`
+	Source       string           `json:"source"`
+	SourceName   string           `json:"sourceName"`
+	Category     string           `json:"category"`
+	Priority     int              `json:"priority"`
+	State        enums.AlarmState `json:"state"`
+	AssignedTo   string           `json:"assignedTo"`
+	Time         string           `json:"time"`
+	LastModified string           `json:"lastModified"`
+}
+
+func (a *Alarm) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(a)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal alarm: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+type Alarms struct {
+	Alarms []*Alarm
+}
+
+func NewAlarms() *Alarms {
+	return &Alarms{
+		Alarms: []*Alarm{},
+	}
+}
+
+func (as *Alarms) Add(a *Alarm) {
+	if a != nil {
+		as.Alarms = append(as.Alarms, a)
+	}
+}
+
+func (as *Alarms) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(as.Alarms)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal alarms: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// Criteria to filter the alarms list. Fields left with their zero value don't filter.
+type AlarmFilter struct {
+	State    enums.AlarmState `json:"state"`
+	Priority int              `json:"priority"`
+	// Source of the alarm, e.g. cameras/{id}
+	Source string    `json:"source"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+}
+
+// Returns the filter as REST API query parameters (field=operator:value).
+func (af *AlarmFilter) Query() url.Values {
+	query := url.Values{}
+	if af == nil {
+		return query
+	}
+	if af.State != 0 {
+		query.Add("state", "eq:"+strconv.Itoa(int(af.State)))
+	}
+	if af.Priority != 0 {
+		query.Add("priority", "eq:"+strconv.Itoa(af.Priority))
+	}
+	if af.Source != "" {
+		query.Add("source", "eq:"+af.Source)
+	}
+	if !af.From.IsZero() {
+		query.Add("time", "gte:"+af.From.UTC().Format(time.RFC3339))
+	}
+	if !af.To.IsZero() {
+		query.Add("time", "lte:"+af.To.UTC().Format(time.RFC3339))
+	}
+	return query
+}
+
+// Fields of an alarm that can be changed. Nil fields are left unchanged.
+type AlarmUpdate struct {
+	State      *enums.AlarmState `json:"state,omitempty"`
+	AssignedTo *string           `json:"assignedTo,omitempty"`
+	Comment    string            `json:"comment,omitempty"`
+}
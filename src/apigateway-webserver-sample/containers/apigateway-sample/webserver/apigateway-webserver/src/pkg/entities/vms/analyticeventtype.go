@@ -2,17 +2,125 @@ package vms
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 )
 
 type AnalyticEventType struct {
-	ID           string   `json:"id"`
+	ID           string   `json:"id,omitempty"`
 	Name         string   `json:"displayName"`
 	Description  string   `json:"description"`
-	LastModified string   `json:"lastModified"`
+	LastModified string   `json:"lastModified,omitempty"`
 	Sources      []string `json:"sourceArray"`
 }
 
+func (aet *AnalyticEventType) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(aet)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal analytic event type: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// Returns the fields of the event type that can be created or changed, without the site specific id and modification time.
+func (aet *AnalyticEventType) Definition() *AnalyticEventTypeDefinition {
+	return &AnalyticEventTypeDefinition{
+		Name:        aet.Name,
+		Description: aet.Description,
+		Sources:     aet.Sources,
+	}
+}
+
+// Fields of an analytic event type sent when creating or updating it.
+type AnalyticEventTypeDefinition struct {
+	Name        string   `json:"displayName"`
+	Description string   `json:"description"`
+	Sources     []string `json:"sourceArray"`
+}
+
+// Tells whether both definitions describe the same event type, with the same sources in any order.
+func (aetd *AnalyticEventTypeDefinition) Equal(other *AnalyticEventTypeDefinition) bool {
+	return aetd.Name == other.Name &&
+		aetd.Description == other.Description &&
+		slices.Equal(slices.Sorted(slices.Values(aetd.Sources)), slices.Sorted(slices.Values(other.Sources)))
+}
+
+// Checks the fields required by the server are set.
+func (aetd *AnalyticEventTypeDefinition) Validate() error {
+	if aetd.Name == "" {
+		return errors.New("analytic event type without displayName")
+	}
+	return nil
+}
+
+// Version of the catalog format written by the export. Catalogs with a newer version can't be imported.
+const AnalyticEventCatalogVersion = 1
+
+// Portable list of analytic event types, used to move the event types from one site to another.
+type AnalyticEventCatalog struct {
+	Version    int                            `json:"version"`
+	EventTypes []*AnalyticEventTypeDefinition `json:"eventTypes"`
+}
+
+func NewAnalyticEventCatalog(aets *AnalyticEventTypes) *AnalyticEventCatalog {
+	catalog := &AnalyticEventCatalog{
+		Version:    AnalyticEventCatalogVersion,
+		EventTypes: []*AnalyticEventTypeDefinition{},
+	}
+	for _, aet := range aets.Types {
+		catalog.EventTypes = append(catalog.EventTypes, aet.Definition())
+	}
+	return catalog
+}
+
+// Checks the catalog version is supported and every event type is valid.
+func (aec *AnalyticEventCatalog) Validate() error {
+	if aec.Version < 1 || aec.Version > AnalyticEventCatalogVersion {
+		return fmt.Errorf("unsupported catalog version %d", aec.Version)
+	}
+	for i, aetd := range aec.EventTypes {
+		if err := aetd.Validate(); err != nil {
+			return fmt.Errorf("event type %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Result of the import of a catalog. Existing event types are never overwritten by an import: the ones defined like in
+// the catalog are unchanged, the others are conflicts left as they are, to be updated one by one.
+type AnalyticEventTypeImport struct {
+	Created []*AnalyticEventType `json:"created"`
+	// Names of the existing event types already defined like in the catalog
+	Unchanged []string `json:"unchanged"`
+	// Names of the existing event types defined differently from the catalog
+	Conflicts []string `json:"conflicts"`
+}
+
+func NewAnalyticEventTypeImport() *AnalyticEventTypeImport {
+	return &AnalyticEventTypeImport{
+		Created:   []*AnalyticEventType{},
+		Unchanged: []string{},
+		Conflicts: []string{},
+	}
+}
+
+func (aeti *AnalyticEventTypeImport) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(aeti)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal analytic event type import: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+func (aec *AnalyticEventCatalog) ToJSON() (string, error) {
+	jsonData, err := json.MarshalIndent(aec, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal analytic event catalog: %w", err)
+	}
+	return string(jsonData), nil
+}
+
 type AnalyticEventTypes struct {
 	Types []*AnalyticEventType
 }
@@ -0,0 +1,89 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type Bookmark struct {
+	ID string `json:"id,omitempty"`
+	// Id of the camera the bookmark is set on
+	DeviceID      string `json:"deviceId"`
+	TimeBegin     string `json:"timeBegin"`
+	TimeTriggered string `json:"timeTriggered"`
+	TimeEnd       string `json:"timeEnd"`
+	Header        string `json:"header"`
+	Description   string `json:"description"`
+	Reference     string `json:"reference,omitempty"`
+	User          string `json:"user,omitempty"`
+}
+
+// Creates a bookmark on the given camera covering the pre and post window around the triggered time.
+func NewBookmarkAround(cameraID string, triggered time.Time, pre, post time.Duration) *Bookmark {
+	triggered = triggered.UTC()
+	return &Bookmark{
+		DeviceID:      cameraID,
+		TimeBegin:     triggered.Add(-pre).Format(time.RFC3339Nano),
+		TimeTriggered: triggered.Format(time.RFC3339Nano),
+		TimeEnd:       triggered.Add(post).Format(time.RFC3339Nano),
+	}
+}
+
+func (b *Bookmark) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal bookmark: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+type Bookmarks struct {
+	Bookmarks []*Bookmark
+}
+
+func NewBookmarks() *Bookmarks {
+	return &Bookmarks{
+		Bookmarks: []*Bookmark{},
+	}
+}
+
+func (bs *Bookmarks) Add(b *Bookmark) {
+	if b != nil {
+		bs.Bookmarks = append(bs.Bookmarks, b)
+	}
+}
+
+func (bs *Bookmarks) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(bs.Bookmarks)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal bookmarks: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// Payload of the bookmarks search task, returning the bookmarks of the given cameras within a time range.
+type BookmarkSearch struct {
+	DeviceIDs []string `json:"deviceIds"`
+	TimeFrom  string   `json:"timeFrom"`
+	TimeTo    string   `json:"timeTo"`
+	// Maximum number of bookmarks to return
+	Count int `json:"count,omitempty"`
+}
+
+// Creates a search for the bookmarks of the given camera between from and to.
+func NewBookmarkSearch(cameraID string, from, to time.Time) *BookmarkSearch {
+	return &BookmarkSearch{
+		DeviceIDs: []string{cameraID},
+		TimeFrom:  from.UTC().Format(time.RFC3339Nano),
+		TimeTo:    to.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// Fields of a bookmark that can be changed. Nil fields are left unchanged.
+type BookmarkUpdate struct {
+	Header      *string `json:"header,omitempty"`
+	Description *string `json:"description,omitempty"`
+	TimeBegin   *string `json:"timeBegin,omitempty"`
+	TimeEnd     *string `json:"timeEnd,omitempty"`
+}
@@ -12,6 +12,18 @@ type Camera struct {
 	Enabled      bool   `json:"enabled"`
 	LastModified string `json:"lastModified"`
 	Channel      int    `json:"channel"`
+	// Link to the parent hardware, as returned by the API
+	Relations *ItemRelations `json:"relations,omitempty"`
+	// Recording server of the parent hardware. Only known once resolved from the hardware, see HierarchyService.LinkCameraParents
+	RecordingServerID string `json:"recordingServerId,omitempty"`
+}
+
+// Returns the id of the hardware the camera belongs to, empty when unknown.
+func (c *Camera) HardwareID() string {
+	if c.Relations == nil || c.Relations.Parent == nil {
+		return ""
+	}
+	return c.Relations.Parent.ID
 }
 
 func (c *Camera) ToString() string {
@@ -45,6 +57,15 @@ func (cl *CamerasList) Add(c *Camera) {
 	}
 }
 
+// Returns the ids of all cameras of the list.
+func (cl *CamerasList) IDs() []string {
+	ids := []string{}
+	for _, item := range cl.Cameras {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
 func (cl *CamerasList) Empty() bool {
 	return len(cl.Cameras) == 0
 }
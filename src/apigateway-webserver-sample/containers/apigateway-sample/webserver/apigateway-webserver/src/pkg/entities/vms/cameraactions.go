@@ -0,0 +1,78 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type Output struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"displayName"`
+	Description  string         `json:"description"`
+	Enabled      bool           `json:"enabled"`
+	LastModified string         `json:"lastModified"`
+	Relations    *ItemRelations `json:"relations,omitempty"`
+}
+
+type Outputs struct {
+	Outputs []*Output
+}
+
+func NewOutputs() *Outputs {
+	return &Outputs{
+		Outputs: []*Output{},
+	}
+}
+
+func (os *Outputs) Add(o *Output) {
+	if o != nil {
+		os.Outputs = append(os.Outputs, o)
+	}
+}
+
+func (os *Outputs) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(os.Outputs)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal outputs: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// Preset position of a PTZ camera.
+type PtzPreset struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"displayName"`
+	Description string         `json:"description"`
+	Relations   *ItemRelations `json:"relations,omitempty"`
+}
+
+// Output with the tasks that can be invoked on it (e.g. activate, deactivate).
+type OutputActions struct {
+	Output *Output       `json:"output"`
+	Tasks  []*ConfigTask `json:"tasks"`
+}
+
+// Everything that can be done on a camera: its own tasks, its PTZ presets and the outputs of its hardware.
+type CameraActions struct {
+	Camera     *Camera          `json:"camera"`
+	Tasks      []*ConfigTask    `json:"tasks"`
+	PtzPresets []*PtzPreset     `json:"ptzPresets"`
+	Outputs    []*OutputActions `json:"outputs"`
+}
+
+func NewCameraActions(c *Camera) *CameraActions {
+	return &CameraActions{
+		Camera:     c,
+		Tasks:      []*ConfigTask{},
+		PtzPresets: []*PtzPreset{},
+		Outputs:    []*OutputActions{},
+	}
+}
+
+func (ca *CameraActions) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(ca)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal camera actions: %w", err)
+	}
+	return string(jsonData), nil
+}
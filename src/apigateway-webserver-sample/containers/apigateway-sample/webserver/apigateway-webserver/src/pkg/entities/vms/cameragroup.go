@@ -0,0 +1,38 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Camera group as returned by the API. Groups can contain cameras and other camera groups.
+type CameraGroup struct {
+	ID           string `json:"id"`
+	Name         string `json:"displayName"`
+	Description  string `json:"description"`
+	LastModified string `json:"lastModified"`
+}
+
+type CameraGroups struct {
+	Groups []*CameraGroup
+}
+
+func (cgs *CameraGroups) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(cgs.Groups)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal camera groups: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+func NewCameraGroups() *CameraGroups {
+	return &CameraGroups{
+		Groups: []*CameraGroup{},
+	}
+}
+
+func (cgs *CameraGroups) Add(g *CameraGroup) {
+	if g != nil {
+		cgs.Groups = append(cgs.Groups, g)
+	}
+}
@@ -0,0 +1,130 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Optional API gateway feature, available only on some XProtect versions.
+type Feature string
+
+const (
+	FeatureConfigApi       Feature = "configApi"
+	FeatureEventsWebsocket Feature = "eventsWebsocket"
+	FeatureEventsRest      Feature = "eventsRest"
+	FeatureAlarms          Feature = "alarms"
+	FeatureBookmarks       Feature = "bookmarks"
+)
+
+// Names of the features as shown to the user.
+var featureNames = map[Feature]string{
+	FeatureConfigApi:       "Configuration REST API",
+	FeatureEventsWebsocket: "Events and State WebSocket API",
+	FeatureEventsRest:      "Events REST API",
+	FeatureAlarms:          "Alarms REST API",
+	FeatureBookmarks:       "Bookmarks REST API",
+}
+
+// Returns all known features.
+func Features() []Feature {
+	return []Feature{FeatureConfigApi, FeatureEventsWebsocket, FeatureEventsRest, FeatureAlarms, FeatureBookmarks}
+}
+
+func (f Feature) Name() string {
+	if name, ok := featureNames[f]; ok {
+		return name
+	}
+	return string(f)
+}
+
+// XProtect product version, e.g. 24.1.0.1 for XProtect 2024 R1.
+type ProductVersion struct {
+	Major    int
+	Minor    int
+	Build    int
+	Revision int
+}
+
+// Parses a dotted version number. Missing trailing parts are zero, e.g. "24.1" is 24.1.0.0.
+func ParseProductVersion(version string) (ProductVersion, error) {
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	if len(parts) > 4 || parts[0] == "" {
+		return ProductVersion{}, fmt.Errorf("invalid product version %q", version)
+	}
+
+	numbers := [4]int{}
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return ProductVersion{}, fmt.Errorf("invalid product version %q", version)
+		}
+		numbers[i] = number
+	}
+	return ProductVersion{Major: numbers[0], Minor: numbers[1], Build: numbers[2], Revision: numbers[3]}, nil
+}
+
+// Returns the release name, e.g. "2024 R1" for 24.1.
+func (pv ProductVersion) Release() string {
+	return fmt.Sprintf("20%02d R%d", pv.Major, pv.Minor)
+}
+
+func (pv ProductVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", pv.Major, pv.Minor, pv.Build, pv.Revision)
+}
+
+// Whether a feature can be used on a server, and why not when it can't.
+type FeatureSupport struct {
+	Feature   Feature `json:"feature"`
+	Name      string  `json:"name"`
+	Supported bool    `json:"supported"`
+	Reason    string  `json:"reason,omitempty"`
+}
+
+// Features available on a server, detected by probing the optional endpoints. The version is only shown.
+type Capabilities struct {
+	// Empty when the server didn't report a version that could be parsed
+	Version  string                      `json:"version"`
+	Release  string                      `json:"release"`
+	Features map[Feature]*FeatureSupport `json:"features"`
+}
+
+func NewCapabilities() *Capabilities {
+	return &Capabilities{
+		Features: map[Feature]*FeatureSupport{},
+	}
+}
+
+func (c *Capabilities) SetVersion(version ProductVersion) {
+	c.Version = version.String()
+	c.Release = version.Release()
+}
+
+func (c *Capabilities) Set(f Feature, supported bool, reason string) {
+	c.Features[f] = &FeatureSupport{Feature: f, Name: f.Name(), Supported: supported, Reason: reason}
+}
+
+// Features that were not detected are assumed to be supported.
+func (c *Capabilities) Supports(f Feature) bool {
+	support, ok := c.Features[f]
+	return !ok || support.Supported
+}
+
+func (c *Capabilities) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal capabilities: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// Returned when a feature is used on a server that doesn't support it.
+type UnsupportedFeatureError struct {
+	Feature Feature
+	Reason  string
+}
+
+func (ufe *UnsupportedFeatureError) Error() string {
+	return fmt.Sprintf("%s is not supported by this server: %s", ufe.Feature.Name(), ufe.Reason)
+}
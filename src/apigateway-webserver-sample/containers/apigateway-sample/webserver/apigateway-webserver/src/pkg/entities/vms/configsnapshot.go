@@ -0,0 +1,166 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"apigateway-webserver/src/pkg/constants"
+)
+
+// Kinds of changes between two configuration snapshots.
+const (
+	ConfigChangeAdded    = "added"
+	ConfigChangeRemoved  = "removed"
+	ConfigChangeModified = "modified"
+)
+
+// Configuration items of a server, as read at a given time by an identity. Users are only allowed to read some items,
+// every identity has its own snapshots, see User.Identity.
+type ConfigSnapshot struct {
+	Server     string               `json:"server"`
+	Identity   string               `json:"identity"`
+	UpdatedAt  time.Time            `json:"updatedAt"`
+	Cameras    []*Camera            `json:"cameras"`
+	EventTypes []*AnalyticEventType `json:"eventTypes"`
+}
+
+func NewConfigSnapshot(server, identity string, cameras *CamerasList, eventTypes *AnalyticEventTypes) *ConfigSnapshot {
+	return &ConfigSnapshot{
+		Server:     server,
+		Identity:   identity,
+		UpdatedAt:  time.Now().UTC(),
+		Cameras:    cameras.Cameras,
+		EventTypes: eventTypes.Types,
+	}
+}
+
+func (cs *ConfigSnapshot) CamerasList() *CamerasList {
+	cameras := NewCamerasList()
+	for _, camera := range cs.Cameras {
+		cameras.Add(camera)
+	}
+	return cameras
+}
+
+func (cs *ConfigSnapshot) AnalyticEventTypes() *AnalyticEventTypes {
+	eventTypes := NewAnalyticEventTypes()
+	for _, eventType := range cs.EventTypes {
+		eventTypes.Add(*eventType)
+	}
+	return eventTypes
+}
+
+// Returns the changes from the previous snapshot to this one, comparing the items by id and lastModified.
+// Every item is reported as added when there is no previous snapshot.
+func (cs *ConfigSnapshot) Diff(previous *ConfigSnapshot) *ConfigChangeSet {
+	if previous == nil {
+		previous = &ConfigSnapshot{}
+	}
+
+	changeSet := NewConfigChangeSet()
+	changeSet.Changes = append(changeSet.Changes, diffConfigItems(constants.CamerasResource, cameraVersions(previous.Cameras), cameraVersions(cs.Cameras))...)
+	changeSet.Changes = append(changeSet.Changes, diffConfigItems(constants.AnalyticEventsResource, eventTypeVersions(previous.EventTypes), eventTypeVersions(cs.EventTypes))...)
+	return changeSet
+}
+
+// Name and modification time of a configuration item, by item id.
+type configItemVersion struct {
+	id           string
+	name         string
+	lastModified string
+}
+
+func cameraVersions(cameras []*Camera) []configItemVersion {
+	versions := []configItemVersion{}
+	for _, camera := range cameras {
+		versions = append(versions, configItemVersion{id: camera.ID, name: camera.Name, lastModified: camera.LastModified})
+	}
+	return versions
+}
+
+func eventTypeVersions(eventTypes []*AnalyticEventType) []configItemVersion {
+	versions := []configItemVersion{}
+	for _, eventType := range eventTypes {
+		versions = append(versions, configItemVersion{id: eventType.ID, name: eventType.Name, lastModified: eventType.LastModified})
+	}
+	return versions
+}
+
+func diffConfigItems(resource string, previous, current []configItemVersion) []*ConfigChange {
+	previousByID := map[string]configItemVersion{}
+	for _, item := range previous {
+		previousByID[item.id] = item
+	}
+
+	changes := []*ConfigChange{}
+	for _, item := range current {
+		old, found := previousByID[item.id]
+		delete(previousByID, item.id)
+		switch {
+		case !found:
+			changes = append(changes, &ConfigChange{Resource: resource, ID: item.id, Name: item.name, Kind: ConfigChangeAdded, LastModified: item.lastModified})
+		case old.lastModified != item.lastModified:
+			changes = append(changes, &ConfigChange{Resource: resource, ID: item.id, Name: item.name, Kind: ConfigChangeModified, PreviousLastModified: old.lastModified, LastModified: item.lastModified})
+		}
+	}
+	// Keep the order of the previous snapshot for the removed items
+	for _, item := range previous {
+		if _, removed := previousByID[item.id]; removed {
+			changes = append(changes, &ConfigChange{Resource: resource, ID: item.id, Name: item.name, Kind: ConfigChangeRemoved, PreviousLastModified: item.lastModified})
+		}
+	}
+	return changes
+}
+
+// Change of a single configuration item.
+type ConfigChange struct {
+	Resource             string `json:"resource"`
+	ID                   string `json:"id"`
+	Name                 string `json:"displayName"`
+	Kind                 string `json:"kind"`
+	PreviousLastModified string `json:"previousLastModified,omitempty"`
+	LastModified         string `json:"lastModified,omitempty"`
+}
+
+// Changes found by a single refresh of the configuration. Sequence numbers increase with every change set of a server.
+type ConfigChangeSet struct {
+	Sequence uint64          `json:"sequence"`
+	Time     time.Time       `json:"time"`
+	Changes  []*ConfigChange `json:"changes"`
+}
+
+func NewConfigChangeSet() *ConfigChangeSet {
+	return &ConfigChangeSet{
+		Time:    time.Now().UTC(),
+		Changes: []*ConfigChange{},
+	}
+}
+
+func (ccs *ConfigChangeSet) Empty() bool {
+	return len(ccs.Changes) == 0
+}
+
+type ConfigChangeSets struct {
+	ChangeSets []*ConfigChangeSet
+}
+
+func NewConfigChangeSets() *ConfigChangeSets {
+	return &ConfigChangeSets{
+		ChangeSets: []*ConfigChangeSet{},
+	}
+}
+
+func (ccss *ConfigChangeSets) Add(ccs *ConfigChangeSet) {
+	if ccs != nil {
+		ccss.ChangeSets = append(ccss.ChangeSets, ccs)
+	}
+}
+
+func (ccss *ConfigChangeSets) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(ccss.ChangeSets)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config change sets: %w", err)
+	}
+	return string(jsonData), nil
+}
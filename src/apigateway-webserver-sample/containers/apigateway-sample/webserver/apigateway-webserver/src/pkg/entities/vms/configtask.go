@@ -0,0 +1,92 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Known states of a configuration task.
+const (
+	TaskStateInProgress = "InProgress"
+	TaskStateSuccess    = "Success"
+	TaskStateError      = "Error"
+)
+
+// Path of a configuration item, e.g. { "type": "tasks", "id": "123" }.
+type ConfigItemPath struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// A task that can be invoked on a configuration item or item type.
+type ConfigTask struct {
+	ID   string `json:"id"`
+	Name string `json:"displayName"`
+}
+
+type ConfigTasks struct {
+	Tasks []*ConfigTask `json:"tasks"`
+}
+
+func NewConfigTasks() *ConfigTasks {
+	return &ConfigTasks{
+		Tasks: []*ConfigTask{},
+	}
+}
+
+func (cts *ConfigTasks) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(cts.Tasks)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config tasks: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// Result of invoking a configuration task.
+// Tasks that take time to complete run asynchronously on the server. For those, Path points to the item of the
+// `tasks` resource that can be read to follow the task progress and get its result once done.
+type ConfigTaskResult struct {
+	Path      *ConfigItemPath `json:"path,omitempty"`
+	State     string          `json:"state,omitempty"`
+	Progress  int             `json:"progress,omitempty"`
+	ErrorText string          `json:"errorText,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+
+	// Complete task response as sent by the server. The fields depend on the invoked task.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (ctr *ConfigTaskResult) UnmarshalJSON(data []byte) error {
+	type alias ConfigTaskResult
+	if err := json.Unmarshal(data, (*alias)(ctr)); err != nil {
+		return err
+	}
+	ctr.Raw = append(json.RawMessage{}, data...)
+	return nil
+}
+
+func (ctr *ConfigTaskResult) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(ctr)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config task result: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// Returns true when the task is running on the server and its result must be read from the tasks resource.
+func (ctr *ConfigTaskResult) IsAsync() bool {
+	return ctr.Path != nil && ctr.Path.Type == "tasks" && !ctr.IsDone()
+}
+
+// Returns true when the task has finished, successfully or not.
+func (ctr *ConfigTaskResult) IsDone() bool {
+	return ctr.State == TaskStateSuccess || ctr.State == TaskStateError
+}
+
+// Returns an error with the server error text when the task has failed.
+func (ctr *ConfigTaskResult) Err() error {
+	if ctr.State != TaskStateError {
+		return nil
+	}
+	return fmt.Errorf("task failed: %s", ctr.ErrorText)
+}
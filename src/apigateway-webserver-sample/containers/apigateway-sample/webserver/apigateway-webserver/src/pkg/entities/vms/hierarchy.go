@@ -0,0 +1,96 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Links of a configuration item to itself and to its parent item, as returned by the Configuration API.
+type ItemRelations struct {
+	Self   *ConfigItemPath `json:"self,omitempty"`
+	Parent *ConfigItemPath `json:"parent,omitempty"`
+}
+
+// Any configuration item with all its properties, used when the item type isn't known in advance.
+type ConfigItem map[string]any
+
+func (ci ConfigItem) ID() string {
+	id, _ := ci["id"].(string)
+	return id
+}
+
+func (ci ConfigItem) Name() string {
+	name, _ := ci["displayName"].(string)
+	return name
+}
+
+// Returns the id of the parent item from the item relations, empty when unknown.
+func (ci ConfigItem) ParentID() string {
+	relations, _ := ci["relations"].(map[string]any)
+	parent, _ := relations["parent"].(map[string]any)
+	id, _ := parent["id"].(string)
+	return id
+}
+
+// Node of the configuration hierarchy: recording servers, their hardware and the devices of every hardware.
+type HierarchyNode struct {
+	Type       string           `json:"type"`
+	ID         string           `json:"id"`
+	Name       string           `json:"displayName"`
+	Parent     *ConfigItemPath  `json:"parent,omitempty"`
+	Properties ConfigItem       `json:"properties,omitempty"`
+	Children   []*HierarchyNode `json:"children"`
+}
+
+// Creates a node for a configuration item of the given type, linked to its parent node when the parent is a configuration item.
+func NewHierarchyNode(itemType string, item ConfigItem, parent *HierarchyNode) *HierarchyNode {
+	node := &HierarchyNode{
+		Type:       itemType,
+		ID:         item.ID(),
+		Name:       item.Name(),
+		Properties: item,
+		Children:   []*HierarchyNode{},
+	}
+	if parent != nil && parent.ID != "" {
+		node.Parent = &ConfigItemPath{Type: parent.Type, ID: parent.ID}
+	}
+	return node
+}
+
+func (hn *HierarchyNode) AddChild(child *HierarchyNode) {
+	if child != nil {
+		hn.Children = append(hn.Children, child)
+	}
+}
+
+// Returns a copy of the hierarchy with only the nodes whose name contains the query (case insensitive) and their ancestors.
+// Children of a matching node are all kept. Returns nil when nothing matches.
+func (hn *HierarchyNode) Search(query string) *HierarchyNode {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" || strings.Contains(strings.ToLower(hn.Name), query) {
+		return hn
+	}
+
+	var children []*HierarchyNode
+	for _, child := range hn.Children {
+		if found := child.Search(query); found != nil {
+			children = append(children, found)
+		}
+	}
+	if len(children) == 0 {
+		return nil
+	}
+
+	found := *hn
+	found.Children = children
+	return &found
+}
+
+func (hn *HierarchyNode) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(hn)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal hierarchy: %w", err)
+	}
+	return string(jsonData), nil
+}
@@ -0,0 +1,117 @@
+package vms
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Signing keys of an IDP, read from the jwks_uri of its OpenID configuration. Only the RSA keys are kept, the IDP of
+// the management server signs its tokens with RS256.
+type JSONWebKeySet struct {
+	keys map[string]*rsa.PublicKey
+	// Keys without kid, tried for the tokens naming none
+	anonymous []*rsa.PublicKey
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Algorithms of the signatures checked, by JWS name.
+var jwsHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+func ParseJSONWebKeySet(data []byte) (*JSONWebKeySet, error) {
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the signing keys: %w", err)
+	}
+
+	jwks := &JSONWebKeySet{keys: make(map[string]*rsa.PublicKey)}
+	for _, key := range document.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(key.N)
+		e, errE := base64.RawURLEncoding.DecodeString(key.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA signing key %q", key.Kid)
+		}
+		publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.Kid == "" {
+			jwks.anonymous = append(jwks.anonymous, publicKey)
+		} else {
+			jwks.keys[key.Kid] = publicKey
+		}
+	}
+	if len(jwks.keys) == 0 && len(jwks.anonymous) == 0 {
+		return nil, errors.New("no RSA signing key")
+	}
+	return jwks, nil
+}
+
+// Checks the access token is a JWT signed by one of the keys, and returns its claims. Tokens without signature, with
+// another algorithm or signed by another key are refused.
+func (jwks *JSONWebKeySet) Verify(accessToken string) (map[string]any, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("access token isn't a JWT")
+	}
+
+	headerData, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the access token header: %w", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the access token header: %w", err)
+	}
+	hash, ok := jwsHashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("access token signed with the unsupported algorithm %q", header.Alg)
+	}
+
+	candidates := jwks.anonymous
+	if key, ok := jwks.keys[header.Kid]; ok {
+		candidates = []*rsa.PublicKey{key}
+	} else if header.Kid != "" {
+		return nil, fmt.Errorf("access token signed with the unknown key %q", header.Kid)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the access token signature: %w", err)
+	}
+	digest := hash.New()
+	digest.Write([]byte(parts[0] + "." + parts[1]))
+	verified := false
+	for _, key := range candidates {
+		if rsa.VerifyPKCS1v15(key, hash, digest.Sum(nil), signature) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("access token signature doesn't match the signing keys of the IDP")
+	}
+
+	return TokenSchema{AccessToken: accessToken}.Claims()
+}
@@ -0,0 +1,84 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Outcome of a readiness check.
+type CheckStatus string
+
+const (
+	CheckStatusOk     CheckStatus = "ok"
+	CheckStatusFailed CheckStatus = "failed"
+	// Not run because a check it depends on failed
+	CheckStatusSkipped CheckStatus = "skipped"
+)
+
+// Result of probing one dependency of the webserver.
+type ReadinessCheck struct {
+	Name      string      `json:"name"`
+	Status    CheckStatus `json:"status"`
+	LatencyMs int64       `json:"latencyMs"`
+	Error     string      `json:"error,omitempty"`
+	CheckedAt time.Time   `json:"checkedAt"`
+}
+
+func NewReadinessCheck(name string, checkedAt time.Time, latency time.Duration, err error) *ReadinessCheck {
+	check := &ReadinessCheck{
+		Name:      name,
+		Status:    CheckStatusOk,
+		LatencyMs: latency.Milliseconds(),
+		CheckedAt: checkedAt,
+	}
+	if err != nil {
+		check.Status = CheckStatusFailed
+		check.Error = err.Error()
+	}
+	return check
+}
+
+func NewSkippedReadinessCheck(name string, checkedAt time.Time, reason string) *ReadinessCheck {
+	return &ReadinessCheck{
+		Name:      name,
+		Status:    CheckStatusSkipped,
+		Error:     reason,
+		CheckedAt: checkedAt,
+	}
+}
+
+// Checks of the readiness endpoint. The webserver is ready when none of them failed.
+type ReadinessReport struct {
+	Status CheckStatus       `json:"status"`
+	Checks []*ReadinessCheck `json:"checks"`
+}
+
+func NewReadinessReport() *ReadinessReport {
+	return &ReadinessReport{
+		Status: CheckStatusOk,
+		Checks: []*ReadinessCheck{},
+	}
+}
+
+func (rr *ReadinessReport) Add(c *ReadinessCheck) {
+	if c == nil {
+		return
+	}
+	rr.Checks = append(rr.Checks, c)
+	if c.Status == CheckStatusFailed {
+		rr.Status = CheckStatusFailed
+	}
+}
+
+func (rr *ReadinessReport) Ready() bool {
+	return rr.Status != CheckStatusFailed
+}
+
+func (rr *ReadinessReport) ToJSON() (string, error) {
+	data, err := json.Marshal(rr)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal readiness report: %w", err)
+	}
+	return string(data), nil
+}
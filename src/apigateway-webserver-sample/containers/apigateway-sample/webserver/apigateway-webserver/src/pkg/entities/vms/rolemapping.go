@@ -0,0 +1,130 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+)
+
+// Claim of the access tokens read when the role mappings don't name one.
+const DefaultRoleClaim = "role"
+
+// Maps the identity of a user to a role of the webserver, read from the file named by the ROLE_MAPPINGS_PATH
+// environment variable. Mappings are given by role name, e.g. "operator". A user matching several mappings gets the
+// highest role, a user matching none gets the default role. Only the sites listed are trusted to tell the identity of
+// their users: the users of other sites get the default role.
+type RoleMappings struct {
+	// Role of the users matching no mapping, viewer when empty
+	DefaultRole string `json:"defaultRole,omitempty"`
+	// Hostnames of the management servers whose users are mapped, with the port when it isn't the default one, as typed
+	// on the login page
+	Sites []string `json:"sites,omitempty"`
+	// Claim of the access token holding the roles of the user, a string or an array of strings. Only read from tokens
+	// signed by the IDP of the site
+	Claim string `json:"claim,omitempty"`
+	// Values of the claim, by role
+	Claims map[string][]string `json:"claims,omitempty"`
+	// Names of the VMS roles the user is member of, by role. Read through the Configuration API, only the users
+	// allowed to read the VMS roles get a role from them
+	VmsRoles map[string][]string `json:"vmsRoles,omitempty"`
+
+	defaultRole enums.Role
+}
+
+// Returns the mappings used when nothing is configured: every user is a viewer, the actions on the VMS need mappings.
+func NewRoleMappings() *RoleMappings {
+	return &RoleMappings{
+		DefaultRole: enums.RoleViewer.String(),
+		Claim:       DefaultRoleClaim,
+		Sites:       []string{},
+		Claims:      map[string][]string{},
+		VmsRoles:    map[string][]string{},
+		defaultRole: enums.RoleViewer,
+	}
+}
+
+func ParseRoleMappings(data []byte) (*RoleMappings, error) {
+	mappings := &RoleMappings{}
+	if err := json.Unmarshal(data, mappings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal role mappings: %w", err)
+	}
+
+	if mappings.DefaultRole == "" {
+		mappings.DefaultRole = enums.RoleViewer.String()
+	}
+	defaultRole, err := enums.ParseRole(mappings.DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+	mappings.defaultRole = defaultRole
+
+	if mappings.Claim == "" {
+		mappings.Claim = DefaultRoleClaim
+	}
+	for _, byRole := range []map[string][]string{mappings.Claims, mappings.VmsRoles} {
+		for roleName := range byRole {
+			if _, err := enums.ParseRole(roleName); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return mappings, nil
+}
+
+func (rm *RoleMappings) Default() enums.Role {
+	return rm.defaultRole
+}
+
+// Tells whether the users of the site with the given hostname are mapped. Hostnames are compared without case.
+func (rm *RoleMappings) TrustsSite(hostname string) bool {
+	return slices.ContainsFunc(rm.Sites, func(site string) bool { return strings.EqualFold(site, hostname) })
+}
+
+// Tells whether the roles depend on the claims of the tokens, whose signature is only checked when they do.
+func (rm *RoleMappings) UsesClaims() bool {
+	return len(rm.Claims) > 0
+}
+
+// Tells whether the roles depend on the VMS roles, which are only read when they do.
+func (rm *RoleMappings) UsesVmsRoles() bool {
+	return len(rm.VmsRoles) > 0
+}
+
+// Returns the highest role mapped to the values of the claim in the given token claims, false when none is.
+func (rm *RoleMappings) RoleOfClaims(claims map[string]any) (enums.Role, bool) {
+	var values []string
+	switch claim := claims[rm.Claim].(type) {
+	case string:
+		values = []string{claim}
+	case []any:
+		for _, value := range claim {
+			if value, ok := value.(string); ok {
+				values = append(values, value)
+			}
+		}
+	}
+	return highestRole(rm.Claims, values)
+}
+
+// Returns the highest role mapped to the given VMS role names, false when none is.
+func (rm *RoleMappings) RoleOfVmsRoles(names []string) (enums.Role, bool) {
+	return highestRole(rm.VmsRoles, names)
+}
+
+// Returns the highest role whose mapped values include one of the values, compared without case.
+func highestRole(byRole map[string][]string, values []string) (enums.Role, bool) {
+	var highest enums.Role
+	for roleName, mapped := range byRole {
+		role, _ := enums.ParseRole(roleName)
+		matches := slices.ContainsFunc(mapped, func(m string) bool {
+			return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(m, v) })
+		})
+		if matches && role > highest {
+			highest = role
+		}
+	}
+	return highest, highest != 0
+}
@@ -16,6 +16,7 @@ type ApiWellKnownUrisSchema struct {
 type IdpOpenIdConfigSchema struct {
 	Issuer        string `json:"issuer"`
 	TokenEndPoint string `json:"token_endpoint"`
+	JwksUri       string `json:"jwks_uri"`
 	ServerVersion string `json:"server_version"`
 }
 
@@ -27,6 +28,10 @@ type Server struct {
 	serverInputInfo  serverInputInfo
 	IdpOpenIdConfig  *IdpOpenIdConfigSchema
 	ApiWellKnownUris *ApiWellKnownUrisSchema
+	// Detected once at login, nil until then
+	capabilities *Capabilities
+	// Parameters of the token requests, nil for the defaults of the credentials flow
+	tokenProfile *TokenProfile
 }
 
 func NewServer(serverURL *url.URL) *Server {
@@ -48,3 +53,36 @@ func (s *Server) Hostname() string {
 func (s *Server) IsSecure() bool {
 	return strings.HasPrefix(s.serverInputInfo.ServerURL.Scheme, "https")
 }
+
+func (s *Server) Capabilities() *Capabilities {
+	if s.capabilities == nil {
+		return NewCapabilities()
+	}
+	return s.capabilities
+}
+
+func (s *Server) SetCapabilities(c *Capabilities) {
+	s.capabilities = c
+}
+
+// Returns true when the server supports the given feature. Every feature is assumed supported until the capabilities are detected.
+func (s *Server) Supports(f Feature) bool {
+	return s.Capabilities().Supports(f)
+}
+
+// Returns an UnsupportedFeatureError when the server doesn't support the given feature, nil otherwise.
+func (s *Server) CheckSupports(f Feature) error {
+	if s.Supports(f) {
+		return nil
+	}
+	return &UnsupportedFeatureError{Feature: f, Reason: s.Capabilities().Features[f].Reason}
+}
+
+// Returns the parameters of the token requests to the server, nil when the defaults of the credentials flow are used.
+func (s *Server) TokenProfile() *TokenProfile {
+	return s.tokenProfile
+}
+
+func (s *Server) SetTokenProfile(tp *TokenProfile) {
+	s.tokenProfile = tp
+}
@@ -0,0 +1,66 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Configuration read from one of the management servers of a session, tagged with the hostname of the server.
+type Site struct {
+	Name              string               `json:"site"`
+	Capabilities      *Capabilities        `json:"capabilities"`
+	Cameras           []*Camera            `json:"cameras"`
+	CameraGroups      []*CameraGroup       `json:"cameraGroups"`
+	EventTypes        []*AnalyticEventType `json:"eventTypes"`
+	UserDefinedEvents []*UserDefinedEvent  `json:"userDefinedEvents"`
+	// Scopes asked for in the token requests, and the scopes the IDP granted, empty when it didn't say
+	RequestedScopes []string `json:"requestedScopes"`
+	GrantedScopes   []string `json:"grantedScopes"`
+}
+
+func NewSite(name string, capabilities *Capabilities, cameras *CamerasList, cameraGroups *CameraGroups, eventTypes *AnalyticEventTypes, userDefinedEvents *UserDefinedEvents) *Site {
+	return &Site{
+		Name:              name,
+		Capabilities:      capabilities,
+		Cameras:           cameras.Cameras,
+		CameraGroups:      cameraGroups.Groups,
+		EventTypes:        eventTypes.Types,
+		UserDefinedEvents: userDefinedEvents.Events,
+		RequestedScopes:   []string{},
+		GrantedScopes:     []string{},
+	}
+}
+
+// Sets the scopes requested with the token profile and the scopes granted in the token.
+func (s *Site) SetScopes(profile *TokenProfile, token Token) {
+	if profile != nil {
+		s.RequestedScopes = profile.Scopes
+	}
+	s.GrantedScopes = strings.Fields(token.GetSchema().Scope)
+}
+
+// Sites of a session, in login order.
+type Sites struct {
+	Sites []*Site
+}
+
+func NewSites() *Sites {
+	return &Sites{
+		Sites: []*Site{},
+	}
+}
+
+func (ss *Sites) Add(s *Site) {
+	if s != nil {
+		ss.Sites = append(ss.Sites, s)
+	}
+}
+
+func (ss *Sites) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(ss.Sites)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal sites: %w", err)
+	}
+	return string(jsonData), nil
+}
@@ -2,7 +2,13 @@ package vms
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -35,7 +41,36 @@ type TokenSchema struct {
 	Scope       string `json:"scope"`
 }
 
+// Returns the claims of the access token when it is a JWT. The signature isn't checked, the token comes straight from the
+// IDP and is only read to find the roles of its user.
+func (ts TokenSchema) Claims() (map[string]any, error) {
+	parts := strings.Split(ts.AccessToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("access token isn't a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the access token claims: %w", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the access token claims: %w", err)
+	}
+	return claims, nil
+}
+
+// Logs the token without its value.
+func (ts TokenSchema) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", ts.Type),
+		slog.Int64("expiresIn", ts.ExpiresIn),
+		slog.String("scope", ts.Scope),
+	)
+}
+
+// Safe for concurrent use, a client credentials token is shared by all app contexts of the same client.
 type token struct {
+	mu           sync.RWMutex
 	schema       TokenSchema
 	timestampUTC time.Time
 	dispatchFunc TokenDispatchFunc
@@ -55,6 +90,8 @@ func NewToken(tokenData []byte, tokenDispatchFunc TokenDispatchFunc) (Token, err
 }
 
 func (t *token) HasExpired() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 	return time.Since(t.timestampUTC) > time.Duration(t.schema.ExpiresIn)*time.Second
 }
 
@@ -62,19 +99,31 @@ func (t *token) DispatchToken(ctx context.Context) (string, error) {
 	if err := t.dispatchFunc(ctx, t); err != nil {
 		return "", err
 	}
-	return t.schema.AccessToken, nil
+	return t.GetSchema().AccessToken, nil
 }
 
 func (t *token) Copy(copy Token) error {
-	t.schema = copy.GetSchema()
-	t.timestampUTC = copy.GetTimestampUTC()
+	schema, timestampUTC := copy.GetSchema(), copy.GetTimestampUTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.schema = schema
+	t.timestampUTC = timestampUTC
 	return nil
 }
 
+// Logs the token without its value.
+func (t *token) LogValue() slog.Value {
+	return t.GetSchema().LogValue()
+}
+
 func (t *token) GetSchema() TokenSchema {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 	return t.schema
 }
 
 func (t *token) GetTimestampUTC() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 	return t.timestampUTC
 }
@@ -0,0 +1,182 @@
+package vms
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/constants/enums"
+)
+
+// Ways the client authenticates to the token endpoint, as named by OpenID Connect.
+const (
+	// Client id and secret sent in the request body
+	ClientSecretPost = "client_secret_post"
+	// Client id and secret sent in the Authorization header
+	ClientSecretBasic = "client_secret_basic"
+)
+
+// Parameters of the token requests of a credentials flow. Empty fields keep the value of the profile they are merged into.
+type TokenProfile struct {
+	Scopes []string `json:"scopes,omitempty"`
+	// Client registered on the IDP. Only used by the login form, the client credentials flow uses the id of its credentials
+	ClientID string `json:"clientId,omitempty"`
+	// Secret of the client of the login form, for clients that aren't public
+	ClientSecret string `json:"clientSecret,omitempty"`
+	// One of ClientSecretPost and ClientSecretBasic
+	AuthMethod string   `json:"authMethod,omitempty"`
+	Audiences  []string `json:"audiences,omitempty"`
+	// Any other parameter added to the token requests
+	ExtraParams map[string]string `json:"extraParams,omitempty"`
+}
+
+// Parameters set by the token request itself, which the extra parameters can't replace.
+var reservedTokenParams = []string{"grant_type", "scope", "client_id", "client_secret", "username", "password", "audience"}
+
+// Returns the parameters used when nothing is configured: the management server scope, and for the login form the client of the XProtect IDP.
+func DefaultTokenProfile(flow enums.CredentialsFlowType) *TokenProfile {
+	profile := &TokenProfile{
+		Scopes:     []string{constants.ManagementServerScope},
+		AuthMethod: ClientSecretPost,
+	}
+	if flow == enums.LoginForm {
+		profile.ClientID = "GrantValidatorClient"
+	}
+	return profile
+}
+
+// Returns the scopes as sent in the token request, separated by spaces.
+func (tp *TokenProfile) Scope() string {
+	return strings.Join(tp.Scopes, " ")
+}
+
+// Returns a hash of every parameter of the token requests of the profile except the client secret, so that tokens are only
+// shared between servers asking for them the same way: same scopes, client, audiences, extra parameters and auth method.
+func (tp *TokenProfile) Fingerprint() string {
+	params := *tp
+	params.ClientSecret = ""
+	// Maps are encoded with sorted keys, the encoding only depends on the values
+	encoded, _ := json.Marshal(params)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns a copy of the profile with the fields set in override replacing its own. Extra parameters are merged one by one.
+func (tp *TokenProfile) Merge(override *TokenProfile) *TokenProfile {
+	merged := *tp
+	merged.ExtraParams = map[string]string{}
+	for name, value := range tp.ExtraParams {
+		merged.ExtraParams[name] = value
+	}
+	if override == nil {
+		return &merged
+	}
+
+	if len(override.Scopes) > 0 {
+		merged.Scopes = override.Scopes
+	}
+	if override.ClientID != "" {
+		merged.ClientID = override.ClientID
+	}
+	if override.ClientSecret != "" {
+		merged.ClientSecret = override.ClientSecret
+	}
+	if override.AuthMethod != "" {
+		merged.AuthMethod = override.AuthMethod
+	}
+	if len(override.Audiences) > 0 {
+		merged.Audiences = override.Audiences
+	}
+	for name, value := range override.ExtraParams {
+		merged.ExtraParams[name] = value
+	}
+	return &merged
+}
+
+// Checks the profile can be used for the token requests of the given flow.
+func (tp *TokenProfile) Validate(flow enums.CredentialsFlowType) error {
+	if len(tp.Scopes) == 0 {
+		return errors.New("token profile without scopes")
+	}
+	if tp.AuthMethod != ClientSecretPost && tp.AuthMethod != ClientSecretBasic {
+		return fmt.Errorf("unsupported client authentication method %q, expected %s or %s", tp.AuthMethod, ClientSecretPost, ClientSecretBasic)
+	}
+	if flow == enums.LoginForm && tp.ClientID == "" {
+		return errors.New("token profile of the login form without client id")
+	}
+	if flow == enums.LoginForm && tp.AuthMethod == ClientSecretBasic && tp.ClientSecret == "" {
+		return errors.New("client_secret_basic needs a client secret")
+	}
+	for name := range tp.ExtraParams {
+		if slices.Contains(reservedTokenParams, name) {
+			return fmt.Errorf("extra parameter %s is set by the token request", name)
+		}
+	}
+	return nil
+}
+
+// Token profiles of all servers, read from the file named by the TOKEN_PROFILES_PATH environment variable.
+// Profiles are given by credentials flow name, e.g. "LoginForm". The profile of a server is merged into the default one,
+// itself merged into DefaultTokenProfile.
+type TokenProfiles struct {
+	Default map[string]*TokenProfile `json:"default,omitempty"`
+	// Profiles by server hostname, as entered on the login page
+	Servers map[string]map[string]*TokenProfile `json:"servers,omitempty"`
+}
+
+func NewTokenProfiles() *TokenProfiles {
+	return &TokenProfiles{
+		Default: map[string]*TokenProfile{},
+		Servers: map[string]map[string]*TokenProfile{},
+	}
+}
+
+func ParseTokenProfiles(data []byte) (*TokenProfiles, error) {
+	profiles := NewTokenProfiles()
+	if err := json.Unmarshal(data, profiles); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token profiles: %w", err)
+	}
+
+	flowProfiles := []map[string]*TokenProfile{profiles.Default}
+	for _, server := range profiles.Servers {
+		flowProfiles = append(flowProfiles, server)
+	}
+	for _, byFlow := range flowProfiles {
+		for flowName := range byFlow {
+			if _, err := enums.ParseCredentialsFlowType(flowName); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Fail on the first read rather than on the first login using a bad profile
+	for _, flowName := range enums.GetCredentialsFlowTypes() {
+		flow, _ := enums.ParseCredentialsFlowType(flowName)
+		if _, err := profiles.Resolve("", flow); err != nil {
+			return nil, fmt.Errorf("default %s profile: %w", flowName, err)
+		}
+		for hostname := range profiles.Servers {
+			if _, err := profiles.Resolve(hostname, flow); err != nil {
+				return nil, fmt.Errorf("%s profile of %s: %w", flowName, hostname, err)
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// Returns the profile of the server and flow.
+func (tps *TokenProfiles) Resolve(hostname string, flow enums.CredentialsFlowType) (*TokenProfile, error) {
+	profile := DefaultTokenProfile(flow).Merge(tps.Default[flow.String()])
+	if server, ok := tps.Servers[hostname]; ok {
+		profile = profile.Merge(server[flow.String()])
+	}
+	if err := profile.Validate(flow); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
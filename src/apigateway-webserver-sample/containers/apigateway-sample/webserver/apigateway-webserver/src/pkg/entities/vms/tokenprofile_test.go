@@ -0,0 +1,116 @@
+package vms
+
+import (
+	"reflect"
+	"testing"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/constants/enums"
+)
+
+// Profiles are merged from the built-in defaults, then the default profile of the flow, then the profile of the server.
+func TestTokenProfilesResolveMergeOrder(t *testing.T) {
+	profiles, err := ParseTokenProfiles([]byte(`{
+		"default": {
+			"LoginForm": {"scopes": ["openid", "managementserver"], "audiences": ["default"], "extraParams": {"a": "default", "b": "default"}},
+			"ClientCredentialsFlow": {"authMethod": "client_secret_basic"}
+		},
+		"servers": {
+			"vms": {
+				"LoginForm": {"clientId": "vms-client", "clientSecret": "vms-secret", "audiences": ["vms"], "extraParams": {"b": "vms", "c": "vms"}}
+			},
+			"other": {
+				"ClientCredentialsFlow": {"scopes": ["other"]}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hostname string
+		flow     enums.CredentialsFlowType
+		expected TokenProfile
+	}{
+		{"default of the flow", "unknown", enums.LoginForm, TokenProfile{
+			Scopes:      []string{"openid", "managementserver"},
+			ClientID:    "GrantValidatorClient",
+			AuthMethod:  ClientSecretPost,
+			Audiences:   []string{"default"},
+			ExtraParams: map[string]string{"a": "default", "b": "default"},
+		}},
+		{"server over default", "vms", enums.LoginForm, TokenProfile{
+			Scopes:       []string{"openid", "managementserver"},
+			ClientID:     "vms-client",
+			ClientSecret: "vms-secret",
+			AuthMethod:   ClientSecretPost,
+			Audiences:    []string{"vms"},
+			ExtraParams:  map[string]string{"a": "default", "b": "vms", "c": "vms"},
+		}},
+		{"server without profile for the flow", "vms", enums.ClientCredentialsFlow, TokenProfile{
+			Scopes:      []string{constants.ManagementServerScope},
+			AuthMethod:  ClientSecretBasic,
+			ExtraParams: map[string]string{},
+		}},
+		{"server of another flow", "other", enums.ClientCredentialsFlow, TokenProfile{
+			Scopes:      []string{"other"},
+			AuthMethod:  ClientSecretBasic,
+			ExtraParams: map[string]string{},
+		}},
+		{"built-in defaults", "other", enums.LoginForm, TokenProfile{
+			Scopes:      []string{"openid", "managementserver"},
+			ClientID:    "GrantValidatorClient",
+			AuthMethod:  ClientSecretPost,
+			Audiences:   []string{"default"},
+			ExtraParams: map[string]string{"a": "default", "b": "default"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile, err := profiles.Resolve(test.hostname, test.flow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*profile, test.expected) {
+				t.Fatalf("resolved %+v, expected %+v", *profile, test.expected)
+			}
+		})
+	}
+}
+
+// Only the client secret is left out of the fingerprint, every other field tells the profiles apart.
+func TestTokenProfileFingerprint(t *testing.T) {
+	base := &TokenProfile{
+		Scopes:       []string{"managementserver"},
+		ClientID:     "client",
+		ClientSecret: "secret",
+		AuthMethod:   ClientSecretPost,
+		Audiences:    []string{"vms"},
+		ExtraParams:  map[string]string{"a": "1", "b": "2"},
+	}
+
+	tests := []struct {
+		name     string
+		override *TokenProfile
+		same     bool
+	}{
+		{"client secret", &TokenProfile{ClientSecret: "rotated"}, true},
+		{"same extra parameter", &TokenProfile{ExtraParams: map[string]string{"b": "2"}}, true},
+		{"scopes", &TokenProfile{Scopes: []string{"managementserver", "openid"}}, false},
+		{"client id", &TokenProfile{ClientID: "other"}, false},
+		{"auth method", &TokenProfile{AuthMethod: ClientSecretBasic}, false},
+		{"audiences", &TokenProfile{Audiences: []string{"other"}}, false},
+		{"extra parameter value", &TokenProfile{ExtraParams: map[string]string{"a": "3"}}, false},
+		{"extra parameter added", &TokenProfile{ExtraParams: map[string]string{"c": "1"}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			same := base.Merge(test.override).Fingerprint() == base.Fingerprint()
+			if same != test.same {
+				t.Fatalf("same fingerprint %t, expected %t", same, test.same)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package vms
 
 import (
+	"log/slog"
+
 	"apigateway-webserver/src/pkg/constants/enums"
 )
 
@@ -29,3 +31,20 @@ func (u *User) Password() string {
 func (u *User) CredentialsFlowType() enums.CredentialsFlowType {
 	return u.credentialsFlowType
 }
+
+// Returns the identity the VMS grants permissions to: the client of the client credentials, shared by the sessions
+// logging in with them, or the user.
+func (u *User) Identity() string {
+	if u.credentialsFlowType == enums.ClientCredentialsFlow {
+		return "client:" + u.username
+	}
+	return "user:" + u.username
+}
+
+// Logs the user without the password.
+func (u *User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("username", u.username),
+		slog.String("credentialsFlowType", u.credentialsFlowType.String()),
+	)
+}
@@ -0,0 +1,37 @@
+package vms
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type UserDefinedEvent struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	LastModified string `json:"lastModified"`
+}
+
+type UserDefinedEvents struct {
+	Events []*UserDefinedEvent
+}
+
+func (udes *UserDefinedEvents) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(udes.Events)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal user-defined events: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+func NewUserDefinedEvents() *UserDefinedEvents {
+	return &UserDefinedEvents{
+		Events: []*UserDefinedEvent{},
+	}
+}
+
+func (udes *UserDefinedEvents) Add(e *UserDefinedEvent) {
+	if e != nil {
+		udes.Events = append(udes.Events, e)
+	}
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/events"
+	"apigateway-webserver/src/pkg/entities/vms"
+	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+	"apigateway-webserver/src/pkg/view"
+)
+
+// Handles the alarms page. The handler keeps no state and doesn't lock, the requests replacing the alarms session lock
+// the app context.
+type AlarmHandler struct{}
+
+func NewAlarmHandler() *AlarmHandler {
+	return &AlarmHandler{}
+}
+
+func (ah *AlarmHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "AlarmHandler.Handle() called")
+
+	queryParams := r.URL.Query()
+	username := queryParams.Get("username")
+	if username == "" {
+		http.Error(w, "Missing required fields: username.", http.StatusBadRequest)
+		return
+	}
+
+	path := "templates/alarms.html"
+	tmpl, err := view.ParseTemplate(path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Parsing template file %s: %v", path, err), http.StatusInternalServerError)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(username)
+	if !exists {
+		http.Error(w, "App context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureAlarms) {
+		return
+	}
+
+	// Alarm state names and their values, used to display and filter the alarm states
+	alarmStates := map[string]enums.AlarmState{}
+	for _, name := range enums.GetAlarmStates() {
+		alarmStates[name], _ = enums.ParseAlarmState(name)
+	}
+	alarmStatesJson, err := json.Marshal(alarmStates)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting alarm states to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// data to be passed to the template
+	pageData := struct {
+		AppName     string
+		AlarmStates string
+		Username    string
+	}{
+		AppName:     constants.AppName,
+		AlarmStates: string(alarmStatesJson),
+		Username:    username,
+	}
+	if err := executeTemplate(r, w, tmpl, path, pageData); err != nil {
+		http.Error(w, fmt.Sprintf("Executing template: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func (ah *AlarmHandler) RequestAlarmsHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "AlarmHandler.RequestAlarmsHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+		Filter   struct {
+			State    string `json:"state"`
+			Priority int    `json:"priority"`
+			Source   string `json:"source"`
+			From     string `json:"from"`
+			To       string `json:"to"`
+		} `json:"filter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" {
+		http.Error(w, "Missing required fields: username", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(data.Username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureAlarms) {
+		return
+	}
+
+	// Every filter field is optional
+	filter := &vms.AlarmFilter{
+		Priority: data.Filter.Priority,
+		Source:   data.Filter.Source,
+	}
+	var err error
+	if data.Filter.State != "" {
+		if filter.State, err = enums.ParseAlarmState(data.Filter.State); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid alarm state: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if data.Filter.From != "" {
+		if filter.From, err = time.Parse(time.RFC3339, data.Filter.From); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid from time: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if data.Filter.To != "" {
+		if filter.To, err = time.Parse(time.RFC3339, data.Filter.To); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid to time: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	alarms, err := appCtx.AlarmsService().RequestAlarms(r.Context(), appCtx.Server(), appCtx.Token(), filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Requesting alarms: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	alarmsJson, err := alarms.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting alarms to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(alarmsJson))
+}
+
+func (ah *AlarmHandler) RequestAlarmHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "AlarmHandler.RequestAlarmHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+		AlarmId  string `json:"alarmId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" || data.AlarmId == "" {
+		http.Error(w, "Missing required fields: Username or AlarmId.", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(data.Username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureAlarms) {
+		return
+	}
+
+	alarm, err := appCtx.AlarmsService().RequestAlarm(r.Context(), appCtx.Server(), appCtx.Token(), data.AlarmId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Requesting alarm: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	alarmJson, err := alarm.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting alarm to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(alarmJson))
+}
+
+func (ah *AlarmHandler) UpdateAlarmHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "AlarmHandler.UpdateAlarmHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+		AlarmId  string `json:"alarmId"`
+		// One of: acknowledge, assign, state
+		Action  string `json:"action"`
+		State   string `json:"state"`
+		Owner   string `json:"owner"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" || data.AlarmId == "" || data.Action == "" {
+		http.Error(w, "Missing required fields: Username, AlarmId or Action.", http.StatusBadRequest)
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, data.Username)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureAlarms) {
+		return
+	}
+
+	var alarm *vms.Alarm
+	switch data.Action {
+	case "acknowledge":
+		alarm, err = appCtx.AlarmsService().AcknowledgeAlarm(r.Context(), appCtx.Server(), appCtx.Token(), data.AlarmId, username)
+	case "assign":
+		if data.Owner == "" {
+			http.Error(w, "Missing required field: owner", http.StatusBadRequest)
+			return
+		}
+		alarm, err = appCtx.AlarmsService().AssignAlarm(r.Context(), appCtx.Server(), appCtx.Token(), data.AlarmId, data.Owner)
+	case "state":
+		state, parseErr := enums.ParseAlarmState(data.State)
+		if parseErr != nil {
+			http.Error(w, fmt.Sprintf("Invalid alarm state: %v", parseErr), http.StatusBadRequest)
+			return
+		}
+		alarm, err = appCtx.AlarmsService().ChangeAlarmState(r.Context(), appCtx.Server(), appCtx.Token(), data.AlarmId, state, data.Comment)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown action: %s", data.Action), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Updating alarm: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	alarmJson, err := alarm.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting alarm to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(alarmJson))
+}
+
+func (ah *AlarmHandler) StartSubscriptionHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "AlarmHandler.StartSubscriptionHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" {
+		http.Error(w, "Missing required fields: username", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(data.Username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureAlarms) {
+		return
+	}
+
+	appCtx.Lock()
+	defer appCtx.Unlock()
+
+	// Close existing WebSocket connection
+	if err := appCtx.WsAlarmsService().RequestClose(); err != nil {
+		http.Error(w, fmt.Sprintf("While closing the previous websocket connection: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Start new WebSocket connection
+	wsResponse, err := appCtx.WsAlarmsService().RequestStartSession(r.Context(), appCtx.Server(), appCtx.Token())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("While starting a new websocket connection: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe for all events of the alarm resource types
+	filters := &events.SubscriptionFilters{
+		Filters: []events.SubscriptionFilter{events.NewResourceTypesFilter(constants.AlarmsResourceType)},
+	}
+	if _, err := appCtx.WsAlarmsService().RequestSubscribeFilters(r.Context(), filters); err != nil {
+		http.Error(w, fmt.Sprintf("While creating a new subscription: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	sessionJson, err := wsResponse.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting session info to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(fmt.Sprintf("{ \"message\": \"Processing started\", \"session\": %s }", sessionJson)))
+}
+
+func (ah *AlarmHandler) RequestEventsHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "AlarmHandler.RequestEventsHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" {
+		http.Error(w, "Missing required fields: username", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(data.Username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	aes, err := appCtx.WsAlarmsService().RequestEvents(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Requesting alarm events: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	aesJson, err := aes.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting alarm events to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(aesJson))
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"apigateway-webserver/src/pkg/entities/api"
+	"apigateway-webserver/src/pkg/entities/vms"
+	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+	"apigateway-webserver/src/pkg/openapi"
+	"apigateway-webserver/src/pkg/services"
+)
+
+// Handles the versioned JSON API used by scripts, served under /api/v1. The user is named in the path, the site of the
+// session in the site query parameter or body field, the first site of the session when empty.
+// Errors are answered with the problem details of RFC 7807. The handler doesn't lock, like the pages the requests
+// replacing the events session of a site lock its app context.
+type ApiHandler struct {
+	document *openapi.Document
+	timeouts Timeouts
+}
+
+func NewApiHandler(document *openapi.Document, timeouts Timeouts) *ApiHandler {
+	return &ApiHandler{
+		document: document,
+		timeouts: timeouts,
+	}
+}
+
+// Returns the OpenAPI document of the API.
+func (ah *ApiHandler) OpenAPIHandle(w http.ResponseWriter, r *http.Request) {
+	documentJson, err := ah.document.ToJSON()
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(documentJson))
+}
+
+// Answers the paths of the API that don't exist.
+func (ah *ApiHandler) NotFoundHandle(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, "No API operation "+r.Method+" "+r.URL.Path)
+}
+
+// Logs the user in to a management server, and returns the session.
+func (ah *ApiHandler) CreateSessionHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ApiHandler.CreateSessionHandle() called")
+
+	var data api.LoginRequest
+	if err := decodeJSON(r, &data); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// The session is named after the user in the paths of the API
+	if data.Username == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Missing required field: username")
+		return
+	}
+
+	if err := login(r.Context(), ah.timeouts, &data, sessionKey(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	session, err := sessionOf(data.Username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	setSessionCookie(w, r, data.Username)
+	// Relative to the request path, which doesn't have the base path once it's stripped
+	w.Header().Set("Location", "sessions/"+url.PathEscape(data.Username))
+	writeJSON(w, r, http.StatusCreated, session)
+}
+
+func (ah *ApiHandler) GetSessionHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ApiHandler.GetSessionHandle() called")
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, r.PathValue("username"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	session, err := sessionOf(username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, session)
+}
+
+// Logs the user out of all sites of the session, closing their events sessions. The user of the session cookie is
+// checked to be an admin or the user of the session, see RequireRoleOrSelf.
+func (ah *ApiHandler) DeleteSessionHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ApiHandler.DeleteSessionHandle() called")
+
+	username := r.PathValue("username")
+	if !handlers_context.GetAppContextsInstance().CloseSession(username) {
+		writeProblem(w, r, http.StatusNotFound, "No session for user "+username)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns the cameras of a site of the session, from the configuration cache.
+func (ah *ApiHandler) ListCamerasHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ApiHandler.ListCamerasHandle() called")
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, r.PathValue("username"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	appCtx, err := siteContext(username, r.URL.Query().Get("site"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	cameras, err := withDeadline(r.Context(), ah.timeouts.List, "Reading the cameras", func(ctx context.Context) (*vms.CamerasList, error) {
+		return appCtx.ConfigCacheService().RequestCameras(ctx, appCtx.Server(), appCtx.Token())
+	})
+	if err != nil {
+		writeProblem(w, r, upstreamStatus(err), "Requesting cameras: "+err.Error())
+		return
+	}
+	writeJSON(w, r, http.StatusOK, cameras.Cameras)
+}
+
+// Returns the analytic event types of a site of the session, from the configuration cache.
+func (ah *ApiHandler) ListEventTypesHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ApiHandler.ListEventTypesHandle() called")
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, r.PathValue("username"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	appCtx, err := siteContext(username, r.URL.Query().Get("site"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	eventTypes, err := withDeadline(r.Context(), ah.timeouts.List, "Reading the analytic event types", func(ctx context.Context) (*vms.AnalyticEventTypes, error) {
+		return appCtx.ConfigCacheService().RequestAnalyticEventTypes(ctx, appCtx.Server(), appCtx.Token())
+	})
+	if err != nil {
+		writeProblem(w, r, upstreamStatus(err), "Requesting analytic event types: "+err.Error())
+		return
+	}
+	writeJSON(w, r, http.StatusOK, eventTypes.Types)
+}
+
+// Starts an events session on a site, replacing the previous subscription of the site.
+func (ah *ApiHandler) CreateSubscriptionHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ApiHandler.CreateSubscriptionHandle() called")
+
+	var data api.SubscriptionRequest
+	if err := decodeJSON(r, &data); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if (data.CameraId == "" && data.CameraGroupId == "") || data.EventTypeId == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Missing required fields: cameraId or cameraGroupId, and eventTypeId")
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, r.PathValue("username"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	appCtx, err := siteContext(username, data.Site)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	siteEvents, exists := handlers_context.GetAppContextsInstance().GetSiteEvents(username)
+	if !exists {
+		writeProblem(w, r, http.StatusNotFound, "No session for user "+username)
+		return
+	}
+
+	subscription, err := startSubscription(r.Context(), username, appCtx, siteEvents, &data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "subscriptions/"+url.PathEscape(subscription.Site))
+	writeJSON(w, r, http.StatusCreated, subscription)
+}
+
+// Stops the events session of a site. The other sites of the session keep sending their events.
+func (ah *ApiHandler) DeleteSubscriptionHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ApiHandler.DeleteSubscriptionHandle() called")
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, r.PathValue("username"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	appCtx, err := siteContext(username, r.PathValue("site"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	siteEvents, exists := handlers_context.GetAppContextsInstance().GetSiteEvents(username)
+	if !exists {
+		writeProblem(w, r, http.StatusNotFound, "No session for user "+username)
+		return
+	}
+
+	appCtx.Lock()
+	err = stopSubscription(appCtx, siteEvents)
+	appCtx.Unlock()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Waits for the events of the subscriptions of all sites of the session, and returns them once some arrived.
+// The subscriptions can be replaced while waiting.
+func (ah *ApiHandler) ListEventsHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ApiHandler.ListEventsHandle() called")
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, r.PathValue("username"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	siteEvents, exists := handlers_context.GetAppContextsInstance().GetSiteEvents(username)
+	if !exists {
+		writeProblem(w, r, http.StatusNotFound, "No session for user "+username)
+		return
+	}
+
+	aes, err := siteEvents.RequestEvents(r.Context())
+	if errors.Is(err, services.ErrNoEventsSession) {
+		writeProblem(w, r, http.StatusConflict, "No subscription was created, events can't be waited for")
+		return
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Requesting events: "+err.Error())
+		return
+	}
+	writeJSON(w, r, http.StatusOK, aes.Events)
+}
+
+// Triggers an event on a site of the session, e.g. a user-defined event.
+func (ah *ApiHandler) TriggerEventHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ApiHandler.TriggerEventHandle() called")
+
+	var data api.EventTriggerRequest
+	if err := decodeJSON(r, &data); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if data.EventTypeId == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Missing required field: eventTypeId")
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, r.PathValue("username"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	appCtx, err := siteContext(username, data.Site)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	event, err := triggerEvent(r.Context(), appCtx, &data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, event)
+}
+
+// Returns the session of the user with all its sites, or an error answered with status 404 when the user has none.
+func sessionOf(username string) (*api.Session, error) {
+	siteCtxs := handlers_context.GetAppContextsInstance().GetSiteContexts(username)
+	role, exists := handlers_context.GetAppContextsInstance().GetRole(username)
+	if len(siteCtxs) == 0 || !exists {
+		return nil, errorWithStatus(http.StatusNotFound, "No session for user %s", username)
+	}
+
+	session := api.NewSession(username, role)
+	for _, siteCtx := range siteCtxs {
+		session.Add(api.NewSessionSite(handlers_context.SiteName(siteCtx), siteCtx.Role(), siteCtx.Server(), siteCtx.Token()))
+	}
+	return session, nil
+}
+
+// Returns the context of a site of the session of the user, the first site when site is empty, or an error answered
+// with status 404 when the user has no session or the site isn't part of it.
+func siteContext(username, site string) (handlers_context.AppContext, error) {
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(username, site)
+	if exists {
+		return appCtx, nil
+	}
+	if _, exists := handlers_context.GetAppContextsInstance().GetAppContext(username); !exists {
+		return nil, errorWithStatus(http.StatusNotFound, "No session for user %s", username)
+	}
+	return nil, errorWithStatus(http.StatusNotFound, "Site %s is not part of the session of user %s", site, username)
+}
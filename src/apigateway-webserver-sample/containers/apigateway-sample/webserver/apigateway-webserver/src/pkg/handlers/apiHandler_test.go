@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+)
+
+func newTestApiMux() *http.ServeMux {
+	ah := NewApiHandler(nil, Timeouts{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/sessions", ah.CreateSessionHandle)
+	mux.HandleFunc("GET /api/v1/sessions/{username}", ah.RequireRole(enums.RoleViewer, ah.GetSessionHandle))
+	mux.HandleFunc("DELETE /api/v1/sessions/{username}", ah.RequireRoleOrSelf(enums.RoleAdmin, ah.DeleteSessionHandle))
+	mux.HandleFunc("GET /api/v1/sessions/{username}/cameras", ah.RequireRole(enums.RoleViewer, ah.ListCamerasHandle))
+	mux.HandleFunc("GET /api/v1/sessions/{username}/event-types", ah.RequireRole(enums.RoleViewer, ah.ListEventTypesHandle))
+	mux.HandleFunc("POST /api/v1/sessions/{username}/subscriptions", ah.RequireRole(enums.RoleViewer, ah.CreateSubscriptionHandle))
+	mux.HandleFunc("DELETE /api/v1/sessions/{username}/subscriptions/{site}", ah.RequireRole(enums.RoleViewer, ah.DeleteSubscriptionHandle))
+	mux.HandleFunc("GET /api/v1/sessions/{username}/events", ah.RequireRole(enums.RoleViewer, ah.ListEventsHandle))
+	return mux
+}
+
+// The operations on a session read and change it for the user of the session cookie only.
+func TestApiSessionsNeedTheCookieOfTheirUser(t *testing.T) {
+	hostname := setupLogins(t)
+	mux := newTestApiMux()
+
+	owner := post(mux, "/api/v1/sessions", nil, loginRequest("operator-api", hostname, false))
+	ownerCookie := sessionCookie(owner)
+	if owner.Code != http.StatusCreated || ownerCookie == nil {
+		t.Fatalf("login of the owner: %d %s", owner.Code, owner.Body)
+	}
+	other := post(mux, "/api/v1/sessions", nil, loginRequest("viewer-api", hostname, false))
+	otherCookie := sessionCookie(other)
+	if other.Code != http.StatusCreated || otherCookie == nil {
+		t.Fatalf("login of the other user: %d %s", other.Code, other.Body)
+	}
+
+	subscription := map[string]any{"cameraId": "cam-1", "eventTypeId": "aet-1"}
+	operations := []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodGet, "/api/v1/sessions/operator-api", nil},
+		{http.MethodGet, "/api/v1/sessions/operator-api/cameras", nil},
+		{http.MethodGet, "/api/v1/sessions/operator-api/event-types", nil},
+		{http.MethodPost, "/api/v1/sessions/operator-api/subscriptions", subscription},
+		{http.MethodDelete, "/api/v1/sessions/operator-api/subscriptions/" + hostname, nil},
+		{http.MethodGet, "/api/v1/sessions/operator-api/events", nil},
+		{http.MethodDelete, "/api/v1/sessions/operator-api", nil},
+	}
+	for _, op := range operations {
+		if response := send(mux, op.method, op.path, nil, op.body); response.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without cookie: %d %s", op.method, op.path, response.Code, response.Body)
+		}
+		if response := send(mux, op.method, op.path, otherCookie, op.body); response.Code != http.StatusForbidden {
+			t.Errorf("%s %s with the cookie of another user: %d %s", op.method, op.path, response.Code, response.Body)
+		}
+	}
+
+	if response := send(mux, http.MethodGet, "/api/v1/sessions/operator-api", ownerCookie, nil); response.Code != http.StatusOK {
+		t.Fatalf("reading the own session: %d %s", response.Code, response.Body)
+	}
+	if response := send(mux, http.MethodGet, "/api/v1/sessions/operator-api/event-types", ownerCookie, nil); response.Code != http.StatusOK {
+		t.Fatalf("listing the own event types: %d %s", response.Code, response.Body)
+	}
+	if response := send(mux, http.MethodDelete, "/api/v1/sessions/operator-api", ownerCookie, nil); response.Code != http.StatusNoContent {
+		t.Fatalf("deleting the own session: %d %s", response.Code, response.Body)
+	}
+}
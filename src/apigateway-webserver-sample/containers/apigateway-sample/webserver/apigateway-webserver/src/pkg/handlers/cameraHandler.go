@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+	"apigateway-webserver/src/pkg/view"
+)
+
+// Kinds of items an action can be run on.
+const (
+	cameraActionKindCamera    = "camera"
+	cameraActionKindOutput    = "output"
+	cameraActionKindPtzPreset = "ptzPreset"
+)
+
+// Handles the camera detail page and the actions run on cameras, their PTZ presets and outputs.
+// The handler keeps no state and doesn't lock.
+type CameraHandler struct{}
+
+func NewCameraHandler() *CameraHandler {
+	return &CameraHandler{}
+}
+
+func (ch *CameraHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "CameraHandler.Handle() called")
+
+	queryParams := r.URL.Query()
+	username := queryParams.Get("username")
+	cameraId := queryParams.Get("cameraId")
+	// Site of the camera, the first site of the session when empty
+	site := queryParams.Get("site")
+	if username == "" || cameraId == "" {
+		http.Error(w, "Missing required fields: username or cameraId.", http.StatusBadRequest)
+		return
+	}
+
+	path := "templates/camera.html"
+	tmpl, err := view.ParseTemplate(path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Parsing template file %s: %v", path, err), http.StatusInternalServerError)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(username, site)
+	if !exists {
+		http.Error(w, "App context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	// data to be passed to the template
+	pageData := struct {
+		AppName  string
+		Username string
+		CameraId string
+		Site     string
+	}{
+		AppName:  constants.AppName,
+		Username: username,
+		CameraId: cameraId,
+		Site:     handlers_context.SiteName(appCtx),
+	}
+	if err := executeTemplate(r, w, tmpl, path, pageData); err != nil {
+		http.Error(w, fmt.Sprintf("Executing template: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// Returns the camera with its tasks, PTZ presets and the outputs of its hardware.
+func (ch *CameraHandler) RequestCameraActionsHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "CameraHandler.RequestCameraActionsHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+		CameraId string `json:"cameraId"`
+		// Site of the camera, the first site of the session when empty
+		Site string `json:"site"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" || data.CameraId == "" {
+		http.Error(w, "Missing required fields: Username or CameraId.", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(data.Username, data.Site)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	actions, err := appCtx.CameraActionsService().RequestCameraActions(r.Context(), appCtx.Server(), appCtx.Token(), data.CameraId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Requesting camera actions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Show the recording server next to the hardware of the camera
+	cameras := vms.NewCamerasList()
+	cameras.Add(actions.Camera)
+	if err := appCtx.HierarchyService().LinkCameraParents(r.Context(), appCtx.Server(), appCtx.Token(), cameras); err != nil {
+		http.Error(w, fmt.Sprintf("Requesting camera parents: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	actionsJson, err := actions.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting camera actions to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(actionsJson))
+}
+
+// Runs a task on a camera or output, or activates a PTZ preset, and waits until it is done.
+func (ch *CameraHandler) RunActionHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "CameraHandler.RunActionHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+		// One of: camera, output, ptzPreset
+		Kind string `json:"kind"`
+		Id   string `json:"id"`
+		// Task to run, not used for PTZ presets
+		Task string `json:"task"`
+		// Optional task arguments, only used for camera tasks
+		Payload json.RawMessage `json:"payload"`
+		// Site of the camera or output, the first site of the session when empty
+		Site string `json:"site"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" || data.Kind == "" || data.Id == "" {
+		http.Error(w, "Missing required fields: Username, Kind or Id.", http.StatusBadRequest)
+		return
+	}
+	if data.Task == "" && data.Kind != cameraActionKindPtzPreset {
+		http.Error(w, "Missing required field: task", http.StatusBadRequest)
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, data.Username)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(username, data.Site)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	var result *vms.ConfigTaskResult
+	switch data.Kind {
+	case cameraActionKindCamera:
+		var payload any
+		if len(data.Payload) > 0 {
+			payload = data.Payload
+		}
+		result, err = appCtx.CameraActionsService().PerformCameraTask(r.Context(), appCtx.Server(), appCtx.Token(), data.Id, data.Task, payload)
+	case cameraActionKindOutput:
+		result, err = appCtx.CameraActionsService().PerformOutputTask(r.Context(), appCtx.Server(), appCtx.Token(), data.Id, data.Task)
+	case cameraActionKindPtzPreset:
+		result, err = appCtx.CameraActionsService().ActivatePtzPreset(r.Context(), appCtx.Server(), appCtx.Token(), data.Id)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown action kind: %s", data.Kind), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Running %s action: %v", data.Kind, err), http.StatusInternalServerError)
+		return
+	}
+
+	resultJson, err := result.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting task result to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(resultJson))
+}
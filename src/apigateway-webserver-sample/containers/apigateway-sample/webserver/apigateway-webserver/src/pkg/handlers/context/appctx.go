@@ -1,6 +1,9 @@
 package context
 
 import (
+	"sync"
+
+	"apigateway-webserver/src/pkg/constants/enums"
 	"apigateway-webserver/src/pkg/entities/events"
 	"apigateway-webserver/src/pkg/entities/vms"
 	"apigateway-webserver/src/pkg/services"
@@ -10,24 +13,56 @@ type AppContext interface {
 	IdpService() services.IdpService
 	GatewayService() services.GatewayService
 	WsEventsService() services.WsEventsService
+	EventsRestService() services.EventsRestService
+	AlarmsService() services.AlarmsService
+	// Events WebSocket session dedicated to the alarms page, independent from the events page session
+	WsAlarmsService() services.WsEventsService
+	HierarchyService() services.HierarchyService
+	// Follows the members of the camera group subscribed through the events page session
+	CameraGroupSubscriptionService() services.CameraGroupSubscriptionService
+	CameraActionsService() services.CameraActionsService
+	// Cameras and analytic event types cached on disk, refreshed in the background
+	ConfigCacheService() services.ConfigCacheService
 
 	Server() *vms.Server
 	User() *vms.User
 	Token() vms.Token
+	// Role of the user on the server, found at login
+	Role() enums.Role
 
 	SetWsCommandResponse(wsCommandResponse *events.WsCommandResponse)
 	GetWsCommandResponse() *events.WsCommandResponse
+
+	// Serializes the requests starting and stopping the events sessions of the context, e.g. the same user subscribing
+	// from two pages at once. The requests of other users and other sites don't wait.
+	Lock()
+	Unlock()
+
+	// Stops the background work of the context and closes its events sessions, once it is no longer used.
+	Close()
 }
 
 type appContext struct {
-	idpService      services.IdpService
-	gatewayService  services.GatewayService
-	wsEventsService services.WsEventsService
+	idpService                     services.IdpService
+	gatewayService                 services.GatewayService
+	wsEventsService                services.WsEventsService
+	eventsRestService              services.EventsRestService
+	alarmsService                  services.AlarmsService
+	wsAlarmsService                services.WsEventsService
+	hierarchyService               services.HierarchyService
+	cameraGroupSubscriptionService services.CameraGroupSubscriptionService
+	cameraActionsService           services.CameraActionsService
+	configCacheService             services.ConfigCacheService
 
 	server *vms.Server
 	user   *vms.User
 	token  vms.Token
+	role   enums.Role
+
+	// Held while the events sessions are replaced, see Lock
+	sessionsMu sync.Mutex
 
+	mu                sync.Mutex
 	wsCommandResponse *events.WsCommandResponse
 }
 
@@ -35,16 +70,32 @@ func NewAppContext(
 	idpService services.IdpService,
 	gatewayService services.GatewayService,
 	wsEventsService services.WsEventsService,
+	eventsRestService services.EventsRestService,
+	alarmsService services.AlarmsService,
+	wsAlarmsService services.WsEventsService,
+	hierarchyService services.HierarchyService,
+	cameraGroupSubscriptionService services.CameraGroupSubscriptionService,
+	cameraActionsService services.CameraActionsService,
+	configCacheService services.ConfigCacheService,
 	server *vms.Server,
 	user *vms.User,
-	token vms.Token) AppContext {
+	token vms.Token,
+	role enums.Role) AppContext {
 	return &appContext{
-		idpService:      idpService,
-		gatewayService:  gatewayService,
-		wsEventsService: wsEventsService,
-		server:          server,
-		user:            user,
-		token:           token,
+		idpService:                     idpService,
+		gatewayService:                 gatewayService,
+		wsEventsService:                wsEventsService,
+		eventsRestService:              eventsRestService,
+		alarmsService:                  alarmsService,
+		wsAlarmsService:                wsAlarmsService,
+		hierarchyService:               hierarchyService,
+		cameraGroupSubscriptionService: cameraGroupSubscriptionService,
+		cameraActionsService:           cameraActionsService,
+		configCacheService:             configCacheService,
+		server:                         server,
+		user:                           user,
+		token:                          token,
+		role:                           role,
 	}
 }
 
@@ -60,6 +111,34 @@ func (a *appContext) WsEventsService() services.WsEventsService {
 	return a.wsEventsService
 }
 
+func (a *appContext) EventsRestService() services.EventsRestService {
+	return a.eventsRestService
+}
+
+func (a *appContext) AlarmsService() services.AlarmsService {
+	return a.alarmsService
+}
+
+func (a *appContext) WsAlarmsService() services.WsEventsService {
+	return a.wsAlarmsService
+}
+
+func (a *appContext) HierarchyService() services.HierarchyService {
+	return a.hierarchyService
+}
+
+func (a *appContext) CameraGroupSubscriptionService() services.CameraGroupSubscriptionService {
+	return a.cameraGroupSubscriptionService
+}
+
+func (a *appContext) CameraActionsService() services.CameraActionsService {
+	return a.cameraActionsService
+}
+
+func (a *appContext) ConfigCacheService() services.ConfigCacheService {
+	return a.configCacheService
+}
+
 func (a *appContext) Server() *vms.Server {
 	return a.server
 }
@@ -72,13 +151,37 @@ func (a *appContext) Token() vms.Token {
 	return a.token
 }
 
+func (a *appContext) Role() enums.Role {
+	return a.role
+}
+
 func (a *appContext) SetWsCommandResponse(wsCommandResponse *events.WsCommandResponse) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.wsCommandResponse = wsCommandResponse
 }
 
 func (a *appContext) GetWsCommandResponse() *events.WsCommandResponse {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	if a.wsCommandResponse == nil {
 		return &events.WsCommandResponse{}
 	}
 	return a.wsCommandResponse
 }
+
+func (a *appContext) Lock() {
+	a.sessionsMu.Lock()
+}
+
+func (a *appContext) Unlock() {
+	a.sessionsMu.Unlock()
+}
+
+func (a *appContext) Close() {
+	a.configCacheService.Stop()
+	a.cameraGroupSubscriptionService.Stop()
+	a.wsEventsService.RequestClose()
+	a.wsAlarmsService.RequestClose()
+	a.idpService.ReleaseAccessToken(a.token)
+}
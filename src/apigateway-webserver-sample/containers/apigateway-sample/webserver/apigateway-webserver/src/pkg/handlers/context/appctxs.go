@@ -1,17 +1,60 @@
 package context
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"sync"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/metrics"
+	"apigateway-webserver/src/pkg/services"
 )
 
+// Holds the contexts of the sites a user is logged in to, and keeps the events of all sites together.
 type AppContexts interface {
-	AddAppContext(username string, ac AppContext)
+	// Replaces the session of the user with a session on the given site only. Returns the contexts of the replaced session.
+	AddAppContext(username string, ac AppContext) []AppContext
+	// Adds a site to the session of the user, or starts a session when the user has none. Only the holder of the key of
+	// the session can add a site to it: with another key, ErrNotSessionOwner is returned and the session is left as is.
+	// Returns the previous context of the same site, if any.
+	AddSiteContext(username string, key string, ac AppContext) (AppContext, bool, error)
+	// Returns the context of the first site the user logged in to.
 	GetAppContext(username string) (AppContext, bool)
+	// Returns the context of the site with the given hostname, or the first site when site is empty.
+	GetSiteContext(username string, site string) (AppContext, bool)
+	// Returns the contexts of all sites of the user, in login order.
+	GetSiteContexts(username string) []AppContext
+	// Returns the events of all sites of the user merged together.
+	GetSiteEvents(username string) (services.SiteEventsService, bool)
+	// Returns the key of the session of the user, given to the browser or script that logged in as a cookie. A session
+	// replacing another gets a new key, adding a site keeps it.
+	GetSessionKey(username string) (string, bool)
+	// Returns the user whose session has the given key, false when no session has it.
+	Authenticate(key string) (string, bool)
+	// Returns the role of the user in the session: the lowest role of its sites, so that the actions it allows are allowed
+	// on every site.
+	GetRole(username string) (enums.Role, bool)
+	// Removes the session of the user and closes the contexts of all its sites. Returns false when the user has no session.
+	CloseSession(username string) bool
+	// Removes the sessions of all users and closes all their contexts, when the webserver stops.
+	CloseAll()
+}
+
+// Returned when adding a site to the session of a user without the key of the session.
+var ErrNotSessionOwner = errors.New("the session key isn't the key of the session of the user")
+
+type userSession struct {
+	key        string
+	sites      []AppContext
+	siteEvents services.SiteEventsService
 }
 
 type appContexts struct {
-	ctxs map[string]AppContext
-	mu   sync.Mutex
+	sessions map[string]*userSession
+	// Users by session key
+	users map[string]string
+	mu    sync.Mutex
 }
 
 var (
@@ -21,22 +64,202 @@ var (
 
 func GetAppContextsInstance() AppContexts {
 	once.Do(func() {
-		instance = &appContexts{
-			ctxs: make(map[string]AppContext),
+		acs := &appContexts{
+			sessions: make(map[string]*userSession),
+			users:    make(map[string]string),
 		}
+		metrics.RegisterAppContexts(acs.count)
+		instance = acs
 	})
 	return instance
 }
 
-func (acs *appContexts) AddAppContext(username string, ac AppContext) {
+// Returns the name used to tell the sites of a session apart.
+func SiteName(ac AppContext) string {
+	return ac.Server().Hostname()
+}
+
+// Returns a new random session key.
+func newSessionKey() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		// The random source of the system doesn't fail, a session key that can be guessed is worse than stopping
+		panic("reading a random session key: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Starts a session of the user on a single site. Called with the lock held.
+func (acs *appContexts) newSession(username string, ac AppContext) {
+	session := &userSession{
+		key:        newSessionKey(),
+		sites:      []AppContext{ac},
+		siteEvents: services.NewSiteEventsService(),
+	}
+	acs.sessions[username] = session
+	acs.users[session.key] = username
+}
+
+func (acs *appContexts) AddAppContext(username string, ac AppContext) []AppContext {
 	acs.mu.Lock()
-	defer acs.mu.Unlock()
-	acs.ctxs[username] = ac
+	previous, exists := acs.sessions[username]
+	if exists {
+		delete(acs.users, previous.key)
+	}
+	acs.newSession(username, ac)
+	acs.mu.Unlock()
+
+	if !exists {
+		return nil
+	}
+	previous.siteEvents.Stop()
+	return previous.sites
+}
+
+func (acs *appContexts) AddSiteContext(username string, key string, ac AppContext) (AppContext, bool, error) {
+	acs.mu.Lock()
+	session, exists := acs.sessions[username]
+	if !exists {
+		acs.newSession(username, ac)
+		acs.mu.Unlock()
+		return nil, false, nil
+	}
+	if key != session.key {
+		acs.mu.Unlock()
+		return nil, false, ErrNotSessionOwner
+	}
+
+	site := SiteName(ac)
+	for i, sac := range session.sites {
+		if SiteName(sac) == site {
+			session.sites[i] = ac
+			acs.mu.Unlock()
+			session.siteEvents.Unfollow(site)
+			return sac, true, nil
+		}
+	}
+	session.sites = append(session.sites, ac)
+	acs.mu.Unlock()
+	return nil, false, nil
 }
 
 func (acs *appContexts) GetAppContext(username string) (AppContext, bool) {
+	return acs.GetSiteContext(username, "")
+}
+
+func (acs *appContexts) GetSiteContext(username string, site string) (AppContext, bool) {
 	acs.mu.Lock()
 	defer acs.mu.Unlock()
-	ac, ok := acs.ctxs[username]
-	return ac, ok
+	session, ok := acs.sessions[username]
+	if !ok {
+		return nil, false
+	}
+	if site == "" {
+		return session.sites[0], true
+	}
+	for _, ac := range session.sites {
+		if SiteName(ac) == site {
+			return ac, true
+		}
+	}
+	return nil, false
+}
+
+func (acs *appContexts) GetSiteContexts(username string) []AppContext {
+	acs.mu.Lock()
+	defer acs.mu.Unlock()
+	session, ok := acs.sessions[username]
+	if !ok {
+		return nil
+	}
+	return append([]AppContext{}, session.sites...)
+}
+
+func (acs *appContexts) GetSiteEvents(username string) (services.SiteEventsService, bool) {
+	acs.mu.Lock()
+	defer acs.mu.Unlock()
+	session, ok := acs.sessions[username]
+	if !ok {
+		return nil, false
+	}
+	return session.siteEvents, true
+}
+
+func (acs *appContexts) GetSessionKey(username string) (string, bool) {
+	acs.mu.Lock()
+	defer acs.mu.Unlock()
+	session, ok := acs.sessions[username]
+	if !ok {
+		return "", false
+	}
+	return session.key, true
+}
+
+func (acs *appContexts) Authenticate(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	acs.mu.Lock()
+	defer acs.mu.Unlock()
+	username, ok := acs.users[key]
+	return username, ok
+}
+
+func (acs *appContexts) GetRole(username string) (enums.Role, bool) {
+	acs.mu.Lock()
+	defer acs.mu.Unlock()
+	session, ok := acs.sessions[username]
+	if !ok {
+		return 0, false
+	}
+	role := session.sites[0].Role()
+	for _, ac := range session.sites[1:] {
+		role = min(role, ac.Role())
+	}
+	return role, true
+}
+
+// Returns the number of contexts of all sessions.
+func (acs *appContexts) count() int {
+	acs.mu.Lock()
+	defer acs.mu.Unlock()
+	count := 0
+	for _, session := range acs.sessions {
+		count += len(session.sites)
+	}
+	return count
+}
+
+func (acs *appContexts) CloseSession(username string) bool {
+	acs.mu.Lock()
+	session, ok := acs.sessions[username]
+	if ok {
+		delete(acs.sessions, username)
+		delete(acs.users, session.key)
+	}
+	acs.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	session.siteEvents.Stop()
+	for _, ac := range session.sites {
+		ac.Close()
+	}
+	return true
+}
+
+func (acs *appContexts) CloseAll() {
+	acs.mu.Lock()
+	sessions := acs.sessions
+	acs.sessions = make(map[string]*userSession)
+	acs.users = make(map[string]string)
+	acs.mu.Unlock()
+
+	for _, session := range sessions {
+		session.siteEvents.Stop()
+		for _, ac := range session.sites {
+			ac.Close()
+		}
+	}
 }
@@ -0,0 +1,192 @@
+package context
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// Context of a site answering only what the sessions use, counting its closes.
+type fakeAppContext struct {
+	AppContext
+	server *vms.Server
+	role   enums.Role
+	closed atomic.Int32
+}
+
+func newFakeAppContext(hostname string, role enums.Role) *fakeAppContext {
+	return &fakeAppContext{
+		server: vms.NewServer(&url.URL{Scheme: "http", Host: hostname}),
+		role:   role,
+	}
+}
+
+func (fac *fakeAppContext) Server() *vms.Server { return fac.server }
+func (fac *fakeAppContext) Role() enums.Role    { return fac.role }
+func (fac *fakeAppContext) Close()              { fac.closed.Add(1) }
+
+func newTestAppContexts() *appContexts {
+	return &appContexts{
+		sessions: make(map[string]*userSession),
+		users:    make(map[string]string),
+	}
+}
+
+// Many users log in, add sites, read and log out at the same time. Run with -race.
+func TestAppContextsConcurrentUsers(t *testing.T) {
+	acs := newTestAppContexts()
+	const users, rounds = 20, 50
+
+	var created []*fakeAppContext
+	var createdMu sync.Mutex
+	newContext := func(hostname string, role enums.Role) *fakeAppContext {
+		ac := newFakeAppContext(hostname, role)
+		createdMu.Lock()
+		created = append(created, ac)
+		createdMu.Unlock()
+		return ac
+	}
+
+	var wg sync.WaitGroup
+	for u := range users {
+		username := fmt.Sprintf("user%d", u)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range rounds {
+				for _, previous := range acs.AddAppContext(username, newContext("vms1", enums.RoleAdmin)) {
+					previous.Close()
+				}
+				key, _ := acs.GetSessionKey(username)
+				previous, replaced, err := acs.AddSiteContext(username, key, newContext(fmt.Sprintf("vms%d", i%3+2), enums.RoleViewer))
+				if err != nil {
+					t.Errorf("%s can't add a site with its own key: %v", username, err)
+				}
+				if replaced {
+					previous.Close()
+				}
+
+				if _, ok := acs.GetAppContext(username); !ok {
+					t.Errorf("%s has no session after logging in", username)
+				}
+				if role, ok := acs.GetRole(username); !ok || role != enums.RoleViewer {
+					t.Errorf("%s has the role %v, expected the lowest role of its sites", username, role)
+				}
+				key, ok := acs.GetSessionKey(username)
+				if !ok {
+					t.Errorf("%s has no session key", username)
+				}
+				if owner, ok := acs.Authenticate(key); !ok || owner != username {
+					t.Errorf("the session key of %s authenticates %q", username, owner)
+				}
+				acs.GetSiteContexts(username)
+				acs.GetSiteEvents(username)
+				acs.count()
+
+				if i%10 == 9 {
+					acs.CloseSession(username)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	acs.CloseAll()
+	if count := acs.count(); count != 0 {
+		t.Errorf("%d contexts left after CloseAll", count)
+	}
+	for _, ac := range created {
+		if closed := ac.closed.Load(); closed != 1 {
+			t.Fatalf("context of %s closed %d times, expected once", ac.server.Hostname(), closed)
+		}
+	}
+}
+
+// Users reading the sessions of others while they log in and out never see a half-replaced session.
+func TestAppContextsReadersDuringLogins(t *testing.T) {
+	acs := newTestAppContexts()
+	stop := make(chan struct{})
+
+	var readers sync.WaitGroup
+	for range 8 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, ac := range acs.GetSiteContexts("shared") {
+					if ac == nil {
+						t.Error("nil context in a session")
+					}
+				}
+				if _, ok := acs.Authenticate("not a session key"); ok {
+					t.Error("an unknown key authenticated a user")
+				}
+			}
+		}()
+	}
+
+	var oldKeys []string
+	for range 200 {
+		acs.AddAppContext("shared", newFakeAppContext("vms", enums.RoleOperator))
+		key, _ := acs.GetSessionKey("shared")
+		oldKeys = append(oldKeys, key)
+	}
+	close(stop)
+	readers.Wait()
+
+	// Only the key of the last login is valid
+	for _, key := range oldKeys[:len(oldKeys)-1] {
+		if _, ok := acs.Authenticate(key); ok {
+			t.Fatal("the key of a replaced session still authenticates")
+		}
+	}
+	if !acs.CloseSession("shared") {
+		t.Fatal("the session of shared wasn't found")
+	}
+	if _, ok := acs.Authenticate(oldKeys[len(oldKeys)-1]); ok {
+		t.Fatal("the key of a closed session still authenticates")
+	}
+}
+
+// Only the holder of the key of a session adds sites to it, others can't join the session by naming its user.
+func TestAppContextsAddSiteNeedsSessionKey(t *testing.T) {
+	acs := newTestAppContexts()
+	first := newFakeAppContext("vms1", enums.RoleAdmin)
+	acs.AddAppContext("admin", first)
+	key, _ := acs.GetSessionKey("admin")
+
+	for _, wrongKey := range []string{"", "guessed"} {
+		intruder := newFakeAppContext("evil", enums.RoleAdmin)
+		if _, _, err := acs.AddSiteContext("admin", wrongKey, intruder); err != ErrNotSessionOwner {
+			t.Fatalf("adding a site with the key %q: %v, expected ErrNotSessionOwner", wrongKey, err)
+		}
+	}
+	if sites := acs.GetSiteContexts("admin"); len(sites) != 1 || sites[0] != first {
+		t.Fatalf("the session has %d sites after the refused additions", len(sites))
+	}
+
+	if _, _, err := acs.AddSiteContext("admin", key, newFakeAppContext("vms2", enums.RoleAdmin)); err != nil {
+		t.Fatal(err)
+	}
+	if sameKey, _ := acs.GetSessionKey("admin"); sameKey != key {
+		t.Fatal("adding a site changed the session key")
+	}
+
+	// Users without a session start one with a new key
+	if _, _, err := acs.AddSiteContext("operator", "", newFakeAppContext("vms1", enums.RoleOperator)); err != nil {
+		t.Fatal(err)
+	}
+	if operatorKey, ok := acs.GetSessionKey("operator"); !ok || operatorKey == key {
+		t.Fatal("the new session didn't get its own key")
+	}
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+	"apigateway-webserver/src/pkg/services"
+	"apigateway-webserver/src/pkg/view"
+)
+
+// Handles the admin page managing the analytic event types.
+// Every request reads or writes the VMS directly, so the handler keeps no state and doesn't lock.
+type EventTypesHandler struct{}
+
+func NewEventTypesHandler() *EventTypesHandler {
+	return &EventTypesHandler{}
+}
+
+// Returns the status code for an error of the event types service.
+func eventTypeErrorStatus(err error) int {
+	if errors.Is(err, services.ErrAnalyticEventTypeModified) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// Refreshes the configuration cache, so the events page shows the changed event types right away.
+func refreshConfigCache(r *http.Request, appCtx handlers_context.AppContext) {
+	if _, err := appCtx.ConfigCacheService().Refresh(r.Context(), appCtx.Server(), appCtx.Token()); err != nil {
+		logger.WarnContext(r.Context(), "Refreshing the configuration cache", "site", handlers_context.SiteName(appCtx), "error", err)
+	}
+}
+
+func (eth *EventTypesHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "EventTypesHandler.Handle() called")
+
+	queryParams := r.URL.Query()
+	username := queryParams.Get("username")
+	if username == "" {
+		http.Error(w, "Missing required fields: username.", http.StatusBadRequest)
+		return
+	}
+
+	path := "templates/event_types.html"
+	tmpl, err := view.ParseTemplate(path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Parsing template file %s: %v", path, err), http.StatusInternalServerError)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(username)
+	if !exists {
+		http.Error(w, "App context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	// data to be passed to the template
+	pageData := struct {
+		AppName        string
+		Username       string
+		CatalogVersion int
+	}{
+		AppName:        constants.AppName,
+		Username:       username,
+		CatalogVersion: vms.AnalyticEventCatalogVersion,
+	}
+	if err := executeTemplate(r, w, tmpl, path, pageData); err != nil {
+		http.Error(w, fmt.Sprintf("Executing template: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func (eth *EventTypesHandler) RequestEventTypesHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "EventTypesHandler.RequestEventTypesHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" {
+		http.Error(w, "Missing required fields: username", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(data.Username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	eventTypes, err := appCtx.GatewayService().RequestAnalyticEventTypes(r.Context(), appCtx.Server(), appCtx.Token())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Requesting event types: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	eventTypesJson, err := eventTypes.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting event types to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(eventTypesJson))
+}
+
+func (eth *EventTypesHandler) CreateEventTypeHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "EventTypesHandler.CreateEventTypeHandle() called")
+
+	var data struct {
+		Username  string                          `json:"username"`
+		EventType vms.AnalyticEventTypeDefinition `json:"eventType"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" {
+		http.Error(w, "Missing required fields: username", http.StatusBadRequest)
+		return
+	}
+	if err := data.EventType.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid event type: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, data.Username)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	eventType, err := appCtx.GatewayService().CreateAnalyticEventType(r.Context(), appCtx.Server(), appCtx.Token(), &data.EventType)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Creating event type: %v", err), http.StatusInternalServerError)
+		return
+	}
+	refreshConfigCache(r, appCtx)
+
+	eventTypeJson, err := eventType.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting event type to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(eventTypeJson))
+}
+
+func (eth *EventTypesHandler) UpdateEventTypeHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "EventTypesHandler.UpdateEventTypeHandle() called")
+
+	var data struct {
+		Username     string                          `json:"username"`
+		EventTypeId  string                          `json:"eventTypeId"`
+		LastModified string                          `json:"lastModified"`
+		EventType    vms.AnalyticEventTypeDefinition `json:"eventType"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" || data.EventTypeId == "" {
+		http.Error(w, "Missing required fields: Username or EventTypeId.", http.StatusBadRequest)
+		return
+	}
+	if err := data.EventType.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid event type: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, data.Username)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	eventType, err := appCtx.GatewayService().UpdateAnalyticEventType(r.Context(), appCtx.Server(), appCtx.Token(), data.EventTypeId, data.LastModified, &data.EventType)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Updating event type: %v", err), eventTypeErrorStatus(err))
+		return
+	}
+	refreshConfigCache(r, appCtx)
+
+	eventTypeJson, err := eventType.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting event type to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(eventTypeJson))
+}
+
+func (eth *EventTypesHandler) DeleteEventTypeHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "EventTypesHandler.DeleteEventTypeHandle() called")
+
+	var data struct {
+		Username     string `json:"username"`
+		EventTypeId  string `json:"eventTypeId"`
+		LastModified string `json:"lastModified"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" || data.EventTypeId == "" {
+		http.Error(w, "Missing required fields: Username or EventTypeId.", http.StatusBadRequest)
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, data.Username)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	if err := appCtx.GatewayService().DeleteAnalyticEventType(r.Context(), appCtx.Server(), appCtx.Token(), data.EventTypeId, data.LastModified); err != nil {
+		http.Error(w, fmt.Sprintf("Deleting event type: %v", err), eventTypeErrorStatus(err))
+		return
+	}
+	refreshConfigCache(r, appCtx)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("{ \"message\": \"Event type deleted\" }"))
+}
+
+func (eth *EventTypesHandler) ExportEventTypesHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "EventTypesHandler.ExportEventTypesHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" {
+		http.Error(w, "Missing required fields: username", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(data.Username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	catalog, err := appCtx.GatewayService().ExportAnalyticEventTypes(r.Context(), appCtx.Server(), appCtx.Token())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Exporting event types: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	catalogJson, err := catalog.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting event types catalog to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(catalogJson))
+}
+
+func (eth *EventTypesHandler) ImportEventTypesHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "EventTypesHandler.ImportEventTypesHandle() called")
+
+	var data struct {
+		Username string                   `json:"username"`
+		Catalog  vms.AnalyticEventCatalog `json:"catalog"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" {
+		http.Error(w, "Missing required fields: username", http.StatusBadRequest)
+		return
+	}
+	if err := data.Catalog.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid event types catalog: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, data.Username)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	imported, err := appCtx.GatewayService().ImportAnalyticEventTypes(r.Context(), appCtx.Server(), appCtx.Token(), &data.Catalog)
+	// Part of the catalog may have been imported even on failure
+	refreshConfigCache(r, appCtx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Importing event types (%d created): %v", len(imported.Created), err), http.StatusInternalServerError)
+		return
+	}
+
+	importedJson, err := imported.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting event types to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(importedJson))
+}
@@ -1,84 +1,189 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"sync"
+	"strings"
+	"time"
 
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/api"
+	"apigateway-webserver/src/pkg/entities/events"
+	"apigateway-webserver/src/pkg/entities/vms"
 	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+	"apigateway-webserver/src/pkg/services"
 )
 
-type EventHandler struct {
-	mu sync.Mutex
-}
+// Time recorded before and after an event when bookmarking it, unless the request gives another window.
+const defaultBookmarkWindow = 10 * time.Second
+
+// Handles the events of the events page. The handler keeps no state and doesn't lock, the requests replacing the events
+// session of a site lock its app context.
+type EventHandler struct{}
 
 func NewEventHandler() *EventHandler {
 	return &EventHandler{}
 }
 
 func (eh *EventHandler) StartSubscriptionHandle(w http.ResponseWriter, r *http.Request) {
-	eh.mu.Lock()
-	defer eh.mu.Unlock()
-	log.Println("EventHandler.StartSubscriptionHandle() called")
+	logger.DebugContext(r.Context(), "EventHandler.StartSubscriptionHandle() called")
 
 	var data struct {
-		Username    string `json:"username"`
-		CameraId    string `json:"cameraId"`
-		EventTypeId string `json:"eventTypeId"`
+		Username string `json:"username"`
+		api.SubscriptionRequest
 	}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	if data.Username == "" || data.CameraId == "" || data.EventTypeId == "" {
-		http.Error(w, "Missing required fields: Username, CameraId or EventTypeId.", http.StatusBadRequest)
+	if data.Username == "" || (data.CameraId == "" && data.CameraGroupId == "") || data.EventTypeId == "" {
+		http.Error(w, "Missing required fields: Username, CameraId or CameraGroupId, or EventTypeId.", http.StatusBadRequest)
 		return
 	}
 
-	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(data.Username)
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(data.Username, data.Site)
 	if !exists {
 		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
 		return
 	}
-
-	// Close existing WebSocket connection
-	if err := appCtx.WsEventsService().RequestClose(); err != nil {
-		http.Error(w, fmt.Sprintf("While closing the previous websocket connection: %v", err), http.StatusInternalServerError)
+	siteEvents, exists := handlers_context.GetAppContextsInstance().GetSiteEvents(data.Username)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
 		return
 	}
 
-	// Start new WebSocket connection
-	wsResponse, err := appCtx.WsEventsService().RequestStartSession(r.Context(), appCtx.Server(), appCtx.Token())
+	subscription, err := startSubscription(r.Context(), data.Username, appCtx, siteEvents, &data.SubscriptionRequest)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("While starting a new websocket connection: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), statusOf(err))
 		return
 	}
 
-	appCtx.SetWsCommandResponse(wsResponse)
+	sessionJson, err := subscription.Session.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting session info to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
 
-	// Subscribe for events filtered by type and source
-	if _, err := appCtx.WsEventsService().RequestSubscribe(r.Context(), data.CameraId, data.EventTypeId); err != nil {
-		http.Error(w, fmt.Sprintf("While creating a new subscription: %v", err), http.StatusInternalServerError)
+	if data.CameraId != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf("{ \"message\": \"Processing started\", \"site\": %q, \"session\": %s }", subscription.Site, sessionJson)))
 		return
 	}
 
-	sessionJson, err := wsResponse.ToJSON()
+	cameras := &vms.CamerasList{Cameras: subscription.GroupCameras}
+	camerasJson, err := cameras.ToJSON()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Converting session info to JSON: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Converting cameras list to JSON: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(fmt.Sprintf("{ \"message\": \"Processing started\", \"session\": %s }", sessionJson)))
+	w.Write([]byte(fmt.Sprintf("{ \"message\": \"Processing started\", \"site\": %q, \"session\": %s, \"groupCameras\": %s }", subscription.Site, sessionJson, camerasJson)))
+}
+
+// Starts an events session on the site of appCtx, replacing the previous subscription of the site, and subscribes to the
+// events of the camera or camera group of the request. The events are merged into the site events of the user.
+// The app context is locked while its session is replaced.
+func startSubscription(ctx context.Context, username string, appCtx handlers_context.AppContext, siteEvents services.SiteEventsService, data *api.SubscriptionRequest) (*api.Subscription, error) {
+	site := handlers_context.SiteName(appCtx)
+
+	if err := checkFeature(appCtx, vms.FeatureEventsWebsocket); err != nil {
+		return nil, err
+	}
+
+	eventTypeId, err := siteEventTypeId(ctx, username, data.EventTypeSite, data.EventTypeId, appCtx)
+	if err != nil {
+		return nil, errorWithStatus(http.StatusBadRequest, "Finding the event type on site %s: %w", site, err)
+	}
+
+	appCtx.Lock()
+	defer appCtx.Unlock()
+
+	// Only the session of this site is replaced, the subscriptions made on the other sites of the session keep running.
+	// Stop following the previous camera group, then close existing WebSocket connection
+	if err := stopSubscription(appCtx, siteEvents); err != nil {
+		return nil, err
+	}
+
+	// Start new WebSocket connection
+	wsResponse, err := appCtx.WsEventsService().RequestStartSession(ctx, appCtx.Server(), appCtx.Token())
+	if err != nil {
+		return nil, fmt.Errorf("While starting a new websocket connection: %w", err)
+	}
+
+	appCtx.SetWsCommandResponse(wsResponse)
+	subscription := &api.Subscription{Site: site, Session: wsResponse}
+
+	// Subscribe for events filtered by type and source
+	if data.CameraId != "" {
+		if _, err := appCtx.WsEventsService().RequestSubscribe(ctx, data.CameraId, eventTypeId); err != nil {
+			return nil, fmt.Errorf("While creating a new subscription: %w", err)
+		}
+		siteEvents.Follow(site, appCtx.WsEventsService())
+		return subscription, nil
+	}
+
+	// Subscribe for events filtered by type and coming from any camera of the group
+	cameras, err := appCtx.CameraGroupSubscriptionService().Subscribe(ctx, appCtx.Server(), appCtx.Token(), data.CameraGroupId, eventTypeId)
+	if err != nil {
+		return nil, fmt.Errorf("While creating a new camera group subscription: %w", err)
+	}
+	siteEvents.Follow(site, appCtx.WsEventsService())
+
+	subscription.GroupCameras = cameras.Cameras
+	return subscription, nil
+}
+
+// Stops following the events of the site of appCtx and closes its events session. The other sites of the session keep running.
+// Must be called with the app context locked.
+func stopSubscription(appCtx handlers_context.AppContext, siteEvents services.SiteEventsService) error {
+	siteEvents.Unfollow(handlers_context.SiteName(appCtx))
+	appCtx.CameraGroupSubscriptionService().Stop()
+	if err := appCtx.WsEventsService().RequestClose(); err != nil {
+		return fmt.Errorf("While closing the previous websocket connection: %w", err)
+	}
+	return nil
+}
+
+// Returns the id on the site of appCtx of an event type selected on another site of the session, matching the event types by name.
+// The id is returned unchanged when the event type comes from the same site.
+func siteEventTypeId(ctx context.Context, username, eventTypeSite, eventTypeId string, appCtx handlers_context.AppContext) (string, error) {
+	if eventTypeSite == "" || eventTypeSite == handlers_context.SiteName(appCtx) {
+		return eventTypeId, nil
+	}
+
+	fromCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(username, eventTypeSite)
+	if !exists {
+		return "", fmt.Errorf("site %s is not part of the session", eventTypeSite)
+	}
+	fromTypes, err := fromCtx.ConfigCacheService().RequestAnalyticEventTypes(ctx, fromCtx.Server(), fromCtx.Token())
+	if err != nil {
+		return "", err
+	}
+	toTypes, err := appCtx.ConfigCacheService().RequestAnalyticEventTypes(ctx, appCtx.Server(), appCtx.Token())
+	if err != nil {
+		return "", err
+	}
+
+	for _, fromType := range fromTypes.Types {
+		if fromType.ID != eventTypeId {
+			continue
+		}
+		for _, toType := range toTypes.Types {
+			if toType.Name == fromType.Name {
+				return toType.ID, nil
+			}
+		}
+		return "", fmt.Errorf("no event type named %s", fromType.Name)
+	}
+	return "", fmt.Errorf("event type %s not found on site %s", eventTypeId, eventTypeSite)
 }
 
 func (eh *EventHandler) RequestEventsHandle(w http.ResponseWriter, r *http.Request) {
-	eh.mu.Lock()
-	defer eh.mu.Unlock()
-	log.Println("EventHandler.RequestEventsHandle() called")
+	logger.DebugContext(r.Context(), "EventHandler.RequestEventsHandle() called")
 
 	var data struct {
 		Username string `json:"username"`
@@ -93,14 +198,14 @@ func (eh *EventHandler) RequestEventsHandle(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(data.Username)
+	siteEvents, exists := handlers_context.GetAppContextsInstance().GetSiteEvents(data.Username)
 	if !exists {
 		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
 		return
 	}
 
-	// Start reading events, if the communication was closed will return an error but we can ignore it since the we closed the communication gracefully
-	aes, err := appCtx.WsEventsService().RequestEvents(r.Context())
+	// Start reading the events of all sites, if no session is open will return an error
+	aes, err := siteEvents.RequestEvents(r.Context())
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Requesting events: %v", err), http.StatusInternalServerError)
 		return
@@ -115,3 +220,157 @@ func (eh *EventHandler) RequestEventsHandle(w http.ResponseWriter, r *http.Reque
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(aesJson))
 }
+
+func (eh *EventHandler) TriggerEventHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "EventHandler.TriggerEventHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+		api.EventTriggerRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" || data.EventTypeId == "" {
+		http.Error(w, "Missing required fields: Username or EventTypeId.", http.StatusBadRequest)
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, data.Username)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(username, data.Site)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	event, err := triggerEvent(r.Context(), appCtx, &data.EventTriggerRequest)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	eventJson, err := event.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting event to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(eventJson))
+}
+
+// Triggers an event of the request on the site of appCtx, with the camera of the request as source.
+func triggerEvent(ctx context.Context, appCtx handlers_context.AppContext, data *api.EventTriggerRequest) (*events.Event, error) {
+	if err := checkFeature(appCtx, vms.FeatureEventsRest); err != nil {
+		return nil, err
+	}
+
+	// The camera is optional, without it the event has no source
+	request := &events.EventTriggerRequest{
+		Type: data.EventTypeId,
+		Data: data.Data,
+	}
+	if data.CameraId != "" {
+		request.Source = "cameras/" + data.CameraId
+	}
+
+	event, err := appCtx.EventsRestService().TriggerEvent(ctx, appCtx.Server(), appCtx.Token(), request)
+	if err != nil {
+		return nil, fmt.Errorf("While triggering the event: %w", err)
+	}
+	return event, nil
+}
+
+func (eh *EventHandler) BookmarkEventHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "EventHandler.BookmarkEventHandle() called")
+
+	var data struct {
+		Username  string `json:"username"`
+		EventId   string `json:"eventId"`
+		EventType string `json:"eventType"`
+		// Source of the event, e.g. cameras/{id}
+		Source string `json:"source"`
+		Time   string `json:"time"`
+		// Seconds recorded before and after the event, defaults to defaultBookmarkWindow when missing
+		PreSeconds  *float64 `json:"preSeconds"`
+		PostSeconds *float64 `json:"postSeconds"`
+		// Site the event comes from, the first site of the session when empty
+		Site string `json:"site"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" || data.Source == "" || data.Time == "" {
+		http.Error(w, "Missing required fields: Username, Source or Time.", http.StatusBadRequest)
+		return
+	}
+
+	// Only events from cameras have recordings to bookmark
+	cameraId, ok := strings.CutPrefix(data.Source, "cameras/")
+	if !ok || cameraId == "" {
+		http.Error(w, fmt.Sprintf("The event source is not a camera: %s", data.Source), http.StatusBadRequest)
+		return
+	}
+
+	triggered, err := time.Parse(time.RFC3339Nano, data.Time)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid event time: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	pre, post := defaultBookmarkWindow, defaultBookmarkWindow
+	if data.PreSeconds != nil {
+		pre = time.Duration(*data.PreSeconds * float64(time.Second))
+	}
+	if data.PostSeconds != nil {
+		post = time.Duration(*data.PostSeconds * float64(time.Second))
+	}
+	if pre < 0 || post < 0 {
+		http.Error(w, "The bookmark window can't be negative.", http.StatusBadRequest)
+		return
+	}
+
+	// The session cookie names the user acted for, see RequireRole
+	username, err := actingUser(r, data.Username)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(username, data.Site)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureBookmarks) {
+		return
+	}
+
+	header := fmt.Sprintf("Event %s", data.EventType)
+	description := fmt.Sprintf("Created by %s from event %s", constants.AppName, data.EventId)
+	bookmark, err := appCtx.GatewayService().CreateBookmarkAround(r.Context(), appCtx.Server(), appCtx.Token(), cameraId, triggered, pre, post, header, description)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("While creating the bookmark: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	bookmarkJson, err := bookmark.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting bookmark to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(bookmarkJson))
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"apigateway-webserver/src/pkg/entities/vms"
+	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+)
+
+// Writes an error naming the missing feature and returns false when the server of the app context doesn't support it.
+func requireFeature(w http.ResponseWriter, appCtx handlers_context.AppContext, feature vms.Feature) bool {
+	if err := checkFeature(appCtx, feature); err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return false
+	}
+	return true
+}
+
+// Returns an error answered with status 501 when the server of the app context doesn't support the feature.
+func checkFeature(appCtx handlers_context.AppContext, feature vms.Feature) error {
+	if err := appCtx.Server().CheckSupports(feature); err != nil {
+		return &statusError{status: http.StatusNotImplemented, err: err}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/services"
+)
+
+// Handles the liveness and readiness probes of the container orchestrator.
+// The probes are called often, they are not logged.
+type HealthHandler struct {
+	// Nil when no management server is configured, the webserver is then ready as soon as it listens
+	readinessService services.ReadinessService
+}
+
+func NewHealthHandler(readinessService services.ReadinessService) *HealthHandler {
+	return &HealthHandler{
+		readinessService: readinessService,
+	}
+}
+
+// Answers as long as the process serves requests.
+func (hh *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// Returns the report of the readiness checks, with status 503 when one of them failed.
+func (hh *HealthHandler) ReadyHandle(w http.ResponseWriter, r *http.Request) {
+	report := vms.NewReadinessReport()
+	if hh.readinessService != nil {
+		report = hh.readinessService.Check(r.Context())
+	}
+
+	reportJson, err := report.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting readiness report to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !report.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write([]byte(reportJson))
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+	"apigateway-webserver/src/pkg/view"
+)
+
+// Handles the configuration browser page. The handler keeps no state and doesn't lock.
+type HierarchyHandler struct{}
+
+func NewHierarchyHandler() *HierarchyHandler {
+	return &HierarchyHandler{}
+}
+
+func (hh *HierarchyHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "HierarchyHandler.Handle() called")
+
+	queryParams := r.URL.Query()
+	username := queryParams.Get("username")
+	if username == "" {
+		http.Error(w, "Missing required fields: username.", http.StatusBadRequest)
+		return
+	}
+
+	path := "templates/hierarchy.html"
+	tmpl, err := view.ParseTemplate(path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Parsing template file %s: %v", path, err), http.StatusInternalServerError)
+		return
+	}
+
+	// Site to browse, the first site of the session when empty
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(username, queryParams.Get("site"))
+	if !exists {
+		http.Error(w, "App context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	// data to be passed to the template
+	pageData := struct {
+		AppName  string
+		Username string
+		Search   string
+		Site     string
+	}{
+		AppName:  constants.AppName,
+		Username: username,
+		Search:   queryParams.Get("search"),
+		Site:     handlers_context.SiteName(appCtx),
+	}
+	if err := executeTemplate(r, w, tmpl, path, pageData); err != nil {
+		http.Error(w, fmt.Sprintf("Executing template: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func (hh *HierarchyHandler) RequestHierarchyHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "HierarchyHandler.RequestHierarchyHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+		// Optional, only the nodes with a name containing the search text are returned, along with their ancestors
+		Search string `json:"search"`
+		// Site to browse, the first site of the session when empty
+		Site string `json:"site"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" {
+		http.Error(w, "Missing required fields: username", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(data.Username, data.Site)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	root, err := appCtx.HierarchyService().RequestHierarchy(r.Context(), appCtx.Server(), appCtx.Token())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Requesting hierarchy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	found := root.Search(data.Search)
+	if found == nil {
+		// Keep the site node so the page still has a root to show
+		site := *root
+		site.Children = []*vms.HierarchyNode{}
+		found = &site
+	}
+
+	hierarchyJson, err := found.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting hierarchy to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(hierarchyJson))
+}
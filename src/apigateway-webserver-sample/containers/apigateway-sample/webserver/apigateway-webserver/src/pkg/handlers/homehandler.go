@@ -2,31 +2,25 @@ package handlers
 
 import (
 	"fmt"
-	"html/template"
-	"log"
 	"net/http"
-	"sync"
 
 	"apigateway-webserver/src/pkg/constants"
 	"apigateway-webserver/src/pkg/constants/enums"
 	"apigateway-webserver/src/pkg/view"
 )
 
-type HomeHandler struct {
-	mu sync.Mutex
-}
+// Handles the home page, with the login form. The handler keeps no state and doesn't lock.
+type HomeHandler struct{}
 
 func NewHomeHandler() *HomeHandler {
 	return &HomeHandler{}
 }
 
 func (hh *HomeHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	hh.mu.Lock()
-	defer hh.mu.Unlock()
-	log.Println("HomeHandler.Handle() called")
+	logger.DebugContext(r.Context(), "HomeHandler.Handle() called")
 
 	path := "templates/index.html"
-	tmpl, err := template.ParseFS(view.TemplateFS, path)
+	tmpl, err := view.ParseTemplate(path)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Parsing template file %s: %v", path, err), http.StatusInternalServerError)
 		return
@@ -39,7 +33,7 @@ func (hh *HomeHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		AppName:              constants.AppName,
 		CredentialsFlowTypes: enums.GetCredentialsFlowTypes(),
 	}
-	if err := tmpl.Execute(w, pageData); err != nil {
+	if err := executeTemplate(r, w, tmpl, path, pageData); err != nil {
 		http.Error(w, fmt.Sprintf("Executing template: %v", err), http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,6 @@
+package handlers
+
+import "apigateway-webserver/src/pkg/logging"
+
+// Logger of the package, see logging.For.
+var logger = logging.For("handlers")
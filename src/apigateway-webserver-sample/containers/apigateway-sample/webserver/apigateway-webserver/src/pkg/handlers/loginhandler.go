@@ -4,60 +4,69 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
-	"sync"
 
 	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/api"
 	"apigateway-webserver/src/pkg/entities/appcenter"
 	"apigateway-webserver/src/pkg/entities/vms"
 	handlers_context "apigateway-webserver/src/pkg/handlers/context"
 	"apigateway-webserver/src/pkg/services"
 )
 
+// Handles the logins of the login page. The handler keeps no state and doesn't lock, the logins of different users
+// don't wait for each other.
 type LoginHandler struct {
-	mu sync.Mutex
+	timeouts Timeouts
 }
 
-func NewLoginHandler() *LoginHandler {
-	return &LoginHandler{}
+func NewLoginHandler(timeouts Timeouts) *LoginHandler {
+	return &LoginHandler{
+		timeouts: timeouts,
+	}
 }
 
 func (lh *LoginHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	lh.mu.Lock()
-	defer lh.mu.Unlock()
-	log.Println("LoginHandler.Handle() called")
+	logger.DebugContext(r.Context(), "LoginHandler.Handle() called")
 
-	var data struct {
-		Username            string `json:"username"`
-		Password            string `json:"password"`
-		Hostname            string `json:"hostname"`
-		Secure              bool   `json:"secure"`
-		CredentialsFlowType string `json:"credentialsFlowType"`
-	}
+	var data api.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	if data.Hostname == "" {
-		http.Error(w, "Missing required field: hostname", http.StatusBadRequest)
+	if err := login(r.Context(), lh.timeouts, &data, sessionKey(r)); err != nil {
+		http.Error(w, err.Error(), statusOf(err))
 		return
 	}
 
-	// If the user selected the login form and didn't left the password field empty
-	if data.CredentialsFlowType == enums.LoginForm.String() {
-		if data.Password == "" {
-			http.Error(w, "Missing required field: password", http.StatusBadRequest)
-			return
-		}
-		if data.Hostname == "" {
-			http.Error(w, "Missing required field: hostname", http.StatusBadRequest)
-			return
+	setSessionCookie(w, r, data.Username)
+	sites := len(handlers_context.GetAppContextsInstance().GetSiteContexts(data.Username))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(fmt.Sprintf(`{ "message": "Login success", "sites": %d }`, sites)))
+}
+
+// Logs the user in to the management server, replacing the session of the user or adding the server to it.
+// Only the holder of the session key of the user can add a server to its session. The requests to the server end with
+// the request context, or once their timeout expired.
+func login(ctx context.Context, timeouts Timeouts, data *api.LoginRequest, sessionKey string) error {
+	if data.Hostname == "" {
+		return errorWithStatus(http.StatusBadRequest, "Missing required field: hostname")
+	}
+
+	// Checked again when the site is added, refused here before logging in to the server
+	if data.AddSite {
+		if key, exists := handlers_context.GetAppContextsInstance().GetSessionKey(data.Username); exists && key != sessionKey {
+			return errNotSessionOwner(data.Username)
 		}
 	}
 
+	// If the user selected the login form and didn't left the password field empty
+	if data.CredentialsFlowType == enums.LoginForm.String() && data.Password == "" {
+		return errorWithStatus(http.StatusBadRequest, "Missing required field: password")
+	}
+
 	scheme := "http"
 	if data.Secure {
 		scheme = "https"
@@ -65,33 +74,52 @@ func (lh *LoginHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	credentialsFlowType, err := enums.ParseCredentialsFlowType(data.CredentialsFlowType)
 	if err != nil {
-		http.Error(w, "Couldn't parse the provided credential flow type", http.StatusBadRequest)
-		return
+		return errorWithStatus(http.StatusBadRequest, "Couldn't parse the provided credential flow type")
 	}
 
-	username, password, err := appcenter.ReadCredentialsFromEnv(data.Username, data.Password, credentialsFlowType)
+	username, password, err := appcenter.ReadCredentials(data.Username, data.Password, credentialsFlowType)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Couldn't read the credentials files: %v", err), http.StatusInternalServerError)
-		return
+		return fmt.Errorf("Couldn't read the credentials files: %w", err)
 	}
 
-	appCtx, err := setupAppContext(data.Hostname, username, password, scheme, credentialsFlowType)
+	appCtx, err := setupAppContext(ctx, timeouts, data.Hostname, username, password, scheme, credentialsFlowType)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Unable to perform login: %v", err), http.StatusInternalServerError)
-		return
+		return fmt.Errorf("Unable to perform login: %w", err)
 	}
 
-	// Always override the previous user login session
-	handlers_context.GetAppContextsInstance().AddAppContext(data.Username, appCtx)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{ "message": "Login success" }`))
+	// Started before the context is shared, a concurrent login of the same user replacing it stops it again
+	appCtx.ConfigCacheService().Start(appCtx.Server(), appCtx.Token())
+
+	// Override the previous user login session, unless the server is added to it
+	if data.AddSite {
+		previous, replaced, err := handlers_context.GetAppContextsInstance().AddSiteContext(data.Username, sessionKey, appCtx)
+		if err != nil {
+			appCtx.Close()
+			return errNotSessionOwner(data.Username)
+		}
+		if replaced {
+			previous.Close()
+		}
+	} else {
+		for _, previous := range handlers_context.GetAppContextsInstance().AddAppContext(data.Username, appCtx) {
+			previous.Close()
+		}
+	}
+	return nil
 }
 
-func setupAppContext(hostname, username, password, scheme string, credentialsFlowType enums.CredentialsFlowType) (handlers_context.AppContext, error) {
+func setupAppContext(ctx context.Context, timeouts Timeouts, hostname, username, password, scheme string, credentialsFlowType enums.CredentialsFlowType) (handlers_context.AppContext, error) {
 	// Create services
 	gatewayService := services.NewGatewayService()
 	idpService := services.NewIdpService()
 	wsEventsService := services.NewWsEventsService()
+	eventsRestService := services.NewEventsRestService()
+	alarmsService := services.NewAlarmsService()
+	wsAlarmsService := services.NewWsEventsService()
+	hierarchyService := services.NewHierarchyService()
+	cameraGroupSubscriptionService := services.NewCameraGroupSubscriptionService(gatewayService, wsEventsService)
+	cameraActionsService := services.NewCameraActionsService()
+	roleService := services.NewRoleService()
 
 	// Parse the url string into a real url
 	parsedServerUrl := &url.URL{
@@ -102,26 +130,56 @@ func setupAppContext(hostname, username, password, scheme string, credentialsFlo
 	// Create endpoint needed data structures
 	user := vms.NewUser(username, password, credentialsFlowType)
 	server := vms.NewServer(parsedServerUrl)
+	// The cache of the configuration read with the permissions of the user
+	configCacheService := services.NewConfigCacheService(gatewayService, user)
 
 	var err error
 
 	// Request gateway uris
-	server.ApiWellKnownUris, err = gatewayService.RequestGatewayWellKnownUris(context.Background(), server)
+	server.ApiWellKnownUris, err = withDeadline(ctx, timeouts.Discovery, "Reading the gateway well-known URIs", func(ctx context.Context) (*vms.ApiWellKnownUrisSchema, error) {
+		return gatewayService.RequestGatewayWellKnownUris(ctx, server)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Request idp openid config
-	server.IdpOpenIdConfig, err = idpService.RequestIdpWellKnownConfig(context.Background(), server)
+	server.IdpOpenIdConfig, err = withDeadline(ctx, timeouts.Discovery, "Reading the IDP configuration", func(ctx context.Context) (*vms.IdpOpenIdConfigSchema, error) {
+		return idpService.RequestIdpWellKnownConfig(ctx, server)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Scopes, client and extra parameters of the token requests configured for this server
+	tokenProfile, err := idpService.RequestTokenProfile(user, server)
 	if err != nil {
 		return nil, err
 	}
+	server.SetTokenProfile(tokenProfile)
 
 	// Create access token for the given management server and user
-	token, err := idpService.RequestAccessToken(context.Background(), user, server)
+	token, err := withDeadline(ctx, timeouts.Token, "Requesting the access token", func(ctx context.Context) (vms.Token, error) {
+		return idpService.RequestAccessToken(ctx, user, server)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Role of the user in the webserver, from the claims of the token or the VMS roles of the user
+	role, err := withDeadline(ctx, timeouts.List, "Reading the roles of the user", func(ctx context.Context) (enums.Role, error) {
+		return roleService.RequestRole(ctx, user, server, token)
+	})
 	if err != nil {
+		idpService.ReleaseAccessToken(token)
 		return nil, err
 	}
 
-	return handlers_context.NewAppContext(idpService, gatewayService, wsEventsService, server, user, token), nil
+	// Detect the optional features of the server, so the pages can turn off the unsupported ones.
+	// Like unreachable ones, the probes that didn't answer in time leave their feature on.
+	detectCtx, cancel := deadlineContext(ctx, timeouts.Discovery)
+	server.SetCapabilities(gatewayService.DetectCapabilities(detectCtx, server, token))
+	cancel()
+
+	return handlers_context.NewAppContext(idpService, gatewayService, wsEventsService, eventsRestService, alarmsService, wsAlarmsService, hierarchyService, cameraGroupSubscriptionService, cameraActionsService, configCacheService, server, user, token, role), nil
 }
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/constants/enums"
+	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+)
+
+// Hostname of the management server shared by the tests, trusted by the role mappings.
+var fakeVmsHostname string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "handlers")
+	if err != nil {
+		panic(err)
+	}
+	vms := newFakeVms()
+	fakeVmsHostname = strings.TrimPrefix(vms.URL, "http://")
+
+	mappingsPath := filepath.Join(dir, "roles.json")
+	mappings := fmt.Sprintf(`{"defaultRole":"viewer","sites":[%q],"claims":{"operator":["Operators"]}}`, fakeVmsHostname)
+	if err := os.WriteFile(mappingsPath, []byte(mappings), 0600); err != nil {
+		panic(err)
+	}
+	os.Setenv(constants.RoleMappingsPathEnv, mappingsPath)
+	os.Setenv(constants.ConfigCachePathEnv, filepath.Join(dir, "config.db"))
+
+	code := m.Run()
+	handlers_context.GetAppContextsInstance().CloseAll()
+	vms.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Management server with an API gateway and an IDP signing its tokens. The tokens name their user, and the users named
+// operator* get the Operators role claim. The lists answer one item named after the user of the token, so each user can
+// tell its own data.
+func newFakeVms() *httptest.Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	var vms *httptest.Server
+	vms = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path := r.URL.Path; {
+		case path == "/api/.well-known/uris":
+			fmt.Fprintf(w, `{"ProductVersion":"24.1.0.1","ApiGateways":["%s/"]}`, vms.URL)
+		case path == "/idp/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":"%[1]s/idp","token_endpoint":"%[1]s/idp/connect/token","jwks_uri":"%[1]s/idp/jwks"}`, vms.URL)
+		case path == "/idp/jwks":
+			fmt.Fprintf(w, `{"keys":[{"kty":"RSA","use":"sig","kid":"k1","n":%q,"e":"AQAB"}]}`, base64.RawURLEncoding.EncodeToString(key.N.Bytes()))
+		case path == "/idp/connect/token":
+			r.ParseForm()
+			username := r.PostForm.Get("username")
+			role := "Users"
+			if strings.HasPrefix(username, "operator") {
+				role = "Operators"
+			}
+			token := signToken(key, map[string]any{"iss": vms.URL + "/idp", "sub": username, "role": []string{role}})
+			fmt.Fprintf(w, `{"access_token":%q,"expires_in":3600,"token_type":"Bearer"}`, token)
+		case strings.HasSuffix(path, "/ws/events/v1/"):
+			http.NotFound(w, r)
+		case r.URL.Query().Has("task"):
+			fmt.Fprintf(w, `{"result":{"state":"Success","task":%q}}`, r.URL.Query().Get("task"))
+		case strings.HasPrefix(path, "/api/rest/v1/"):
+			fmt.Fprintf(w, `{"array":[{"id":"item-1","displayName":%q,"sourceArray":[]}]}`, tokenUser(r))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return vms
+}
+
+// Returns an RS256 JWT of the claims.
+func signToken(key *rsa.PrivateKey, claims map[string]any) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"k1","typ":"JWT"}`))
+	payload, _ := json.Marshal(claims)
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// Returns the user named by the bearer token of the request.
+func tokenUser(r *http.Request) string {
+	parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	json.Unmarshal(payload, &claims)
+	return claims.Sub
+}
+
+func newTestMux() *http.ServeMux {
+	timeouts := Timeouts{}
+	loginHandler := NewLoginHandler(timeouts)
+	eventTypesHandler := NewEventTypesHandler()
+	cameraHandler := NewCameraHandler()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_login/", loginHandler.Handle)
+	mux.HandleFunc("/event_types/_event_types_request/", eventTypesHandler.RequestEventTypesHandle)
+	mux.HandleFunc("/camera/_camera_action_run/", RequireRole(enums.RoleOperator, cameraHandler.RunActionHandle))
+	return mux
+}
+
+func post(mux http.Handler, path string, cookie *http.Cookie, body any) *httptest.ResponseRecorder {
+	return send(mux, http.MethodPost, path, cookie, body)
+}
+
+func send(mux http.Handler, method, path string, cookie *http.Cookie, body any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	request := httptest.NewRequest(method, path, strings.NewReader(string(data)))
+	if cookie != nil {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func sessionCookie(recorder *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == constants.SessionCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// Returns the hostname of the fake VMS, closing the sessions of the test once it ends.
+func setupLogins(t *testing.T) string {
+	t.Cleanup(handlers_context.GetAppContextsInstance().CloseAll)
+	return fakeVmsHostname
+}
+
+func loginRequest(username, hostname string, addSite bool) map[string]any {
+	return map[string]any{
+		"username":            username,
+		"password":            "secret",
+		"hostname":            hostname,
+		"credentialsFlowType": enums.LoginForm.String(),
+		"addSite":             addSite,
+	}
+}
+
+// Many users log in at once and use the pages while the others log in again. Every user only gets its own session,
+// data and role. Run with -race.
+func TestParallelLoginsAndPages(t *testing.T) {
+	hostname := setupLogins(t)
+	mux := newTestMux()
+
+	const users, rounds = 12, 3
+	var wg sync.WaitGroup
+	for u := range users {
+		username := fmt.Sprintf("viewer%d", u)
+		if u%2 == 0 {
+			username = fmt.Sprintf("operator%d", u)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				login := post(mux, "/_login/", nil, loginRequest(username, hostname, false))
+				if login.Code != http.StatusOK {
+					t.Errorf("login of %s: %d %s", username, login.Code, login.Body)
+					return
+				}
+				cookie := sessionCookie(login)
+				if cookie == nil {
+					t.Errorf("login of %s set no session cookie", username)
+					return
+				}
+
+				page := post(mux, "/event_types/_event_types_request/", cookie, map[string]any{"username": username})
+				if page.Code != http.StatusOK || !strings.Contains(page.Body.String(), fmt.Sprintf("%q", username)) {
+					t.Errorf("event types of %s: %d %s", username, page.Code, page.Body)
+				}
+
+				action := map[string]any{"username": username, "kind": "camera", "id": "cam-1", "task": "Test"}
+				expected := http.StatusForbidden
+				if strings.HasPrefix(username, "operator") {
+					expected = http.StatusOK
+				}
+				if run := post(mux, "/camera/_camera_action_run/", cookie, action); run.Code != expected {
+					t.Errorf("action of %s: %d %s, expected %d", username, run.Code, run.Body, expected)
+				}
+
+				// The cookie of a user doesn't act for another one
+				action["username"] = "operator0"
+				if username != "operator0" {
+					if run := post(mux, "/camera/_camera_action_run/", cookie, action); run.Code != http.StatusForbidden {
+						t.Errorf("%s acted for operator0: %d %s", username, run.Code, run.Body)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every user is still logged in once the others logged in again
+	for u := range users {
+		username := fmt.Sprintf("viewer%d", u)
+		if u%2 == 0 {
+			username = fmt.Sprintf("operator%d", u)
+		}
+		if _, ok := handlers_context.GetAppContextsInstance().GetSessionKey(username); !ok {
+			t.Errorf("%s has no session", username)
+		}
+	}
+}
+
+// Logins adding a site to the session of another user are refused, and don't get the cookie of the session.
+func TestAddSiteNeedsSessionCookie(t *testing.T) {
+	hostname := setupLogins(t)
+	mux := newTestMux()
+
+	owner := post(mux, "/_login/", nil, loginRequest("operator-owner", hostname, false))
+	ownerCookie := sessionCookie(owner)
+	if owner.Code != http.StatusOK || ownerCookie == nil {
+		t.Fatalf("login of the owner: %d %s", owner.Code, owner.Body)
+	}
+	intruder := post(mux, "/_login/", nil, loginRequest("viewer-intruder", hostname, false))
+	intruderCookie := sessionCookie(intruder)
+	if intruder.Code != http.StatusOK || intruderCookie == nil {
+		t.Fatalf("login of the intruder: %d %s", intruder.Code, intruder.Body)
+	}
+
+	for _, cookie := range []*http.Cookie{nil, intruderCookie} {
+		join := post(mux, "/_login/", cookie, loginRequest("operator-owner", hostname, true))
+		if join.Code != http.StatusForbidden || sessionCookie(join) != nil {
+			t.Fatalf("adding a site to the session of another user: %d %s", join.Code, join.Body)
+		}
+	}
+	if sites := handlers_context.GetAppContextsInstance().GetSiteContexts("operator-owner"); len(sites) != 1 {
+		t.Fatalf("the session of the owner has %d sites", len(sites))
+	}
+
+	own := post(mux, "/_login/", ownerCookie, loginRequest("operator-owner", hostname, true))
+	if own.Code != http.StatusOK || sessionCookie(own).Value != ownerCookie.Value {
+		t.Fatalf("adding a site with the cookie of the session: %d %s", own.Code, own.Body)
+	}
+}
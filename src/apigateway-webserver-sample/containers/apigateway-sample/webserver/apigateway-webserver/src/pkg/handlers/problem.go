@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"apigateway-webserver/src/pkg/entities/api"
+	"apigateway-webserver/src/pkg/logging"
+)
+
+// Error answered with the given status, shared by the pages and the API. Other errors are answered with status 500.
+type statusError struct {
+	status int
+	err    error
+}
+
+func errorWithStatus(status int, format string, args ...any) error {
+	return &statusError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+func (se *statusError) Error() string {
+	return se.err.Error()
+}
+
+func (se *statusError) Unwrap() error {
+	return se.err
+}
+
+// Returns the status of the response answering the error.
+func statusOf(err error) int {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Answers an API request with the problem details of the error.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, r, statusOf(err), err.Error())
+}
+
+// Answers an API request with problem details of the given status.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	problem := api.NewProblem(status, detail)
+	// The request URI still has the base path, without the query that may hold secrets
+	problem.Instance, _, _ = strings.Cut(r.RequestURI, "?")
+	problem.RequestID = logging.RequestID(r.Context())
+
+	problemJson, err := problem.ToJSON()
+	if err != nil {
+		http.Error(w, detail, status)
+		return
+	}
+	w.Header().Set("Content-Type", api.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write([]byte(problemJson))
+}
+
+// Answers an API request with the given body encoded as JSON.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, fmt.Sprintf("Converting the response to JSON: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonData)
+}
+
+// Reads the JSON body of an API request, refusing unknown fields so that misspelled fields aren't silently ignored.
+func decodeJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return errorWithStatus(http.StatusBadRequest, "Invalid JSON format: %v", err)
+	}
+	return nil
+}
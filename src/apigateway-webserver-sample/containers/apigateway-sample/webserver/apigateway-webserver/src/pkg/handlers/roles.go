@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/constants/enums"
+	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+)
+
+// Key of the user checked by RequireRole in the request context.
+type callerKey struct{}
+
+// Answers with status 401 the requests without the cookie of a session, and with status 403 those of a session whose
+// role doesn't allow the route of the page. The handler acts for the user of the session, see actingUser.
+func RequireRole(required enums.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, err := checkRole(r, required, false)
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		next(w, withCaller(r, username))
+	}
+}
+
+// Like RequireRole for the operations of the API, answering with problem details like the other errors of the API.
+func (ah *ApiHandler) RequireRole(required enums.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, err := checkRole(r, required, false)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		next(w, withCaller(r, username))
+	}
+}
+
+// Like RequireRole, but also lets the user act on their own session named in the path, e.g. to log out.
+func (ah *ApiHandler) RequireRoleOrSelf(required enums.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, err := checkRole(r, required, true)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		next(w, withCaller(r, username))
+	}
+}
+
+// Returns the user of the session cookie of the request, or an error answered with status 401 when there is none and
+// 403 when the role of the session doesn't allow the required role. With self, a user acting on the session named in
+// the path of the request is allowed whatever the role.
+func checkRole(r *http.Request, required enums.Role, self bool) (string, error) {
+	username, err := authenticate(r)
+	if err != nil {
+		return "", err
+	}
+	if self && r.PathValue("username") == username {
+		return username, nil
+	}
+
+	role, exists := handlers_context.GetAppContextsInstance().GetRole(username)
+	if !exists {
+		return "", errorWithStatus(http.StatusUnauthorized, "The session of user %s ended, log in again", username)
+	}
+	if !role.Allows(required) {
+		logger.WarnContext(r.Context(), "Action refused to the role of the user", "username", username, "role", role.String(), "requiredRole", required.String())
+		return "", errorWithStatus(http.StatusForbidden, "The %s role of user %s doesn't allow this action, it needs the %s role", role, username, required)
+	}
+	return username, nil
+}
+
+// Returns the user of the session named by the cookie of the request, or an error answered with status 401.
+func authenticate(r *http.Request) (string, error) {
+	key := sessionKey(r)
+	if key == "" {
+		return "", errorWithStatus(http.StatusUnauthorized, "No session cookie, log in first")
+	}
+	username, exists := handlers_context.GetAppContextsInstance().Authenticate(key)
+	if !exists {
+		return "", errorWithStatus(http.StatusUnauthorized, "The session of the cookie ended, log in again")
+	}
+	return username, nil
+}
+
+// Returns the session key of the cookie of the request, empty without cookie.
+func sessionKey(r *http.Request) string {
+	cookie, err := r.Cookie(constants.SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// Answered to the logins adding a site to the session of another user.
+func errNotSessionOwner(username string) error {
+	return errorWithStatus(http.StatusForbidden, "User %s has a session, log in with its session cookie to add a site to it", username)
+}
+
+func withCaller(r *http.Request, username string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), callerKey{}, username))
+}
+
+// Returns the user checked by RequireRole, whom the handlers of the routes it guards act for. The user named by the
+// request, if any, must be the same: requests naming another user are answered with status 403.
+func actingUser(r *http.Request, requested string) (string, error) {
+	caller, _ := r.Context().Value(callerKey{}).(string)
+	if caller == "" {
+		return "", errorWithStatus(http.StatusUnauthorized, "No session cookie, log in first")
+	}
+	if requested != "" && requested != caller {
+		return "", errorWithStatus(http.StatusForbidden, "The session cookie is of user %s, not of user %s", caller, requested)
+	}
+	return caller, nil
+}
+
+// Gives the key of the session of the user to the browser or script that logged in, as an HTTP only cookie.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, username string) {
+	key, exists := handlers_context.GetAppContextsInstance().GetSessionKey(username)
+	if !exists {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     constants.SessionCookieName,
+		Value:    key,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"apigateway-webserver/src/pkg/tracing"
+	"apigateway-webserver/src/pkg/view"
+)
+
+// Renders a page template in its own span, so that the time spent rendering a slow page is told apart from the time
+// spent reading the VMS. The template functions are the ones of the request, e.g. the nonce of its inline scripts.
+func executeTemplate(r *http.Request, w http.ResponseWriter, tmpl *template.Template, path string, data any) error {
+	_, span := tracing.Start(r.Context(), "Render "+path)
+	err := tmpl.Funcs(view.Funcs(r.Context())).Execute(w, data)
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Maximum durations of the requests sent to the VMS while answering a request, by kind of operation. 0 means no limit
+// other than the request context, which is cancelled when the client disconnects.
+type Timeouts struct {
+	// Well-known URIs, IDP configuration and features of a server, read at login
+	Discovery time.Duration
+	// Token requests at login
+	Token time.Duration
+	// Lists read for the pages and the API, e.g. the cameras of a site
+	List time.Duration
+}
+
+// Returns the longest time the requests of a login to the VMS may take one after the other: the well-known URIs, the IDP
+// configuration, the access token, the roles of the user and the feature probes. 0 when one of them has no limit.
+func (t Timeouts) Login() time.Duration {
+	if t.Discovery <= 0 || t.Token <= 0 || t.List <= 0 {
+		return 0
+	}
+	return 3*t.Discovery + t.Token + t.List
+}
+
+// Returns a context expiring after the timeout, or the given context when the timeout is 0.
+func deadlineContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Calls the operation with a context expiring after the timeout. When it expires, the error is answered with status 504
+// and names the operation, e.g. "Reading the cameras timed out after 30s".
+func withDeadline[T any](ctx context.Context, timeout time.Duration, operation string, call func(context.Context) (T, error)) (T, error) {
+	deadlineCtx, cancel := deadlineContext(ctx, timeout)
+	defer cancel()
+
+	result, err := call(deadlineCtx)
+	// Only the deadline of the operation is reported, a request cancelled by its client isn't answered anyway
+	if err != nil && errors.Is(deadlineCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return result, errorWithStatus(http.StatusGatewayTimeout, "%s timed out after %s", operation, timeout)
+	}
+	return result, err
+}
+
+// Returns the status of the response answering an error of the VMS: the status of a timeout, 502 otherwise.
+func upstreamStatus(err error) int {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.status
+	}
+	return http.StatusBadGateway
+}
@@ -2,30 +2,30 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"html/template"
-	"log"
 	"net/http"
-	"sync"
 
 	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/events"
 	"apigateway-webserver/src/pkg/entities/vms"
 	handlers_context "apigateway-webserver/src/pkg/handlers/context"
 	"apigateway-webserver/src/pkg/view"
 )
 
+// Handles the events page. The handler keeps no state and doesn't lock.
 type ViewHandler struct {
-	mu sync.Mutex
+	timeouts Timeouts
 }
 
-func NewViewHandler() *ViewHandler {
-	return &ViewHandler{}
+func NewViewHandler(timeouts Timeouts) *ViewHandler {
+	return &ViewHandler{
+		timeouts: timeouts,
+	}
 }
 
 func (vh *ViewHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	vh.mu.Lock()
-	defer vh.mu.Unlock()
-	log.Println("ViewHandler.Handle() called")
+	logger.DebugContext(r.Context(), "ViewHandler.Handle() called")
 
 	queryParams := r.URL.Query()
 	username := queryParams.Get("username")
@@ -35,37 +35,41 @@ func (vh *ViewHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	path := "templates/view_events.html"
-	tmpl, err := template.ParseFS(view.TemplateFS, path)
+	tmpl, err := view.ParseTemplate(path)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Parsing template file %s: %v", path, err), http.StatusInternalServerError)
 		return
 	}
 
-	appCtx, exists := handlers_context.GetAppContextsInstance().GetAppContext(username)
+	role, exists := handlers_context.GetAppContextsInstance().GetRole(username)
 	if !exists {
 		http.Error(w, "App context not found.", http.StatusBadRequest)
 		return
 	}
 
-	cameras, eventTypes, err := setupPageData(appCtx)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Could not read data from the VMS: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	camerasJson, err := cameras.ToJSON()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Converting cameras to JSON: %v", err), http.StatusInternalServerError)
-		return
+	// The cameras and events of every site of the session are shown together, tagged with their site
+	sites := vms.NewSites()
+	sessions := map[string]*events.WsCommandResponse{}
+	for _, siteCtx := range handlers_context.GetAppContextsInstance().GetSiteContexts(username) {
+		siteName := handlers_context.SiteName(siteCtx)
+		cameras, cameraGroups, eventTypes, userDefinedEvents, err := setupPageData(r.Context(), vh.timeouts, siteCtx)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Could not read data from the VMS %s: %v", siteName, err), statusOf(err))
+			return
+		}
+		site := vms.NewSite(siteName, siteCtx.Server().Capabilities(), cameras, cameraGroups, eventTypes, userDefinedEvents)
+		site.SetScopes(siteCtx.Server().TokenProfile(), siteCtx.Token())
+		sites.Add(site)
+		sessions[siteName] = siteCtx.GetWsCommandResponse()
 	}
 
-	eventTypesJson, err := eventTypes.ToJSON()
+	sitesJson, err := sites.ToJSON()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Converting event types to JSON: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Converting sites to JSON: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	sessionJson, err := appCtx.GetWsCommandResponse().ToJSON()
+	sessionsJson, err := json.Marshal(sessions)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Converting session info to JSON: %v", err), http.StatusInternalServerError)
 		return
@@ -73,32 +77,151 @@ func (vh *ViewHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	// data to be passed to the template
 	pageData := struct {
-		AppName    string
-		Cameras    string
-		EventTypes string
-		Username   string
-		Session    string
+		AppName  string
+		Sites    string
+		Username string
+		Role     string
+		Sessions string
 	}{
-		AppName:    constants.AppName,
-		Cameras:    camerasJson,
-		EventTypes: eventTypesJson,
-		Username:   username,
-		Session:    sessionJson,
+		AppName:  constants.AppName,
+		Sites:    sitesJson,
+		Username: username,
+		Role:     role.String(),
+		Sessions: string(sessionsJson),
 	}
-	if err := tmpl.Execute(w, pageData); err != nil {
+	if err := executeTemplate(r, w, tmpl, path, pageData); err != nil {
 		http.Error(w, fmt.Sprintf("Executing template: %v", err), http.StatusInternalServerError)
 	}
 }
 
-func setupPageData(appCtx handlers_context.AppContext) (*vms.CamerasList, *vms.AnalyticEventTypes, error) {
-	cameras, err := appCtx.GatewayService().RequestEnabledCameras(context.Background(), appCtx.Server(), appCtx.Token())
+// Reads the lists shown by the page for a site. Each read ends with the request context, or once the list timeout expired.
+func setupPageData(ctx context.Context, timeouts Timeouts, appCtx handlers_context.AppContext) (*vms.CamerasList, *vms.CameraGroups, *vms.AnalyticEventTypes, *vms.UserDefinedEvents, error) {
+	// Cameras and event types come from the configuration cache, the page doesn't wait for the whole configuration to be read again
+	cameras, err := withDeadline(ctx, timeouts.List, "Reading the cameras", func(ctx context.Context) (*vms.CamerasList, error) {
+		return appCtx.ConfigCacheService().RequestCameras(ctx, appCtx.Server(), appCtx.Token())
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// Optional features are left empty when the server doesn't support them
+	cameraGroups := vms.NewCameraGroups()
+	if appCtx.Server().Supports(vms.FeatureConfigApi) {
+		cameraGroups, err = withDeadline(ctx, timeouts.List, "Reading the camera groups", func(ctx context.Context) (*vms.CameraGroups, error) {
+			return appCtx.GatewayService().RequestCameraGroups(ctx, appCtx.Server(), appCtx.Token())
+		})
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	eventTypes, err := withDeadline(ctx, timeouts.List, "Reading the analytic event types", func(ctx context.Context) (*vms.AnalyticEventTypes, error) {
+		return appCtx.ConfigCacheService().RequestAnalyticEventTypes(ctx, appCtx.Server(), appCtx.Token())
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	userDefinedEvents := vms.NewUserDefinedEvents()
+	if appCtx.Server().Supports(vms.FeatureEventsRest) {
+		read, err := withDeadline(ctx, timeouts.List, "Reading the user-defined events", func(ctx context.Context) (*vms.UserDefinedEvents, error) {
+			return appCtx.EventsRestService().RequestUserDefinedEvents(ctx, appCtx.Server(), appCtx.Token())
+		})
+		// Only needed to trigger events, e.g. a user without the permission to read them still watches the events
+		if err != nil {
+			logger.WarnContext(ctx, "Showing the page without the user-defined events", "site", appCtx.Server().Hostname(), "error", err)
+		} else {
+			userDefinedEvents = read
+		}
+	}
+	return cameras, cameraGroups, eventTypes, userDefinedEvents, nil
+}
+
+// Returns the cameras of a camera group, including the cameras of its nested groups.
+func (vh *ViewHandler) RequestGroupCamerasHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ViewHandler.RequestGroupCamerasHandle() called")
+
+	var data struct {
+		Username      string `json:"username"`
+		CameraGroupId string `json:"cameraGroupId"`
+		// Site of the group, the first site of the session when empty
+		Site string `json:"site"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" || data.CameraGroupId == "" {
+		http.Error(w, "Missing required fields: Username or CameraGroupId.", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(data.Username, data.Site)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	if !requireFeature(w, appCtx, vms.FeatureConfigApi) {
+		return
+	}
+
+	cameras, err := appCtx.GatewayService().RequestCameraGroupCameras(r.Context(), appCtx.Server(), appCtx.Token(), data.CameraGroupId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Requesting the cameras of the group: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	camerasJson, err := cameras.ToJSON()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Converting cameras list to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(camerasJson))
+}
+
+// Returns the configuration changes recorded by the configuration cache after the given sequence number.
+// A since of 0 returns all recorded changes.
+func (vh *ViewHandler) RequestConfigChangesHandle(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "ViewHandler.RequestConfigChangesHandle() called")
+
+	var data struct {
+		Username string `json:"username"`
+		Since    uint64 `json:"since"`
+		// Site of the changes, the first site of the session when empty
+		Site string `json:"site"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if data.Username == "" {
+		http.Error(w, "Missing required fields: username", http.StatusBadRequest)
+		return
+	}
+
+	appCtx, exists := handlers_context.GetAppContextsInstance().GetSiteContext(data.Username, data.Site)
+	if !exists {
+		http.Error(w, "App handlers_context not found.", http.StatusBadRequest)
+		return
+	}
+
+	changeSets, err := appCtx.ConfigCacheService().RequestChangeSets(appCtx.Server(), data.Since)
 	if err != nil {
-		return nil, nil, err
+		http.Error(w, fmt.Sprintf("Requesting configuration changes: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	eventTypes, err := appCtx.GatewayService().RequestAnalyticEventTypes(context.Background(), appCtx.Server(), appCtx.Token())
+	changeSetsJson, err := changeSets.ToJSON()
 	if err != nil {
-		return nil, nil, err
+		http.Error(w, fmt.Sprintf("Converting configuration changes to JSON: %v", err), http.StatusInternalServerError)
+		return
 	}
-	return cameras, eventTypes, nil
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(changeSetsJson))
 }
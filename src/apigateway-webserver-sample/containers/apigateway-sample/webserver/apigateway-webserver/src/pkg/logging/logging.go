@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"apigateway-webserver/src/pkg/constants"
+)
+
+// Header carrying the id of a request, read from the incoming requests and set on the requests sent to the VMS.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// Returns a context carrying the id of the request it belongs to.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// Returns the id of the request the context belongs to, empty when none.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Returns a random request id.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Every log record is written as one JSON line, with the secrets redacted.
+var output slog.Handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	Level:       slog.LevelDebug,
+	ReplaceAttr: redactAttr,
+})
+
+var (
+	levelsMu     sync.Mutex
+	defaultLevel = new(slog.LevelVar)
+	// Levels of the packages given their own level, the others use the default level
+	packageLevels = map[string]slog.Level{}
+)
+
+// Returns the logger of a package. Its records have a "package" attribute and, when logged with a request context,
+// "requestId", "traceId" and "spanId" attributes. The level of the package is set by Setup, it can be called before or after.
+func For(pkg string) *slog.Logger {
+	return slog.New(&contextHandler{
+		next:  output.WithAttrs([]slog.Attr{slog.String("package", pkg)}),
+		level: levelOf{pkg: pkg},
+	})
+}
+
+// Level of a package, its own level when one is set and the default level otherwise.
+type levelOf struct {
+	pkg string
+}
+
+func (l levelOf) Level() slog.Level {
+	levelsMu.Lock()
+	level, ok := packageLevels[l.pkg]
+	levelsMu.Unlock()
+	if ok {
+		return level
+	}
+	return defaultLevel.Level()
+}
+
+// Sets the levels from the LOG_LEVEL variable, e.g. "info", and the LOG_LEVELS variable giving the levels of some
+// packages, e.g. "repositories=debug,handlers=warn". Also sends the records of the standard log package to the JSON output.
+func Setup() error {
+	if value := os.Getenv(constants.LogLevelEnv); value != "" {
+		if err := defaultLevel.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("environment variable %s: %w", constants.LogLevelEnv, err)
+		}
+	}
+
+	levels := map[string]slog.Level{}
+	for _, entry := range strings.Split(os.Getenv(constants.LogLevelsEnv), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pkg, value, found := strings.Cut(entry, "=")
+		if !found || pkg == "" {
+			return fmt.Errorf("environment variable %s: %q is not package=level", constants.LogLevelsEnv, entry)
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("environment variable %s: %w", constants.LogLevelsEnv, err)
+		}
+		levels[strings.TrimSpace(pkg)] = level
+	}
+
+	levelsMu.Lock()
+	packageLevels = levels
+	levelsMu.Unlock()
+
+	slog.SetDefault(For("main"))
+	return nil
+}
+
+// Adds the ids of the request and of its trace to the records logged with a request context and filters the records by the package level.
+type contextHandler struct {
+	next  slog.Handler
+	level slog.Leveler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		r.AddAttrs(slog.String("requestId", requestID))
+	}
+	// Lets the logs of a request be found from its trace
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		r.AddAttrs(slog.String("traceId", spanContext.TraceID().String()), slog.String("spanId", spanContext.SpanID().String()))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs), level: h.level}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name), level: h.level}
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Replaces the secrets in the logs.
+const Redacted = "[REDACTED]"
+
+// Names of the attributes and URL query parameters whose value is always a secret, compared in lower case
+// without "_" and "-".
+var secretNames = map[string]bool{
+	"password":      true,
+	"secret":        true,
+	"clientsecret":  true,
+	"token":         true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+	"idtoken":       true,
+	"authorization": true,
+	"cookie":        true,
+	"apikey":        true,
+	"key":           true,
+	"keystorekey":   true,
+}
+
+func isSecretName(name string) bool {
+	name = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+	return secretNames[name]
+}
+
+// Secrets that can end up inside any text, e.g. in an error: bearer tokens, JWTs and form encoded secrets.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	regexp.MustCompile(`(?i)((?:password|client_secret|access_token|refresh_token|id_token)=)[^&\s"]+`),
+	regexp.MustCompile(`(?i)("(?:password|client_secret|clientSecret|access_token|refresh_token|id_token)"\s*:\s*")[^"]*`),
+}
+
+// Returns the text with the secrets it contains replaced.
+func RedactString(s string) string {
+	for _, pattern := range secretPatterns {
+		if pattern.NumSubexp() > 0 {
+			s = pattern.ReplaceAllString(s, "${1}"+Redacted)
+		} else {
+			s = pattern.ReplaceAllString(s, Redacted)
+		}
+	}
+	return s
+}
+
+// Returns the URL with the values of its secret query parameters and its password replaced.
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redacted := *u
+	if _, hasPassword := redacted.User.Password(); hasPassword {
+		redacted.User = url.UserPassword(redacted.User.Username(), Redacted)
+	}
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for name := range query {
+			if isSecretName(name) {
+				query.Set(name, Redacted)
+			}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+// Redacts the attributes named like a secret and the secrets found in the text of the others, the message included.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if isSecretName(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, RedactString(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prefix of the names of all metrics of the webserver.
+const namespace = "apigateway_webserver"
+
+// Values of the result labels.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Kinds of token requests.
+const (
+	// First token of a login
+	TokenIssue = "issue"
+	// Token requested again because the previous one expired
+	TokenRenewal = "renewal"
+	// Token requested again because the client credentials changed
+	TokenReissue = "reissue"
+)
+
+// Results of starting an events session, see the startSession command of the Events and State WebSocket API.
+const (
+	SessionNew     = "new"
+	SessionResumed = "resumed"
+	SessionFailed  = "failed"
+)
+
+// The requests waiting for events last up to a minute, the default buckets stop at 10 seconds.
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the requests served by the webserver, by route, method and status code.",
+		Buckets:   durationBuckets,
+	}, []string{"handler", "method", "code"})
+
+	upstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Duration of the requests sent to the API gateway and the IDP, by endpoint, method and status code.",
+		Buckets:   durationBuckets,
+	}, []string{"api", "endpoint", "method", "code"})
+
+	upstreamRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_errors_total",
+		Help:      "Requests to the API gateway and the IDP that failed to get a response or got an error status.",
+	}, []string{"api", "endpoint", "method"})
+
+	tokenRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_requests_total",
+		Help:      "Token requests sent to the IDP, by credentials flow, kind (issue, renewal, reissue) and result.",
+	}, []string{"flow", "kind", "result"})
+
+	websocketSessions = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_sessions",
+		Help:      "Open Events and State WebSocket connections.",
+	})
+
+	websocketSessionStarts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_session_starts_total",
+		Help:      "Events sessions started, by result (new, resumed, failed).",
+	}, []string{"result"})
+
+	websocketReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_reconnects_total",
+		Help:      "Events sessions started again on a connection that already had a session.",
+	})
+
+	eventsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_received_total",
+		Help:      "Events received from the Events and State WebSocket API, by event type id.",
+	}, []string{"type"})
+
+	eventsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dropped_total",
+		Help:      "Events received from the Events and State WebSocket API but dropped because nobody was reading them.",
+	})
+)
+
+// Returns the handler of the /metrics endpoint, with the metrics of the webserver and of the Go runtime.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Measures the duration and the status code of the requests served by the handler of the given route.
+func InstrumentHandler(route string, handler http.Handler) http.Handler {
+	return promhttp.InstrumentHandlerDuration(httpRequestDuration.MustCurryWith(prometheus.Labels{"handler": route}), handler)
+}
+
+// Reports the number of app contexts of all sessions, read every time the metrics are collected.
+func RegisterAppContexts(count func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "app_contexts",
+		Help:      "App contexts of all sessions, one per user and management server.",
+	}, func() float64 {
+		return float64(count())
+	})
+}
+
+// Matches the ids in request paths, e.g. the id of a camera.
+var idPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$|^[0-9]+$`)
+
+// Records a request sent to the API gateway or the IDP. The ids of the path are replaced so that all the requests to the
+// same endpoint are counted together. A status code of -1 means no response was received.
+func ObserveUpstreamRequest(method, path string, statusCode int, duration time.Duration, err error) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idPattern.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	endpoint := strings.Join(segments, "/")
+
+	api := "gateway"
+	if strings.HasPrefix(path, "/idp/") {
+		api = "idp"
+	}
+
+	code := "none"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	upstreamRequestDuration.WithLabelValues(api, endpoint, method, code).Observe(duration.Seconds())
+	if err != nil {
+		upstreamRequestErrors.WithLabelValues(api, endpoint, method).Inc()
+	}
+}
+
+// Records a token request of the given kind.
+func ObserveTokenRequest(flow, kind string, err error) {
+	tokenRequests.WithLabelValues(flow, kind, result(err)).Inc()
+}
+
+// Records an Events and State WebSocket connection being opened.
+func WebsocketOpened() {
+	websocketSessions.Inc()
+}
+
+// Records an Events and State WebSocket connection being closed.
+func WebsocketClosed() {
+	websocketSessions.Dec()
+}
+
+// Records the start of an events session. Reconnect tells whether the connection had a session before.
+func ObserveSessionStart(result string, reconnect bool) {
+	websocketSessionStarts.WithLabelValues(result).Inc()
+	if reconnect {
+		websocketReconnects.Inc()
+	}
+}
+
+// Records an event received from the Events and State WebSocket API.
+func ObserveEventReceived(eventType string) {
+	eventsReceived.WithLabelValues(eventType).Inc()
+}
+
+// Records events dropped because the events buffer of a connection was full.
+func ObserveEventsDropped(count int) {
+	eventsDropped.Add(float64(count))
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
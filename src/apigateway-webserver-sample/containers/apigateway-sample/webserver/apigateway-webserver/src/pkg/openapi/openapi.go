@@ -0,0 +1,271 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the OpenAPI specification the documents follow.
+const Version = "3.0.3"
+
+// Parameters in the path of an operation, e.g. {username}.
+var pathParameterPattern = regexp.MustCompile(`{([^}]+)}`)
+
+// OpenAPI document generated from the operations served, with the schemas of their bodies read from the Go types.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+
+	// Go types of the schemas, to tell apart types of different packages with the same name
+	schemaTypes map[string]reflect.Type
+	// Error response of every operation
+	problem *response
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// JSON schema of a body, the subset of OpenAPI 3.0 needed by the Go types of the API.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Operation of the API, as registered by the router.
+type Operation struct {
+	// Name of the operation in the generated clients, e.g. listCameras
+	ID      string
+	Method  string
+	Path    string
+	Summary string
+	// Groups the operations in the document, e.g. "sessions"
+	Tag string
+	// Query parameters, all optional
+	Query []Parameter
+	// Zero value of the type of the request body, nil when the operation has no body
+	Request any
+	// Status of the successful responses, and zero value of the type of their body, nil when they have no body
+	Status   int
+	Response any
+}
+
+type Parameter struct {
+	Name        string
+	Description string
+}
+
+type operation struct {
+	Summary     string               `json:"summary"`
+	Tags        []string             `json:"tags,omitempty"`
+	OperationID string               `json:"operationId"`
+	Parameters  []*parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Creates a document whose operations are relative to the given server URL, e.g. /api/v1.
+// Every operation answers its errors with the given problem type, sent as problemContentType.
+func NewDocument(info Info, serverURL string, problem any, problemContentType string) *Document {
+	d := &Document{
+		OpenAPI:     Version,
+		Info:        info,
+		Servers:     []Server{{URL: serverURL}},
+		Paths:       map[string]map[string]*operation{},
+		Components:  components{Schemas: map[string]*Schema{}},
+		schemaTypes: map[string]reflect.Type{},
+	}
+	d.problem = &response{
+		Description: "Error, described by the problem details of RFC 7807",
+		Content:     map[string]*mediaType{problemContentType: {Schema: d.schemaOf(reflect.TypeOf(problem))}},
+	}
+	return d
+}
+
+// Adds an operation to the document. The fields of the request and response types are required unless their
+// JSON name has omitempty.
+func (d *Document) Add(op Operation) {
+	method := strings.ToLower(op.Method)
+	o := &operation{
+		Summary:     op.Summary,
+		OperationID: op.ID,
+		Responses:   map[string]*response{"default": d.problem},
+	}
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+	}
+
+	for _, match := range pathParameterPattern.FindAllStringSubmatch(op.Path, -1) {
+		o.Parameters = append(o.Parameters, &parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	for _, query := range op.Query {
+		o.Parameters = append(o.Parameters, &parameter{Name: query.Name, In: "query", Description: query.Description, Schema: &Schema{Type: "string"}})
+	}
+
+	if op.Request != nil {
+		o.RequestBody = &requestBody{
+			Required: true,
+			Content:  map[string]*mediaType{"application/json": {Schema: d.schemaOf(reflect.TypeOf(op.Request))}},
+		}
+	}
+
+	success := &response{Description: http.StatusText(op.Status)}
+	if op.Response != nil {
+		success.Content = map[string]*mediaType{"application/json": {Schema: d.schemaOf(reflect.TypeOf(op.Response))}}
+	}
+	o.Responses[strconv.Itoa(op.Status)] = success
+
+	if d.Paths[op.Path] == nil {
+		d.Paths[op.Path] = map[string]*operation{}
+	}
+	d.Paths[op.Path][method] = o
+}
+
+func (d *Document) ToJSON() (string, error) {
+	jsonData, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal OpenAPI document: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Returns the schema of a Go type as encoded by encoding/json. Named structs are added to the components of the document
+// and referenced.
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		// Any JSON value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + d.addSchema(t)}
+	}
+	return &Schema{}
+}
+
+// Adds the schema of a named struct to the components once, and returns its name in the components.
+func (d *Document) addSchema(t reflect.Type) string {
+	name := t.Name()
+	if known, ok := d.schemaTypes[name]; ok && known != t {
+		// Same name in another package, e.g. vms.Event and events.Event
+		name = strings.ReplaceAll(t.String(), ".", "_")
+	}
+	if _, ok := d.schemaTypes[name]; ok {
+		return name
+	}
+
+	// Registered before reading the fields, so types referring to themselves end
+	d.schemaTypes[name] = t
+	d.Components.Schemas[name] = d.structSchema(t)
+	return name
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(schema, t)
+	return schema
+}
+
+// Adds the exported fields of a struct to the schema, and the fields of its embedded structs like encoding/json does.
+func (d *Document) addFields(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			d.addFields(schema, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = d.schemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
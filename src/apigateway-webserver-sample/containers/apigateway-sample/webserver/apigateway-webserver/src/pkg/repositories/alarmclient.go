@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"iter"
+	"net/http"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/repositories/base"
+)
+
+// Interface for implementing the alarms api repository
+type AlarmsRepository interface {
+	// Iterate over the alarms matching the given filter, requesting them page by page. A nil filter returns all alarms
+	ListAlarms(ctx context.Context, s vms.Server, t vms.Token, filter *vms.AlarmFilter) iter.Seq2[*vms.Alarm, error]
+	// Query a single alarm by id
+	RequestAlarm(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.Alarm, error)
+	// Change the state, owner or comment of an alarm
+	UpdateAlarm(ctx context.Context, s vms.Server, t vms.Token, id string, update *vms.AlarmUpdate) (*vms.Alarm, error)
+}
+
+// The alarms api follows the same request and response conventions as the configuration api
+type alarmsRepository struct {
+	configClient
+}
+
+func NewAlarmsRepository() AlarmsRepository {
+	return &alarmsRepository{
+		configClient: configClient{HttpBaseRepository: base.NewHttpBaseRepository()},
+	}
+}
+
+func (ar *alarmsRepository) ListAlarms(ctx context.Context, s vms.Server, t vms.Token, filter *vms.AlarmFilter) iter.Seq2[*vms.Alarm, error] {
+	// Build the request url
+	requestUrl, err := newGatewayRequestUrl(s, constants.Alarms)
+	if err != nil {
+		return func(yield func(*vms.Alarm, error) bool) {
+			yield(nil, err)
+		}
+	}
+	requestUrl.RawQuery = filter.Query().Encode()
+	return base.ListFromArgs[vms.Alarm](ctx, ar.HttpBaseRepository, requestUrl, t, base.DefaultPageSize)
+}
+
+func (ar *alarmsRepository) RequestAlarm(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.Alarm, error) {
+	return doConfigItem[vms.Alarm](ctx, ar.configClient, s, t, http.MethodGet, itemPath(constants.Alarms, id), "", nil)
+}
+
+func (ar *alarmsRepository) UpdateAlarm(ctx context.Context, s vms.Server, t vms.Token, id string, update *vms.AlarmUpdate) (*vms.Alarm, error) {
+	return doConfigItem[vms.Alarm](ctx, ar.configClient, s, t, http.MethodPatch, itemPath(constants.Alarms, id), "", update)
+}
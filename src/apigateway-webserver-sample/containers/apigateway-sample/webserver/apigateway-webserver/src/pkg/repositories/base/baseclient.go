@@ -5,8 +5,6 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
-	"net/url"
-	"strings"
 	"time"
 )
 
@@ -17,24 +15,30 @@ type BaseRepository struct {
 func NewBaseRepository() BaseRepository {
 	return BaseRepository{
 		client: &http.Client{
-			Timeout: 2 * time.Minute,
+			Timeout:   2 * time.Minute,
+			Transport: newTransport(),
 		},
 	}
 }
 
-// Configures the request URL and sets a transport. This must be done once per request.
-func (br *BaseRepository) setRequestTransport(requestUrl *url.URL) {
-	br.client.Transport = &http.Transport{
+// Creates the transport of a repository, supporting both encrypted and unencrypted communication.
+// It is shared by the concurrent requests of the repository, whatever server they are sent to: the TLS server name is
+// taken from the address dialed, and connections are reused per address.
+func newTransport() *http.Transport {
+	return &http.Transport{
 		IdleConnTimeout: 30 * time.Second,
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 			dialer := net.Dialer{}
 			return dialer.DialContext(ctx, network, addr)
 		},
 		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
 			dialer := tls.Dialer{
 				Config: &tls.Config{
-					ServerName:         requestUrl.Hostname(),
-					InsecureSkipVerify: strings.ToLower(requestUrl.Scheme) == "http",
+					ServerName: host,
 				},
 			}
 			return dialer.DialContext(ctx, network, addr)
@@ -4,11 +4,18 @@ import (
 	"context"
 	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 
 	"apigateway-webserver/src/pkg/constants/enums"
 	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/logging"
+	"apigateway-webserver/src/pkg/metrics"
+	"apigateway-webserver/src/pkg/tracing"
 )
 
 type HttpBaseRepository struct {
@@ -29,6 +36,49 @@ func NewHttpBaseRepository() HttpBaseRepository {
 // - body: Optional parameter that gets added as the request body if provided.
 // - contentType: The content type of the request body.
 func (hbr HttpBaseRepository) DoFromArgs(ctx context.Context, method string, requestUrl *url.URL, token vms.Token, body io.Reader, contentType enums.RequestContentType) ([]byte, int, error) {
+	request, err := hbr.newRequestFromArgs(ctx, method, requestUrl, token, body, contentType)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	// Execute request
+	return hbr.doFromRequest(request)
+}
+
+// Sends a request using the HTTP client, same as DoFromArgs, but authenticated with HTTP basic authentication instead of a token.
+// The username and password are form encoded first, as OAuth 2 requires for the client id and secret.
+func (hbr HttpBaseRepository) DoWithBasicAuth(ctx context.Context, method string, requestUrl *url.URL, username, password string, body io.Reader, contentType enums.RequestContentType) ([]byte, int, error) {
+	request, err := hbr.newRequestFromArgs(ctx, method, requestUrl, nil, body, contentType)
+	if err != nil {
+		return nil, -1, err
+	}
+	request.SetBasicAuth(url.QueryEscape(username), url.QueryEscape(password))
+
+	// Execute request
+	return hbr.doFromRequest(request)
+}
+
+// Sends a request using the HTTP client, same as DoFromArgs, but returns the response body without reading it.
+// This allows large responses to be decoded as a stream. The caller must close the returned body.
+func (hbr HttpBaseRepository) StreamFromArgs(ctx context.Context, method string, requestUrl *url.URL, token vms.Token, body io.Reader, contentType enums.RequestContentType) (io.ReadCloser, int, error) {
+	request, err := hbr.newRequestFromArgs(ctx, method, requestUrl, token, body, contentType)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	// Execute request
+	resp, err := hbr.sendRequest(request)
+	if err != nil {
+		if resp != nil {
+			return nil, resp.StatusCode, err
+		}
+		return nil, -1, err
+	}
+	return resp.Body, resp.StatusCode, nil
+}
+
+// Creates the request from the given arguments, see DoFromArgs.
+func (hbr HttpBaseRepository) newRequestFromArgs(ctx context.Context, method string, requestUrl *url.URL, token vms.Token, body io.Reader, contentType enums.RequestContentType) (*http.Request, error) {
 	if body == nil {
 		body = http.NoBody
 	}
@@ -36,25 +86,28 @@ func (hbr HttpBaseRepository) DoFromArgs(ctx context.Context, method string, req
 	// Create request from given arguments
 	request, err := http.NewRequestWithContext(ctx, method, requestUrl.String(), body)
 	if err != nil {
-		return nil, -1, err
+		return nil, err
 	}
 
 	// Set the content type
 	if err := hbr.setContentType(request, contentType); err != nil {
-		return nil, -1, err
+		return nil, err
+	}
+
+	// Let the requests be followed from the webserver logs to the VMS logs
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		request.Header.Set(logging.RequestIDHeader, requestID)
 	}
 
 	// Check if the token was provided and add it to the request header
 	if token != nil {
 		bearerToken, err := token.DispatchToken(ctx)
 		if err != nil {
-			return nil, -1, err
+			return nil, err
 		}
 		request.Header.Set("Authorization", "Bearer "+bearerToken)
 	}
-
-	// Execute request
-	return hbr.doFromRequest(request)
+	return request, nil
 }
 
 // Sets the content type in the request header based on the given content type enum.
@@ -73,23 +126,17 @@ func (hbr HttpBaseRepository) setContentType(request *http.Request, contentType
 
 // Executes any HTTP request and returns the response as bytes.
 func (hbr HttpBaseRepository) doFromRequest(request *http.Request) ([]byte, int, error) {
-	// Set the request transport to support both encrypted and unencrypted communication
-	hbr.setRequestTransport(request.URL)
-
-	// Execute request
-	resp, err := hbr.client.Do(request)
+	resp, err := hbr.sendRequest(request)
 	if err != nil {
+		if resp != nil {
+			return nil, resp.StatusCode, err
+		}
 		return nil, -1, err
 	}
 
 	// Ensure the response body is closed before exiting the function
 	defer resp.Body.Close()
 
-	// Check the status code and return it as an error if it is not OK
-	if resp.StatusCode >= 400 && resp.StatusCode <= 511 {
-		return nil, resp.StatusCode, errors.New(resp.Status)
-	}
-
 	// Read the body and convert it to bytes
 	// If the body is empty, return an empty array of bytes
 	bytes, err := io.ReadAll(resp.Body)
@@ -100,3 +147,60 @@ func (hbr HttpBaseRepository) doFromRequest(request *http.Request) ([]byte, int,
 	// Success: return body content and status code
 	return bytes, resp.StatusCode, nil
 }
+
+// Executes any HTTP request and returns the response with its body still open.
+// If the status code indicates an error, the body is closed and the response is returned along with the error.
+// Every request is sent in a client span, with the trace context in its headers. Its duration and status are logged
+// and recorded in the metrics of its endpoint.
+func (hbr HttpBaseRepository) sendRequest(request *http.Request) (*http.Response, error) {
+	ctx, span := tracing.StartClient(request.Context(), request.Method+" "+request.URL.Path,
+		semconv.HTTPRequestMethodKey.String(request.Method),
+		semconv.URLFull(logging.RedactURL(request.URL)),
+		semconv.ServerAddress(request.URL.Hostname()),
+	)
+	request = request.WithContext(ctx)
+	tracing.Inject(ctx, request.Header)
+
+	start := time.Now()
+	resp, err := hbr.send(request)
+	duration := time.Since(start)
+	statusCode := -1
+	if resp != nil {
+		statusCode = resp.StatusCode
+		span.SetAttributes(semconv.HTTPResponseStatusCode(statusCode))
+	}
+	tracing.End(span, err)
+	metrics.ObserveUpstreamRequest(request.Method, request.URL.Path, statusCode, duration, err)
+
+	attrs := []slog.Attr{
+		slog.String("method", request.Method),
+		slog.String("url", logging.RedactURL(request.URL)),
+		slog.Int("status", statusCode),
+		slog.Int64("durationMs", duration.Milliseconds()),
+	}
+	// Client errors are expected, e.g. when probing the features of a server, only failures of the VMS are warnings
+	level := slog.LevelDebug
+	if err != nil {
+		if statusCode < 0 || statusCode >= 500 {
+			level = slog.LevelWarn
+		}
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	logger.LogAttrs(request.Context(), level, "VMS request sent", attrs...)
+	return resp, err
+}
+
+func (hbr HttpBaseRepository) send(request *http.Request) (*http.Response, error) {
+	// Execute request
+	resp, err := hbr.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the status code and return it as an error if it is not OK
+	if resp.StatusCode >= 400 && resp.StatusCode <= 511 {
+		resp.Body.Close()
+		return resp, errors.New(resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,178 @@
+package base
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// Number of items requested per page when no page size is given.
+const DefaultPageSize = 100
+
+// Number of pages read at most from a list endpoint, so that an endpoint paging wrongly can't be read forever.
+const MaxPages = 1000
+
+// Iterates over all the items of a REST API list endpoint (e.g. /api/rest/v1/cameras).
+// The items are requested one page at a time using the `page` and `size` query parameters,
+// and each page is decoded as a stream so only one item is kept in memory at a time.
+// The iteration ends when:
+// - the last page has been read (a page with fewer items than the page size, or an empty page),
+// - a page starts with the same item as the previous page, the endpoint ignores the paging parameters,
+// - the caller stops ranging over the iterator,
+// - the context is cancelled, a request fails or MaxPages pages were read. In this case the error is yielded as the
+// last element.
+func ListFromArgs[T any](ctx context.Context, hbr HttpBaseRepository, requestUrl *url.URL, token vms.Token, pageSize int) iter.Seq2[*T, error] {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	return func(yield func(*T, error) bool) {
+		// Id of the first item of the previous page
+		var previousFirst []byte
+		for page := 0; ; page++ {
+			if page == MaxPages {
+				yield(nil, fmt.Errorf("stopped listing %s after %d pages", requestUrl.Path, MaxPages))
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			// Build the page url without modifying the given one
+			pageUrl := *requestUrl
+			query := pageUrl.Query()
+			query.Set("page", strconv.Itoa(page))
+			query.Set("size", strconv.Itoa(pageSize))
+			pageUrl.RawQuery = query.Encode()
+
+			// Execute Get request
+			body, _, err := hbr.StreamFromArgs(ctx, http.MethodGet, &pageUrl, token, nil, enums.None)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			count, first, stopped, err := decodeArrayStream(ctx, body, previousFirst, yield)
+			body.Close()
+			if stopped {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			// A short page is the last one. A page bigger than requested means the endpoint doesn't support paging
+			// and returned everything at once.
+			if count != pageSize {
+				return
+			}
+			previousFirst = first
+		}
+	}
+}
+
+// Decodes the `array` field of a list response one item at a time, passing every item to yield.
+// Returns the number of decoded items, the id of the first one and whether yield asked to stop. When the first item has
+// the id previousFirst, the page repeats the previous one: nothing is yielded and the count is 0.
+func decodeArrayStream[T any](ctx context.Context, r io.Reader, previousFirst []byte, yield func(*T, error) bool) (int, []byte, bool, error) {
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return 0, nil, false, err
+	}
+
+	count := 0
+	var first []byte
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return count, first, false, err
+		}
+
+		// Skip every field other than the array of items
+		if key != "array" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return count, first, false, err
+			}
+			continue
+		}
+
+		// An empty list may be sent as null
+		token, err := dec.Token()
+		if err != nil {
+			return count, first, false, err
+		}
+		if token == nil {
+			continue
+		}
+		if token != json.Delim('[') {
+			return count, first, false, fmt.Errorf("unexpected JSON token %v, expected [", token)
+		}
+		for dec.More() {
+			if err := ctx.Err(); err != nil {
+				return count, first, false, err
+			}
+
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return count, first, false, err
+			}
+			if count == 0 {
+				first = itemId(raw)
+				if previousFirst != nil && bytes.Equal(first, previousFirst) {
+					logger.WarnContext(ctx, "List page repeating the previous page, the endpoint doesn't page", "id", string(first))
+					return 0, first, false, nil
+				}
+			}
+
+			item := new(T)
+			if err := json.Unmarshal(raw, item); err != nil {
+				return count, first, false, err
+			}
+			count++
+
+			if !yield(item, nil) {
+				return count, first, true, nil
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return count, first, false, err
+		}
+	}
+	return count, first, false, nil
+}
+
+// Returns the id of a list item, or the whole item when it has none.
+func itemId(raw json.RawMessage) []byte {
+	var item struct {
+		Id json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &item); err == nil && len(item.Id) > 0 {
+		return item.Id
+	}
+	return raw
+}
+
+// Reads the next JSON token and checks it is the given delimiter.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("unexpected JSON token %v, expected %v", token, delim)
+	}
+	return nil
+}
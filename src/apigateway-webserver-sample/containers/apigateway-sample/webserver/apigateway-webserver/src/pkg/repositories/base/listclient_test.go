@@ -0,0 +1,138 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type listItem struct {
+	Id int `json:"id"`
+}
+
+// List endpoint answering every page with the body at its index, and an empty page after the last one.
+// Records the page numbers requested.
+func newListServer(t *testing.T, pages []string) (*url.URL, func() []int) {
+	t.Helper()
+	var mu sync.Mutex
+	requested := []int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || r.URL.Query().Get("size") != "2" {
+			http.Error(w, "bad paging parameters", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requested = append(requested, page)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if page >= len(pages) {
+			w.Write([]byte(`{"array":[]}`))
+			return
+		}
+		w.Write([]byte(pages[page]))
+	}))
+	t.Cleanup(server.Close)
+
+	requestUrl, _ := url.Parse(server.URL + "/api/rest/v1/cameras")
+	return requestUrl, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int{}, requested...)
+	}
+}
+
+func TestListFromArgsPaging(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     []string
+		items     []int
+		requested []int
+		fails     bool
+	}{
+		{"several pages", []string{
+			`{"array":[{"id":1},{"id":2}]}`,
+			`{"array":[{"id":3},{"id":4}]}`,
+			`{"array":[{"id":5}]}`,
+		}, []int{1, 2, 3, 4, 5}, []int{0, 1, 2}, false},
+		{"empty last page", []string{
+			`{"array":[{"id":1},{"id":2}]}`,
+			`{"array":[{"id":3},{"id":4}]}`,
+		}, []int{1, 2, 3, 4}, []int{0, 1, 2}, false},
+		{"null last page", []string{
+			`{"array":[{"id":1},{"id":2}]}`,
+			`{"other":{"a":[1]},"array":null}`,
+		}, []int{1, 2}, []int{0, 1}, false},
+		{"endpoint repeating the first page", []string{
+			`{"array":[{"id":1},{"id":2}]}`,
+			`{"array":[{"id":1},{"id":2}]}`,
+		}, []int{1, 2}, []int{0, 1}, false},
+		{"endpoint returning everything at once", []string{
+			`{"array":[{"id":1},{"id":2},{"id":3}]}`,
+		}, []int{1, 2, 3}, []int{0}, false},
+		{"invalid JSON in the middle of a page", []string{
+			`{"array":[{"id":1},{"id":2}]}`,
+			`{"array":[{"id":3},{"id":`,
+		}, []int{1, 2, 3}, []int{0, 1}, true},
+		{"item of the wrong type", []string{
+			`{"array":[{"id":1},{"id":"two"}]}`,
+		}, []int{1}, []int{0}, true},
+		{"not a list", []string{
+			`[{"id":1}]`,
+		}, []int{}, []int{0}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requestUrl, requested := newListServer(t, test.pages)
+
+			items := []int{}
+			var lastErr error
+			for item, err := range ListFromArgs[listItem](context.Background(), NewHttpBaseRepository(), requestUrl, nil, 2) {
+				if lastErr != nil {
+					t.Fatal("iteration went on after an error")
+				}
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				items = append(items, item.Id)
+			}
+
+			if !reflect.DeepEqual(items, test.items) {
+				t.Errorf("items %v, expected %v", items, test.items)
+			}
+			if pages := requested(); !reflect.DeepEqual(pages, test.requested) {
+				t.Errorf("pages %v requested, expected %v", pages, test.requested)
+			}
+			if (lastErr != nil) != test.fails {
+				t.Errorf("error %v, expected one: %t", lastErr, test.fails)
+			}
+		})
+	}
+}
+
+// Stopping the iteration stops requesting pages.
+func TestListFromArgsStopsWithTheCaller(t *testing.T) {
+	requestUrl, requested := newListServer(t, []string{
+		`{"array":[{"id":1},{"id":2}]}`,
+		`{"array":[{"id":3},{"id":4}]}`,
+	})
+
+	for item, err := range ListFromArgs[listItem](context.Background(), NewHttpBaseRepository(), requestUrl, nil, 2) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Id == 2 {
+			break
+		}
+	}
+	if pages := requested(); !reflect.DeepEqual(pages, []int{0}) {
+		t.Fatalf("pages %v requested, expected only the first one", pages)
+	}
+}
@@ -0,0 +1,6 @@
+package base
+
+import "apigateway-webserver/src/pkg/logging"
+
+// Logger of the package, the requests to the VMS are logged with the repositories.
+var logger = logging.For("repositories")
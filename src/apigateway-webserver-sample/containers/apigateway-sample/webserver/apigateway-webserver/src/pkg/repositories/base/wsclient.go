@@ -2,6 +2,7 @@ package base
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/url"
 	"sync"
@@ -9,8 +10,10 @@ import (
 
 	"github.com/coder/websocket"
 	"github.com/coder/websocket/wsjson"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 
 	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/tracing"
 )
 
 type WsBaseRepository struct {
@@ -39,13 +42,10 @@ func (wbr *WsBaseRepository) MakeConnect(ctx context.Context, requestUrl *url.UR
 	var ctxWithCancel context.Context
 
 	// Close the connection if it is already open
-	if err := wbr.CloseConnect(); err != nil {
+	if err := wbr.closeConnect(); err != nil {
 		return err
 	}
 
-	// Set the request transport to support both encrypted and unencrypted communication
-	wbr.setRequestTransport(requestUrl)
-
 	header := make(http.Header)
 	// Check if the token was provided and add it to the request header
 	if token != nil {
@@ -56,25 +56,34 @@ func (wbr *WsBaseRepository) MakeConnect(ctx context.Context, requestUrl *url.UR
 		header.Set("Authorization", "Bearer "+bearerToken)
 	}
 
-	// Perform a WebSocket handshake
-	wbr.conn, _, err = websocket.Dial(ctx, requestUrl.String(), &websocket.DialOptions{
+	// Perform a WebSocket handshake, in a client span with the trace context in its headers
+	dialCtx, span := tracing.StartClient(ctx, "WebSocket connect", semconv.URLFull(requestUrl.String()), semconv.ServerAddress(requestUrl.Hostname()))
+	tracing.Inject(dialCtx, header)
+	wbr.conn, _, err = websocket.Dial(dialCtx, requestUrl.String(), &websocket.DialOptions{
 		HTTPClient: wbr.client,
 		HTTPHeader: header,
 		Host:       requestUrl.Host,
 	})
+	tracing.End(span, err)
 	if err != nil {
 		return err
 	}
 
 	// Start a ping pong chat with the server
 	ctxWithCancel, wbr.cancel = context.WithCancel(ctx)
-	wbr.keepAlive(ctxWithCancel)
+	wbr.keepAlive(ctxWithCancel, wbr.conn)
 
 	return nil
 }
 
 // Closes the WebSocket connection if it is open
 func (wbr *WsBaseRepository) CloseConnect() error {
+	wbr.mu.Lock()
+	defer wbr.mu.Unlock()
+	return wbr.closeConnect()
+}
+
+func (wbr *WsBaseRepository) closeConnect() error {
 	if wbr.conn == nil {
 		return nil
 	}
@@ -110,7 +119,24 @@ func (wbr *WsBaseRepository) ReadResponse(ctx context.Context, v any) error {
 	return wsjson.Read(ctx, wbr.conn, v)
 }
 
-func (wbr *WsBaseRepository) keepAlive(ctx context.Context) {
+// Returns a function reading responses from the current WebSocket connection, even after a new connection is made.
+// The function doesn't lock, so requests can be sent while it waits for a message. Only one reader must use it at a time.
+// Cancelling the context given to the function closes the connection.
+func (wbr *WsBaseRepository) ConnReader() func(ctx context.Context, v any) error {
+	wbr.mu.Lock()
+	conn := wbr.conn
+	wbr.mu.Unlock()
+
+	return func(ctx context.Context, v any) error {
+		if conn == nil {
+			return errors.New("websocket connection is not open")
+		}
+		return wsjson.Read(ctx, conn, v)
+	}
+}
+
+// Pings the given connection until the context is cancelled, the connection is passed as wbr.conn changes on reconnects.
+func (wbr *WsBaseRepository) keepAlive(ctx context.Context, conn *websocket.Conn) {
 	// Keep the server alive - ping every minute
 	wbr.wg.Add(1)
 	go func() {
@@ -126,7 +152,7 @@ func (wbr *WsBaseRepository) keepAlive(ctx context.Context) {
 			}
 
 			// send ping
-			err := wbr.conn.Ping(ctx)
+			err := conn.Ping(ctx)
 			if err != nil {
 				return
 			}
@@ -135,3 +161,34 @@ func (wbr *WsBaseRepository) keepAlive(ctx context.Context) {
 		}
 	}()
 }
+
+// Performs a WebSocket handshake with the given URL and closes the connection right away, to find out whether the
+// endpoint exists. Returns the status of the handshake response, 101 when it succeeded. Only failing to get a response
+// returns an error.
+func (br BaseRepository) ProbeWebSocket(ctx context.Context, requestUrl *url.URL, token vms.Token) (int, error) {
+	header := make(http.Header)
+	if token != nil {
+		bearerToken, err := token.DispatchToken(ctx)
+		if err != nil {
+			return -1, err
+		}
+		header.Set("Authorization", "Bearer "+bearerToken)
+	}
+
+	dialCtx, span := tracing.StartClient(ctx, "WebSocket probe", semconv.URLFull(requestUrl.String()), semconv.ServerAddress(requestUrl.Hostname()))
+	tracing.Inject(dialCtx, header)
+	conn, response, err := websocket.Dial(dialCtx, requestUrl.String(), &websocket.DialOptions{
+		HTTPClient: br.client,
+		HTTPHeader: header,
+		Host:       requestUrl.Host,
+	})
+	tracing.End(span, err)
+	if err == nil {
+		conn.Close(websocket.StatusNormalClosure, "")
+		return http.StatusSwitchingProtocols, nil
+	}
+	if response != nil {
+		return response.StatusCode, nil
+	}
+	return -1, err
+}
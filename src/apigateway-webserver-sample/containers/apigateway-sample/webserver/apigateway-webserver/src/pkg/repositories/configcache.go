@@ -0,0 +1,242 @@
+package repositories
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// Number of change sets kept for every server, the oldest ones are dropped first.
+const configChangeSetsLimit = 1000
+
+// Interface for persisting the configuration snapshot of every server and the change sets between its snapshots. The
+// snapshots and change sets of every identity reading the server are kept apart, see vms.User.Identity.
+type ConfigCacheRepository interface {
+	// Read the last snapshot of a server read by the identity. Returns nil when it was never cached
+	LoadSnapshot(server, identity string) (*vms.ConfigSnapshot, error)
+	// Store the snapshot of a server and identity, replacing the previous one, along with the changes from the previous
+	// snapshot. The change set gets the next sequence number of the server and identity. Empty change sets are not stored
+	SaveSnapshot(snapshot *vms.ConfigSnapshot, changes *vms.ConfigChangeSet) error
+	// Read the change sets of a server and identity with a sequence number greater than since, oldest first
+	ListChangeSets(server, identity string, since uint64) (*vms.ConfigChangeSets, error)
+}
+
+var (
+	configCacheInstance ConfigCacheRepository
+	configCacheOnce     sync.Once
+)
+
+// Returns the cache shared by all app contexts. The cache file can only be opened once per process.
+// The file path is read from the CONFIG_CACHE_PATH environment variable. When the file can't be opened,
+// the cache is only kept in memory and is lost on restart.
+func GetConfigCacheRepository() ConfigCacheRepository {
+	configCacheOnce.Do(func() {
+		path := os.Getenv(constants.ConfigCachePathEnv)
+		if path == "" {
+			path = constants.ConfigCacheDefaultPath
+		}
+
+		repository, err := newBoltConfigCacheRepository(path)
+		if err != nil {
+			logger.Warn("Opening the configuration cache, keeping it in memory only", "path", path, "error", err)
+			configCacheInstance = newMemoryConfigCacheRepository()
+			return
+		}
+		configCacheInstance = repository
+	})
+	return configCacheInstance
+}
+
+// Every server has its own bucket, with a nested bucket for every identity holding the last snapshot and a nested
+// bucket of change sets by sequence number.
+var (
+	configSnapshotKey   = []byte("snapshot")
+	configChangesBucket = []byte("changes")
+)
+
+type boltConfigCacheRepository struct {
+	db *bolt.DB
+}
+
+func newBoltConfigCacheRepository(path string) (*boltConfigCacheRepository, error) {
+	// Fail instead of waiting forever when another process holds the file
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &boltConfigCacheRepository{db: db}, nil
+}
+
+func sequenceKey(sequence uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, sequence)
+	return key
+}
+
+// Returns the bucket of the identity in the bucket of the server, nil when there is none.
+func identityBucket(tx *bolt.Tx, server, identity string) *bolt.Bucket {
+	serverBucket := tx.Bucket([]byte(server))
+	if serverBucket == nil {
+		return nil
+	}
+	return serverBucket.Bucket([]byte(identity))
+}
+
+func (bccr *boltConfigCacheRepository) LoadSnapshot(server, identity string) (*vms.ConfigSnapshot, error) {
+	var snapshot *vms.ConfigSnapshot
+	err := bccr.db.View(func(tx *bolt.Tx) error {
+		bucket := identityBucket(tx, server, identity)
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get(configSnapshotKey)
+		if data == nil {
+			return nil
+		}
+		snapshot = &vms.ConfigSnapshot{}
+		return json.Unmarshal(data, snapshot)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (bccr *boltConfigCacheRepository) SaveSnapshot(snapshot *vms.ConfigSnapshot, changes *vms.ConfigChangeSet) error {
+	snapshotData, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return bccr.db.Update(func(tx *bolt.Tx) error {
+		serverBucket, err := tx.CreateBucketIfNotExists([]byte(snapshot.Server))
+		if err != nil {
+			return err
+		}
+		bucket, err := serverBucket.CreateBucketIfNotExists([]byte(snapshot.Identity))
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(configSnapshotKey, snapshotData); err != nil {
+			return err
+		}
+		if changes == nil || changes.Empty() {
+			return nil
+		}
+
+		changesBucket, err := bucket.CreateBucketIfNotExists(configChangesBucket)
+		if err != nil {
+			return err
+		}
+		if changes.Sequence, err = changesBucket.NextSequence(); err != nil {
+			return err
+		}
+		changesData, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		if err := changesBucket.Put(sequenceKey(changes.Sequence), changesData); err != nil {
+			return err
+		}
+
+		// Sequence numbers have no gaps, dropping the change set at the limit keeps exactly the last ones
+		if changes.Sequence > configChangeSetsLimit {
+			return changesBucket.Delete(sequenceKey(changes.Sequence - configChangeSetsLimit))
+		}
+		return nil
+	})
+}
+
+func (bccr *boltConfigCacheRepository) ListChangeSets(server, identity string, since uint64) (*vms.ConfigChangeSets, error) {
+	changeSets := vms.NewConfigChangeSets()
+	err := bccr.db.View(func(tx *bolt.Tx) error {
+		bucket := identityBucket(tx, server, identity)
+		if bucket == nil {
+			return nil
+		}
+		changesBucket := bucket.Bucket(configChangesBucket)
+		if changesBucket == nil {
+			return nil
+		}
+
+		cursor := changesBucket.Cursor()
+		for key, data := cursor.Seek(sequenceKey(since + 1)); key != nil; key, data = cursor.Next() {
+			changeSet := &vms.ConfigChangeSet{}
+			if err := json.Unmarshal(data, changeSet); err != nil {
+				return err
+			}
+			changeSets.Add(changeSet)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changeSets, nil
+}
+
+// Cache used when the cache file can't be opened. Its maps are keyed by server and identity, see memoryCacheKey.
+type memoryConfigCacheRepository struct {
+	mu         sync.Mutex
+	snapshots  map[string]*vms.ConfigSnapshot
+	changeSets map[string][]*vms.ConfigChangeSet
+	sequences  map[string]uint64
+}
+
+func newMemoryConfigCacheRepository() *memoryConfigCacheRepository {
+	return &memoryConfigCacheRepository{
+		snapshots:  map[string]*vms.ConfigSnapshot{},
+		changeSets: map[string][]*vms.ConfigChangeSet{},
+		sequences:  map[string]uint64{},
+	}
+}
+
+func memoryCacheKey(server, identity string) string {
+	return server + "\x00" + identity
+}
+
+func (mccr *memoryConfigCacheRepository) LoadSnapshot(server, identity string) (*vms.ConfigSnapshot, error) {
+	mccr.mu.Lock()
+	defer mccr.mu.Unlock()
+	return mccr.snapshots[memoryCacheKey(server, identity)], nil
+}
+
+func (mccr *memoryConfigCacheRepository) SaveSnapshot(snapshot *vms.ConfigSnapshot, changes *vms.ConfigChangeSet) error {
+	mccr.mu.Lock()
+	defer mccr.mu.Unlock()
+
+	key := memoryCacheKey(snapshot.Server, snapshot.Identity)
+	mccr.snapshots[key] = snapshot
+	if changes == nil || changes.Empty() {
+		return nil
+	}
+
+	mccr.sequences[key]++
+	changes.Sequence = mccr.sequences[key]
+	changeSets := append(mccr.changeSets[key], changes)
+	if len(changeSets) > configChangeSetsLimit {
+		changeSets = changeSets[len(changeSets)-configChangeSetsLimit:]
+	}
+	mccr.changeSets[key] = changeSets
+	return nil
+}
+
+func (mccr *memoryConfigCacheRepository) ListChangeSets(server, identity string, since uint64) (*vms.ConfigChangeSets, error) {
+	mccr.mu.Lock()
+	defer mccr.mu.Unlock()
+
+	changeSets := vms.NewConfigChangeSets()
+	for _, changeSet := range mccr.changeSets[memoryCacheKey(server, identity)] {
+		if changeSet.Sequence > since {
+			changeSets.Add(changeSet)
+		}
+	}
+	return changeSets, nil
+}
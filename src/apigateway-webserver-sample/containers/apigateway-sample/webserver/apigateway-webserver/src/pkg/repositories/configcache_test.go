@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"path/filepath"
+	"testing"
+
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+func testSnapshot(identity string, cameraIDs ...string) *vms.ConfigSnapshot {
+	cameras := vms.NewCamerasList()
+	for _, id := range cameraIDs {
+		cameras.Add(&vms.Camera{ID: id, Name: id, LastModified: "2024-01-01T00:00:00Z"})
+	}
+	return vms.NewConfigSnapshot("vms", identity, cameras, vms.NewAnalyticEventTypes())
+}
+
+// The users of a server only read the snapshots and change sets of the items they were allowed to read.
+func TestConfigCacheKeepsIdentitiesApart(t *testing.T) {
+	bolt, err := newBoltConfigCacheRepository(filepath.Join(t.TempDir(), "config.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bolt.db.Close() })
+
+	for name, cr := range map[string]ConfigCacheRepository{"bolt": bolt, "memory": newMemoryConfigCacheRepository()} {
+		t.Run(name, func(t *testing.T) {
+			// The admin reads every camera, the guard only one of them, refreshing in turn
+			for range 3 {
+				for _, snapshot := range []*vms.ConfigSnapshot{testSnapshot("user:admin", "cam-1", "cam-2"), testSnapshot("user:guard", "cam-1")} {
+					previous, err := cr.LoadSnapshot(snapshot.Server, snapshot.Identity)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := cr.SaveSnapshot(snapshot, snapshot.Diff(previous)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			guard, err := cr.LoadSnapshot("vms", "user:guard")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(guard.Cameras) != 1 {
+				t.Fatalf("the guard reads %d cameras, expected the one it is allowed to read", len(guard.Cameras))
+			}
+			// Only the first refresh of each identity changed something
+			for identity, expected := range map[string]int{"user:admin": 2, "user:guard": 1} {
+				changeSets, err := cr.ListChangeSets("vms", identity, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(changeSets.ChangeSets) != 1 || len(changeSets.ChangeSets[0].Changes) != expected {
+					t.Fatalf("%s has %d change sets, expected one adding %d cameras", identity, len(changeSets.ChangeSets), expected)
+				}
+			}
+			if snapshot, _ := cr.LoadSnapshot("vms", "user:other"); snapshot != nil {
+				t.Fatal("an identity that never read the server has a snapshot")
+			}
+		})
+	}
+}
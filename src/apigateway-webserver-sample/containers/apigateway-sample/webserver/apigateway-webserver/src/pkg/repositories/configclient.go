@@ -0,0 +1,237 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/repositories/base"
+)
+
+// Interface for implementing a Configuration API repository for a single resource type (e.g. cameras, hardware, roles).
+// T is the entity every item of the resource collection is decoded into.
+type ConfigRepository[T any] interface {
+	// Iterate over all items of the resource, requesting them page by page
+	List(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*T, error]
+	// Query a single item by id
+	Get(ctx context.Context, s vms.Server, t vms.Token, id string) (*T, error)
+	// Create a new item (POST) and return it as created by the server
+	Create(ctx context.Context, s vms.Server, t vms.Token, item *T) (*T, error)
+	// Replace all the fields of an item (PUT)
+	Update(ctx context.Context, s vms.Server, t vms.Token, id string, item *T) (*T, error)
+	// Update only the given fields of an item (PATCH)
+	Patch(ctx context.Context, s vms.Server, t vms.Token, id string, fields any) (*T, error)
+	// Delete an item
+	Delete(ctx context.Context, s vms.Server, t vms.Token, id string) error
+	// Query the tasks available on an item. When the id is empty, the tasks available on the resource type are returned
+	ListTasks(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.ConfigTasks, error)
+	// Invoke a task on an item. When the id is empty, the task is invoked on the resource type
+	PerformTask(ctx context.Context, s vms.Server, t vms.Token, id string, task string, payload any) (*vms.ConfigTaskResult, error)
+}
+
+// Interface for implementing a Configuration API repository for the child items of a resource type
+// (e.g. the cameras of a hardware: /api/rest/v1/hardware/{id}/cameras).
+// Child items are read and updated through their own resource type once their id is known.
+type ConfigChildRepository[T any] interface {
+	// Iterate over all child items of the given parent item, requesting them page by page
+	List(ctx context.Context, s vms.Server, t vms.Token, parentID string) iter.Seq2[*T, error]
+	// Create a new child item under the given parent item
+	Create(ctx context.Context, s vms.Server, t vms.Token, parentID string, item *T) (*T, error)
+	// Query the tasks available on the child items of the given parent item
+	ListTasks(ctx context.Context, s vms.Server, t vms.Token, parentID string) (*vms.ConfigTasks, error)
+	// Invoke a task on the child items of the given parent item
+	PerformTask(ctx context.Context, s vms.Server, t vms.Token, parentID string, task string, payload any) (*vms.ConfigTaskResult, error)
+}
+
+// Interface for reading the state and result of tasks running asynchronously on the server.
+type ConfigTaskRepository interface {
+	// Query a task by id
+	RequestTask(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.ConfigTaskResult, error)
+}
+
+// Single item responses wrap the item in a `data` field, create and task responses use a `result` field.
+type configItemResponse[T any] struct {
+	Data   *T `json:"data"`
+	Result *T `json:"result"`
+}
+
+func (cir *configItemResponse[T]) item() *T {
+	if cir.Data != nil {
+		return cir.Data
+	}
+	return cir.Result
+}
+
+type configClient struct {
+	base.HttpBaseRepository
+}
+
+type configRepository[T any] struct {
+	configClient
+	resource string
+}
+
+type configChildRepository[T any] struct {
+	configClient
+	resource  string
+	childType string
+}
+
+type configTaskRepository struct {
+	configClient
+}
+
+func NewConfigRepository[T any](resource string) ConfigRepository[T] {
+	return &configRepository[T]{
+		configClient: configClient{HttpBaseRepository: base.NewHttpBaseRepository()},
+		resource:     resource,
+	}
+}
+
+func NewConfigChildRepository[T any](resource, childType string) ConfigChildRepository[T] {
+	return &configChildRepository[T]{
+		configClient: configClient{HttpBaseRepository: base.NewHttpBaseRepository()},
+		resource:     resource,
+		childType:    childType,
+	}
+}
+
+func NewConfigTaskRepository() ConfigTaskRepository {
+	return &configTaskRepository{
+		configClient: configClient{HttpBaseRepository: base.NewHttpBaseRepository()},
+	}
+}
+
+// Builds the escaped request path /api/rest/v1/{elements...}, skipping empty elements. Every element stays a single
+// segment of the path, whatever characters the id it holds contains.
+func configPath(elements ...string) string {
+	p := strings.TrimSuffix(constants.ConfigApi, "/")
+	for _, e := range elements {
+		if e != "" {
+			p += "/" + url.PathEscape(e)
+		}
+	}
+	return p
+}
+
+// Sends a request with an optional JSON body to the given configuration path and decodes the response into v.
+func (cc configClient) doJson(ctx context.Context, s vms.Server, t vms.Token, method, requestPath, rawQuery string, body any, v any) error {
+	// Build the request url
+	requestUrl, err := newGatewayRequestUrl(s, requestPath)
+	if err != nil {
+		return err
+	}
+	requestUrl.RawQuery = rawQuery
+
+	// Serialize the request body if any
+	contentType := enums.None
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+		contentType = enums.Json
+	}
+
+	// Execute request
+	response, _, err := cc.DoFromArgs(ctx, method, requestUrl, t, payload, contentType)
+	if err != nil {
+		return err
+	}
+
+	// Build the response data structure
+	if v == nil || len(response) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response, v)
+}
+
+// Sends a request and returns the single item contained in the response.
+func doConfigItem[T any](ctx context.Context, cc configClient, s vms.Server, t vms.Token, method, requestPath, rawQuery string, body any) (*T, error) {
+	d := new(configItemResponse[T])
+	if err := cc.doJson(ctx, s, t, method, requestPath, rawQuery, body, d); err != nil {
+		return nil, err
+	}
+	return d.item(), nil
+}
+
+func (cc configClient) listTasks(ctx context.Context, s vms.Server, t vms.Token, requestPath string) (*vms.ConfigTasks, error) {
+	tasks := vms.NewConfigTasks()
+	if err := cc.doJson(ctx, s, t, http.MethodGet, requestPath, constants.ConfigTasksParam+"&"+constants.ConfigNoDataParam, nil, tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (cc configClient) performTask(ctx context.Context, s vms.Server, t vms.Token, requestPath, task string, payload any) (*vms.ConfigTaskResult, error) {
+	query := url.Values{}
+	query.Set(constants.ConfigTaskParam, task)
+
+	// Tasks without arguments still expect a JSON object as body
+	if payload == nil {
+		payload = struct{}{}
+	}
+	return doConfigItem[vms.ConfigTaskResult](ctx, cc, s, t, http.MethodPost, requestPath, query.Encode(), payload)
+}
+
+func (cr *configRepository[T]) List(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*T, error] {
+	return listFromGateway[T](ctx, cr.HttpBaseRepository, s, t, configPath(cr.resource))
+}
+
+func (cr *configRepository[T]) Get(ctx context.Context, s vms.Server, t vms.Token, id string) (*T, error) {
+	return doConfigItem[T](ctx, cr.configClient, s, t, http.MethodGet, configPath(cr.resource, id), "", nil)
+}
+
+func (cr *configRepository[T]) Create(ctx context.Context, s vms.Server, t vms.Token, item *T) (*T, error) {
+	return doConfigItem[T](ctx, cr.configClient, s, t, http.MethodPost, configPath(cr.resource), "", item)
+}
+
+func (cr *configRepository[T]) Update(ctx context.Context, s vms.Server, t vms.Token, id string, item *T) (*T, error) {
+	return doConfigItem[T](ctx, cr.configClient, s, t, http.MethodPut, configPath(cr.resource, id), "", item)
+}
+
+func (cr *configRepository[T]) Patch(ctx context.Context, s vms.Server, t vms.Token, id string, fields any) (*T, error) {
+	return doConfigItem[T](ctx, cr.configClient, s, t, http.MethodPatch, configPath(cr.resource, id), "", fields)
+}
+
+func (cr *configRepository[T]) Delete(ctx context.Context, s vms.Server, t vms.Token, id string) error {
+	return cr.doJson(ctx, s, t, http.MethodDelete, configPath(cr.resource, id), "", nil, nil)
+}
+
+func (cr *configRepository[T]) ListTasks(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.ConfigTasks, error) {
+	return cr.listTasks(ctx, s, t, configPath(cr.resource, id))
+}
+
+func (cr *configRepository[T]) PerformTask(ctx context.Context, s vms.Server, t vms.Token, id string, task string, payload any) (*vms.ConfigTaskResult, error) {
+	return cr.performTask(ctx, s, t, configPath(cr.resource, id), task, payload)
+}
+
+func (ccr *configChildRepository[T]) List(ctx context.Context, s vms.Server, t vms.Token, parentID string) iter.Seq2[*T, error] {
+	return listFromGateway[T](ctx, ccr.HttpBaseRepository, s, t, configPath(ccr.resource, parentID, ccr.childType))
+}
+
+func (ccr *configChildRepository[T]) Create(ctx context.Context, s vms.Server, t vms.Token, parentID string, item *T) (*T, error) {
+	return doConfigItem[T](ctx, ccr.configClient, s, t, http.MethodPost, configPath(ccr.resource, parentID, ccr.childType), "", item)
+}
+
+func (ccr *configChildRepository[T]) ListTasks(ctx context.Context, s vms.Server, t vms.Token, parentID string) (*vms.ConfigTasks, error) {
+	return ccr.listTasks(ctx, s, t, configPath(ccr.resource, parentID, ccr.childType))
+}
+
+func (ccr *configChildRepository[T]) PerformTask(ctx context.Context, s vms.Server, t vms.Token, parentID string, task string, payload any) (*vms.ConfigTaskResult, error) {
+	return ccr.performTask(ctx, s, t, configPath(ccr.resource, parentID, ccr.childType), task, payload)
+}
+
+func (ctr *configTaskRepository) RequestTask(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.ConfigTaskResult, error) {
+	return doConfigItem[vms.ConfigTaskResult](ctx, ctr.configClient, s, t, http.MethodGet, configPath(constants.ConfigTasks, id), "", nil)
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// API gateway answering every request with an empty item, recording the escaped paths requested.
+type fakeGateway struct {
+	*httptest.Server
+	mu    sync.Mutex
+	paths []string
+}
+
+func newFakeGateway(t *testing.T) (*fakeGateway, *vms.Server) {
+	t.Helper()
+	fg := &fakeGateway{}
+	fg.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fg.mu.Lock()
+		fg.paths = append(fg.paths, r.URL.EscapedPath())
+		fg.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	t.Cleanup(fg.Close)
+
+	parsed, _ := url.Parse(fg.URL)
+	s := vms.NewServer(parsed)
+	s.ApiWellKnownUris.ApiGateways = []string{fg.URL + "/api/"}
+	return fg, s
+}
+
+func (fg *fakeGateway) requested() []string {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	return append([]string{}, fg.paths...)
+}
+
+// Ids are a single segment of the request path: they can't reach another resource with / or .. segments.
+func TestRequestPathsKeepIdsInTheirCollection(t *testing.T) {
+	fg, s := newFakeGateway(t)
+	ctx := context.Background()
+	cameras := NewConfigRepository[map[string]any](constants.CamerasResource)
+	groups := NewConfigChildRepository[map[string]any](constants.CameraGroupsResource, constants.CamerasResource)
+	alarms := NewAlarmsRepository()
+
+	tests := []struct {
+		name     string
+		request  func() error
+		expected string
+	}{
+		{"plain id", func() error {
+			_, err := cameras.Get(ctx, *s, nil, "c1")
+			return err
+		}, "/api/rest/v1/cameras/c1"},
+		{"id with slashes", func() error {
+			_, err := cameras.Get(ctx, *s, nil, "../roles/r1")
+			return err
+		}, "/api/rest/v1/cameras/..%2Froles%2Fr1"},
+		{"id with a backslash and a query", func() error {
+			_, err := cameras.Get(ctx, *s, nil, `..\roles?x=1`)
+			return err
+		}, "/api/rest/v1/cameras/..%5Croles%3Fx=1"},
+		{"parent id with slashes", func() error {
+			for _, err := range groups.List(ctx, *s, nil, "g1/../../roles") {
+				return err
+			}
+			return nil
+		}, "/api/rest/v1/cameraGroups/g1%2F..%2F..%2Froles/cameras"},
+		{"alarm id with slashes", func() error {
+			_, err := alarms.RequestAlarm(ctx, *s, nil, "../events")
+			return err
+		}, "/api/rest/v1/alarms/..%2Fevents"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(fg.requested())
+			if err := test.request(); err != nil {
+				t.Fatal(err)
+			}
+			paths := fg.requested()[before:]
+			if len(paths) != 1 || paths[0] != test.expected {
+				t.Fatalf("requested %v, expected %s", paths, test.expected)
+			}
+		})
+	}
+}
+
+// Ids made of dots only are refused before any request is sent.
+func TestRequestPathsRefuseDotIds(t *testing.T) {
+	fg, s := newFakeGateway(t)
+	ctx := context.Background()
+	cameras := NewConfigRepository[map[string]any](constants.CamerasResource)
+	bookmarks := NewGatewayRepository()
+
+	for _, id := range []string{".", ".."} {
+		if _, err := cameras.Get(ctx, *s, nil, id); err == nil {
+			t.Errorf("camera %q requested", id)
+		}
+		if err := cameras.Delete(ctx, *s, nil, id); err == nil {
+			t.Errorf("camera %q deleted", id)
+		}
+		if err := bookmarks.DeleteBookmark(ctx, *s, nil, id); err == nil {
+			t.Errorf("bookmark %q deleted", id)
+		}
+	}
+	if paths := fg.requested(); len(paths) != 0 {
+		t.Fatalf("requested %v", paths)
+	}
+}
@@ -2,18 +2,31 @@ package repositories
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 
 	"apigateway-webserver/src/pkg/constants"
 	"apigateway-webserver/src/pkg/entities/events"
 	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/metrics"
 	"apigateway-webserver/src/pkg/repositories/base"
+	"apigateway-webserver/src/pkg/tracing"
 )
 
-var commandRequestsCounter int = 0
+var commandRequestsCounter atomic.Int64
+
+// Number of received event messages kept while nobody is reading them. When full, the oldest messages are dropped so
+// that the connection is still read and command responses still reach their command.
+const eventsBufferSize = 100
 
 func newStartSessionRequest() *events.WsCommandRequest {
 	return &events.WsCommandRequest{
@@ -28,6 +41,13 @@ func newAddSubscriptionRequest(filters *events.SubscriptionFilters) *events.WsCo
 	}
 }
 
+func newRemoveSubscriptionRequest(subscriptionID string) *events.WsCommandRequest {
+	return &events.WsCommandRequest{
+		Command:        "removeSubscription",
+		SubscriptionID: subscriptionID,
+	}
+}
+
 func newSubscriptionFilter() *events.SubscriptionFilter {
 	return &events.SubscriptionFilter{
 		Modifier:      "include",
@@ -56,17 +76,133 @@ type WsEventsRepository interface {
 	// 2- Subscribe to a topic
 	RequestSubscribe(ctx context.Context, cameraID string, eventTypeID string) (*events.WsCommandResponse, error)
 
+	// 2- Subscribe to a topic using the given filters
+	RequestSubscribeFilters(ctx context.Context, filters *events.SubscriptionFilters) (*events.WsCommandResponse, error)
+
+	// 2- Remove a subscription by the id returned when it was added
+	RequestUnsubscribe(ctx context.Context, subscriptionID string) (*events.WsCommandResponse, error)
+
 	// 3- Read events from an open session
+	// Commands can be sent while waiting for events. Cancelling the context stops waiting without closing the session.
 	RequestEvents(ctx context.Context) (*events.AnalyticsEvents, error)
 
 	// 4- Close communication
 	RequestClose() error
 }
 
+// Reads every message of a connection and dispatches command responses to the waiting command and events to the events channel.
+type wsEventsPump struct {
+	mu      sync.Mutex
+	pending map[int]chan *events.WsCommandResponse
+	events  chan *events.AnalyticsEvents
+	// Closed when the connection can't be read anymore, err tells why
+	done chan struct{}
+	err  error
+}
+
+func newWsEventsPump() *wsEventsPump {
+	return &wsEventsPump{
+		pending: map[int]chan *events.WsCommandResponse{},
+		events:  make(chan *events.AnalyticsEvents, eventsBufferSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// Registers a command waiting for its response.
+func (p *wsEventsPump) wait(commandID int) chan *events.WsCommandResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ch := make(chan *events.WsCommandResponse, 1)
+	p.pending[commandID] = ch
+	return ch
+}
+
+func (p *wsEventsPump) forget(commandID int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, commandID)
+}
+
+// Queues received events without blocking the reader, dropping the oldest queued events when the buffer is full.
+// Only the reader queues events, room made for them can't be taken by another message.
+func (p *wsEventsPump) queue(aes *events.AnalyticsEvents) {
+	for {
+		select {
+		case p.events <- aes:
+			return
+		default:
+		}
+		select {
+		case dropped := <-p.events:
+			metrics.ObserveEventsDropped(len(dropped.Events))
+			logger.Warn("Events buffer full, dropping the oldest events", "count", len(dropped.Events))
+		default:
+		}
+	}
+}
+
+func (p *wsEventsPump) run(ctx context.Context, read func(ctx context.Context, v any) error) {
+	defer close(p.done)
+	for {
+		var message json.RawMessage
+		if err := read(ctx, &message); err != nil {
+			p.err = err
+			return
+		}
+
+		// Messages with events have no command id
+		var header struct {
+			CommandID *int            `json:"commandId"`
+			Events    json.RawMessage `json:"events"`
+		}
+		if err := json.Unmarshal(message, &header); err != nil {
+			p.err = err
+			return
+		}
+
+		if header.Events != nil {
+			aes := events.NewAnalyticsEvents()
+			if err := json.Unmarshal(message, aes); err != nil {
+				p.err = err
+				return
+			}
+			for _, event := range aes.Events {
+				metrics.ObserveEventReceived(event.Type)
+			}
+			p.queue(aes)
+			continue
+		}
+
+		if header.CommandID != nil {
+			wres := new(events.WsCommandResponse)
+			if err := json.Unmarshal(message, wres); err != nil {
+				p.err = err
+				return
+			}
+			p.mu.Lock()
+			if ch, ok := p.pending[wres.CommandID]; ok {
+				ch <- wres
+				delete(p.pending, wres.CommandID)
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
 type wsEventsRepository struct {
 	base.WsBaseRepository
+
+	// Session to resume when starting the next session, updated by the reader of the events while sessions are started and closed
+	sessionMu   sync.Mutex
 	sessionID   string
 	lastEventID string
+	// Whether a session was started on this repository before, starting another one is a reconnect
+	started bool
+
+	// Reader of the current connection, nil when no session is started
+	pumpMu   sync.Mutex
+	pump     *wsEventsPump
+	stopPump context.CancelFunc
 }
 
 func NewWsEventsRepository() WsEventsRepository {
@@ -77,19 +213,63 @@ func NewWsEventsRepository() WsEventsRepository {
 	}
 }
 
-func (wer *wsEventsRepository) sendCommand(ctx context.Context, wreq *events.WsCommandRequest) (*events.WsCommandResponse, error) {
-	commandRequestsCounter++
-	wreq.CommandID = commandRequestsCounter
+func (wer *wsEventsRepository) currentPump() (*wsEventsPump, error) {
+	wer.pumpMu.Lock()
+	defer wer.pumpMu.Unlock()
+	if wer.pump == nil {
+		return nil, errors.New("no events session started")
+	}
+	return wer.pump, nil
+}
+
+// Sends a command in its own span and waits for its response. The command, its status and duration are logged.
+func (wer *wsEventsRepository) sendCommand(ctx context.Context, wreq *events.WsCommandRequest) (wres *events.WsCommandResponse, err error) {
+	ctx, span := tracing.Start(ctx, "WebSocket "+wreq.Command, attribute.String("websocket.command", wreq.Command))
+	start := time.Now()
+	defer func() {
+		span.SetAttributes(attribute.Int("websocket.command_id", wreq.CommandID))
+		if wres != nil {
+			span.SetAttributes(attribute.Int("websocket.status", wres.Status))
+		}
+		tracing.End(span, err)
+
+		attrs := []slog.Attr{
+			slog.String("command", wreq.Command),
+			slog.Int("commandId", wreq.CommandID),
+			slog.Int64("durationMs", time.Since(start).Milliseconds()),
+		}
+		level := slog.LevelDebug
+		if wres != nil {
+			attrs = append(attrs, slog.Int("status", wres.Status))
+		}
+		if err != nil {
+			level = slog.LevelWarn
+			attrs = append(attrs, slog.Any("error", err))
+		}
+		logger.LogAttrs(ctx, level, "WebSocket command sent", attrs...)
+	}()
+
+	pump, err := wer.currentPump()
+	if err != nil {
+		return nil, err
+	}
+
+	wreq.CommandID = int(commandRequestsCounter.Add(1))
+	response := pump.wait(wreq.CommandID)
+	defer pump.forget(wreq.CommandID)
 
 	// Send request to the websocket server
 	if err := wer.SendRequest(ctx, wreq); err != nil {
 		return nil, err
 	}
 
-	// Read from the websocket until we get a command response (discarding any other messages - e.g., events)
-	wres := new(events.WsCommandResponse)
-	if err := wer.ReadResponse(ctx, wres); err != nil {
-		return nil, err
+	// Wait for the pump to receive the response of this command
+	select {
+	case wres = <-response:
+	case <-pump.done:
+		return nil, pump.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 
 	// Raise an exception with errorText if the status does not indicate success
@@ -111,26 +291,57 @@ func (wer *wsEventsRepository) RequestStartSession(ctx context.Context, s vms.Se
 	}
 	requestUrl.Path = constants.EventsWebsocket
 
+	wer.sessionMu.Lock()
+	reconnect := wer.started
+	wer.started = true
+	wer.sessionMu.Unlock()
+
 	// Dial
 	if err := wer.MakeConnect(ctx, requestUrl, t); err != nil {
+		metrics.ObserveSessionStart(metrics.SessionFailed, reconnect)
 		return nil, err
 	}
 
+	// Start reading the connection. The pump outlives the request starting the session, until the session is closed
+	pumpCtx, stopPump := context.WithCancel(context.Background())
+	pump := newWsEventsPump()
+	wer.pumpMu.Lock()
+	// The previous connection, if any, was closed when dialing
+	if wer.stopPump != nil {
+		wer.stopPump()
+		metrics.WebsocketClosed()
+	}
+	wer.pump, wer.stopPump = pump, stopPump
+	wer.pumpMu.Unlock()
+	metrics.WebsocketOpened()
+	go pump.run(pumpCtx, wer.ConnReader())
+
 	request := newStartSessionRequest()
 	// If the session id or last event id are empty or null then we start a new session
+	wer.sessionMu.Lock()
 	if strings.TrimSpace(wer.sessionID) == "" || strings.TrimSpace(wer.lastEventID) == "" {
 		wer.sessionID = ""
 		wer.lastEventID = ""
 	}
 	request.SessionID = wer.sessionID
 	request.LastEventID = wer.lastEventID
+	wer.sessionMu.Unlock()
 
 	// Send request, read response, and parse to object
 	wsCommandResponse, err := wer.sendCommand(ctx, request)
 	if err != nil {
+		metrics.ObserveSessionStart(metrics.SessionFailed, reconnect)
 		return nil, err
 	}
+	// 200 when the previous session was resumed, 201 when a new one was created
+	if wsCommandResponse.Status == 200 {
+		metrics.ObserveSessionStart(metrics.SessionResumed, reconnect)
+	} else {
+		metrics.ObserveSessionStart(metrics.SessionNew, reconnect)
+	}
+	wer.sessionMu.Lock()
 	wer.sessionID = wsCommandResponse.SessionID
+	wer.sessionMu.Unlock()
 	return wsCommandResponse, nil
 }
 
@@ -138,26 +349,59 @@ func (wer *wsEventsRepository) RequestSubscribe(ctx context.Context, cameraID st
 	filters := newSubscriptionFilters()
 	filters.Filters[0].SourceIDs = []string{cameraID}
 	filters.Filters[0].EventTypes = []string{eventTypeID}
+	return wer.RequestSubscribeFilters(ctx, filters)
+}
+
+func (wer *wsEventsRepository) RequestSubscribeFilters(ctx context.Context, filters *events.SubscriptionFilters) (*events.WsCommandResponse, error) {
 	request := newAddSubscriptionRequest(filters)
 
 	// Send request, read response, and parse to object
 	return wer.sendCommand(ctx, request)
 }
 
+func (wer *wsEventsRepository) RequestUnsubscribe(ctx context.Context, subscriptionID string) (*events.WsCommandResponse, error) {
+	return wer.sendCommand(ctx, newRemoveSubscriptionRequest(subscriptionID))
+}
+
 func (wer *wsEventsRepository) RequestEvents(ctx context.Context) (*events.AnalyticsEvents, error) {
-	aes := events.NewAnalyticsEvents()
-	if err := wer.ReadResponse(ctx, aes); err != nil {
+	pump, err := wer.currentPump()
+	if err != nil {
 		return nil, err
 	}
+
+	var aes *events.AnalyticsEvents
+	select {
+	case aes = <-pump.events:
+	case <-pump.done:
+		return nil, pump.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
 	// Get id of the last event
-	wer.lastEventID = aes.Events[len(aes.Events)-1].ID
+	if len(aes.Events) > 0 {
+		wer.sessionMu.Lock()
+		wer.lastEventID = aes.Events[len(aes.Events)-1].ID
+		wer.sessionMu.Unlock()
+	}
 	return aes, nil
 }
 
 func (wer *wsEventsRepository) RequestClose() error {
+	// Stop reading before closing, the pump ends once the connection is closed
+	wer.pumpMu.Lock()
+	if wer.stopPump != nil {
+		wer.stopPump()
+		metrics.WebsocketClosed()
+	}
+	wer.pump, wer.stopPump = nil, nil
+	wer.pumpMu.Unlock()
+
 	// Close and ignore any error
 	defer wer.CloseConnect()
+	wer.sessionMu.Lock()
 	wer.sessionID = ""
 	wer.lastEventID = ""
+	wer.sessionMu.Unlock()
 	return nil
 }
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// Connection returning the given messages in order, then waiting for the context to end.
+func fakeMessages(messages []string) func(ctx context.Context, v any) error {
+	next := 0
+	return func(ctx context.Context, v any) error {
+		if next == len(messages) {
+			<-ctx.Done()
+			return io.EOF
+		}
+		next++
+		return json.Unmarshal([]byte(messages[next-1]), v)
+	}
+}
+
+// Command responses reach their command when nobody reads the events, the oldest events are dropped instead.
+func TestEventsPumpAnswersCommandsWhenEventsAreNotRead(t *testing.T) {
+	messages := []string{}
+	for i := range 2 * eventsBufferSize {
+		messages = append(messages, fmt.Sprintf(`{"events":[{"id":"e%d","type":"t"}]}`, i))
+	}
+	messages = append(messages, `{"commandId":7,"status":200}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := newWsEventsPump()
+	response := p.wait(7)
+	go p.run(ctx, fakeMessages(messages))
+
+	select {
+	case wres := <-response:
+		if wres.Status != 200 {
+			t.Fatalf("status %d", wres.Status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no command response while the events buffer is full")
+	}
+
+	// The newest events are kept
+	if len(p.events) != eventsBufferSize {
+		t.Fatalf("%d event messages queued, expected %d", len(p.events), eventsBufferSize)
+	}
+	first := <-p.events
+	if expected := fmt.Sprintf("e%d", eventsBufferSize); first.Events[0].ID != expected {
+		t.Fatalf("oldest event queued %s, expected %s", first.Events[0].ID, expected)
+	}
+}
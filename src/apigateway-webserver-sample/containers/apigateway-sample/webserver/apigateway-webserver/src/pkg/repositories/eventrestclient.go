@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"iter"
+	"net/http"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/events"
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/repositories/base"
+)
+
+// Interface for implementing the events REST api repository
+type EventsRestRepository interface {
+	// Iterate over all user-defined events, requesting them page by page
+	ListUserDefinedEvents(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.UserDefinedEvent, error]
+	// Trigger a new event and return it as stored by the server
+	TriggerEvent(ctx context.Context, s vms.Server, t vms.Token, request *events.EventTriggerRequest) (*events.Event, error)
+	// Iterate over the events history, requesting it page by page. The additional event data is only included on demand
+	ListEvents(ctx context.Context, s vms.Server, t vms.Token, includeData bool) iter.Seq2[*events.Event, error]
+	// Query a single event by id
+	RequestEvent(ctx context.Context, s vms.Server, t vms.Token, id string) (*events.Event, error)
+}
+
+// The events REST api follows the same request and response conventions as the configuration api
+type eventsRestRepository struct {
+	configClient
+}
+
+func NewEventsRestRepository() EventsRestRepository {
+	return &eventsRestRepository{
+		configClient: configClient{HttpBaseRepository: base.NewHttpBaseRepository()},
+	}
+}
+
+func (ers *eventsRestRepository) ListUserDefinedEvents(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.UserDefinedEvent, error] {
+	return listFromGateway[vms.UserDefinedEvent](ctx, ers.HttpBaseRepository, s, t, constants.UserDefinedEvents)
+}
+
+func (ers *eventsRestRepository) TriggerEvent(ctx context.Context, s vms.Server, t vms.Token, request *events.EventTriggerRequest) (*events.Event, error) {
+	// Returns 202 Accepted with the triggered event in the data field
+	return doConfigItem[events.Event](ctx, ers.configClient, s, t, http.MethodPost, constants.Events, "", request)
+}
+
+func (ers *eventsRestRepository) ListEvents(ctx context.Context, s vms.Server, t vms.Token, includeData bool) iter.Seq2[*events.Event, error] {
+	// Build the request url
+	requestUrl, err := newGatewayRequestUrl(s, constants.Events)
+	if err != nil {
+		return func(yield func(*events.Event, error) bool) {
+			yield(nil, err)
+		}
+	}
+	if includeData {
+		requestUrl.RawQuery = "include=data"
+	}
+	return base.ListFromArgs[events.Event](ctx, ers.HttpBaseRepository, requestUrl, t, base.DefaultPageSize)
+}
+
+func (ers *eventsRestRepository) RequestEvent(ctx context.Context, s vms.Server, t vms.Token, id string) (*events.Event, error) {
+	return doConfigItem[events.Event](ctx, ers.configClient, s, t, http.MethodGet, itemPath(constants.Events, id), "", nil)
+}
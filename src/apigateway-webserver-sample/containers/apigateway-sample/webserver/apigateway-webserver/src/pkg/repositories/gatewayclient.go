@@ -3,8 +3,12 @@ package repositories
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"apigateway-webserver/src/pkg/constants"
 	"apigateway-webserver/src/pkg/constants/enums"
@@ -20,18 +24,101 @@ type GatewayRepository interface {
 	RequestEnabledCameras(ctx context.Context, s vms.Server, t vms.Token) (*vms.CamerasList, error)
 	// Query all analytic events types
 	RequestAnalyticEventTypes(ctx context.Context, s vms.Server, t vms.Token) (*vms.AnalyticEventTypes, error)
+	// Iterate over all cameras, requesting them page by page
+	ListCameras(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.Camera, error]
+	// Iterate over all analytic events types, requesting them page by page
+	ListAnalyticEventTypes(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.AnalyticEventType, error]
+	// Iterate over the top level camera groups, requesting them page by page
+	ListCameraGroups(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.CameraGroup, error]
+	// Iterate over the camera groups directly contained in a camera group
+	ListCameraGroupSubgroups(ctx context.Context, s vms.Server, t vms.Token, groupID string) iter.Seq2[*vms.CameraGroup, error]
+	// Iterate over the cameras directly contained in a camera group, without the cameras of its subgroups
+	ListCameraGroupCameras(ctx context.Context, s vms.Server, t vms.Token, groupID string) iter.Seq2[*vms.Camera, error]
+	// Query a single analytic event type by id
+	RequestAnalyticEventType(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.AnalyticEventType, error)
+	// Create an analytic event type and return it as created by the server
+	CreateAnalyticEventType(ctx context.Context, s vms.Server, t vms.Token, definition *vms.AnalyticEventTypeDefinition) (*vms.AnalyticEventType, error)
+	// Change the name, description and sources of an analytic event type
+	UpdateAnalyticEventType(ctx context.Context, s vms.Server, t vms.Token, id string, definition *vms.AnalyticEventTypeDefinition) (*vms.AnalyticEventType, error)
+	// Delete an analytic event type
+	DeleteAnalyticEventType(ctx context.Context, s vms.Server, t vms.Token, id string) error
+	// Create a bookmark and return it as created by the server
+	CreateBookmark(ctx context.Context, s vms.Server, t vms.Token, b *vms.Bookmark) (*vms.Bookmark, error)
+	// Iterate over all bookmarks, requesting them page by page
+	ListBookmarks(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.Bookmark, error]
+	// Query a single bookmark by id
+	RequestBookmark(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.Bookmark, error)
+	// Change the header, description or time range of a bookmark
+	UpdateBookmark(ctx context.Context, s vms.Server, t vms.Token, id string, update *vms.BookmarkUpdate) (*vms.Bookmark, error)
+	// Delete a bookmark
+	DeleteBookmark(ctx context.Context, s vms.Server, t vms.Token, id string) error
+	// Query the bookmarks of the given cameras within a time range
+	SearchBookmarks(ctx context.Context, s vms.Server, t vms.Token, search *vms.BookmarkSearch) (*vms.Bookmarks, error)
+	// Send a GET request asking for a single item to an endpoint of the API gateway and return the response status code.
+	// Error status codes are returned as they are, only failing to reach the gateway returns an error
+	ProbeEndpoint(ctx context.Context, s vms.Server, t vms.Token, path string) (int, error)
+	// Perform a WebSocket handshake with an endpoint of the API gateway and return the response status code, 101 when
+	// the handshake succeeded. Only failing to reach the gateway returns an error
+	ProbeWebSocketEndpoint(ctx context.Context, s vms.Server, t vms.Token, path string) (int, error)
 }
 
+// The bookmarks api follows the same request and response conventions as the configuration api
 type gatewayRepository struct {
-	base.HttpBaseRepository
+	configClient
 }
 
 func NewGatewayRepository() GatewayRepository {
 	return &gatewayRepository{
-		HttpBaseRepository: base.NewHttpBaseRepository(),
+		configClient: configClient{HttpBaseRepository: base.NewHttpBaseRepository()},
 	}
 }
 
+// Builds a request url for the given path on the first API gateway of the management server.
+// Builds the url of a request to the API gateway of the server. The path is escaped, see configPath and itemPath. Paths
+// with . or .. segments are refused, the server would resolve them to another resource than the one requested.
+func newGatewayRequestUrl(s vms.Server, path string) (*url.URL, error) {
+	if s.ApiWellKnownUris == nil || len(s.ApiWellKnownUris.ApiGateways) == 0 {
+		return nil, errors.New("no API gateway available for server " + s.Hostname())
+	}
+
+	requestUrl, err := url.ParseRequestURI(s.ApiWellKnownUris.ApiGateways[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "." || segment == ".." {
+			return nil, fmt.Errorf("invalid request path %q: . and .. segments aren't allowed", path)
+		}
+	}
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return nil, err
+	}
+	requestUrl.Path = unescaped
+	requestUrl.RawPath = path
+	return requestUrl, nil
+}
+
+// Builds the escaped request path of the item with the given id in a collection (e.g. constants.Bookmarks), or the path
+// of the collection when the id is empty.
+func itemPath(collection, id string) string {
+	if id == "" {
+		return collection
+	}
+	return collection + "/" + url.PathEscape(id)
+}
+
+// Iterates over all the items of a gateway list endpoint. See base.ListFromArgs for more information.
+func listFromGateway[T any](ctx context.Context, hbr base.HttpBaseRepository, s vms.Server, t vms.Token, path string) iter.Seq2[*T, error] {
+	requestUrl, err := newGatewayRequestUrl(s, path)
+	if err != nil {
+		return func(yield func(*T, error) bool) {
+			yield(nil, err)
+		}
+	}
+	return base.ListFromArgs[T](ctx, hbr, requestUrl, t, base.DefaultPageSize)
+}
+
 func (gr gatewayRepository) RequestGatewayWellKnownUris(ctx context.Context, s vms.Server) (*vms.ApiWellKnownUrisSchema, error) {
 	// Build the request url from the management server url
 	requestUrl := s.ServerInputInfo().ServerURL
@@ -55,66 +142,138 @@ func (gr gatewayRepository) RequestGatewayWellKnownUris(ctx context.Context, s v
 }
 
 func (gr gatewayRepository) RequestEnabledCameras(ctx context.Context, s vms.Server, t vms.Token) (*vms.CamerasList, error) {
-	// Build the request url
-	requestUrl, err := url.ParseRequestURI(s.ApiWellKnownUris.ApiGateways[0])
-	if err != nil {
-		return nil, err
+	cameras := vms.NewCamerasList()
+	for camera, err := range gr.ListCameras(ctx, s, t) {
+		if err != nil {
+			return nil, err
+		}
+		cameras.Add(camera)
 	}
 
-	// Build the request path
-	requestUrl.Path = constants.EnabledCameras
+	return cameras, nil
+}
 
-	// Execute Get request
-	response, _, err := gr.DoFromArgs(ctx, http.MethodGet, requestUrl, t, nil, enums.None)
-	if err != nil {
-		return nil, err
+func (gr gatewayRepository) RequestAnalyticEventTypes(ctx context.Context, s vms.Server, t vms.Token) (*vms.AnalyticEventTypes, error) {
+	analyticEvents := vms.NewAnalyticEventTypes()
+	for analyticEventType, err := range gr.ListAnalyticEventTypes(ctx, s, t) {
+		if err != nil {
+			return nil, err
+		}
+		analyticEvents.Add(*analyticEventType)
 	}
 
-	// Build the response data structure
-	type data struct {
-		Array []vms.Camera `json:"array"`
-	}
-	d := new(data)
-	if err := json.Unmarshal(response, d); err != nil {
-		return nil, err
-	}
+	return analyticEvents, nil
+}
 
-	cameras := vms.NewCamerasList()
-	for _, camera := range d.Array {
-		cameras.Add(&camera)
-	}
+func (gr gatewayRepository) ListCameras(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.Camera, error] {
+	return listFromGateway[vms.Camera](ctx, gr.HttpBaseRepository, s, t, constants.EnabledCameras)
+}
 
-	return cameras, nil
+func (gr gatewayRepository) ListAnalyticEventTypes(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.AnalyticEventType, error] {
+	return listFromGateway[vms.AnalyticEventType](ctx, gr.HttpBaseRepository, s, t, constants.AnalyticEventTypes)
 }
 
-func (gr gatewayRepository) RequestAnalyticEventTypes(ctx context.Context, s vms.Server, t vms.Token) (*vms.AnalyticEventTypes, error) {
-	// Build the request url
-	requestUrl, err := url.ParseRequestURI(s.ApiWellKnownUris.ApiGateways[0])
-	if err != nil {
-		return nil, err
+func (gr gatewayRepository) RequestAnalyticEventType(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.AnalyticEventType, error) {
+	return doConfigItem[vms.AnalyticEventType](ctx, gr.configClient, s, t, http.MethodGet, itemPath(constants.AnalyticEventTypes, id), "", nil)
+}
+
+func (gr gatewayRepository) ListCameraGroups(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.CameraGroup, error] {
+	return listFromGateway[vms.CameraGroup](ctx, gr.HttpBaseRepository, s, t, configPath(constants.CameraGroupsResource))
+}
+
+func (gr gatewayRepository) ListCameraGroupSubgroups(ctx context.Context, s vms.Server, t vms.Token, groupID string) iter.Seq2[*vms.CameraGroup, error] {
+	return listFromGateway[vms.CameraGroup](ctx, gr.HttpBaseRepository, s, t, configPath(constants.CameraGroupsResource, groupID, constants.CameraGroupsResource))
+}
+
+func (gr gatewayRepository) ListCameraGroupCameras(ctx context.Context, s vms.Server, t vms.Token, groupID string) iter.Seq2[*vms.Camera, error] {
+	return listFromGateway[vms.Camera](ctx, gr.HttpBaseRepository, s, t, configPath(constants.CameraGroupsResource, groupID, constants.CamerasResource))
+}
+
+// The server expects a list of sources, even when empty.
+func withSourceArray(definition *vms.AnalyticEventTypeDefinition) *vms.AnalyticEventTypeDefinition {
+	if definition.Sources != nil {
+		return definition
 	}
+	d := *definition
+	d.Sources = []string{}
+	return &d
+}
 
-	// Build the request path
-	requestUrl.Path = constants.AnalyticEventTypes
+func (gr gatewayRepository) CreateAnalyticEventType(ctx context.Context, s vms.Server, t vms.Token, definition *vms.AnalyticEventTypeDefinition) (*vms.AnalyticEventType, error) {
+	definition = withSourceArray(definition)
+	return doConfigItem[vms.AnalyticEventType](ctx, gr.configClient, s, t, http.MethodPost, constants.AnalyticEventTypes, "", definition)
+}
 
-	// Execute Get request
-	response, _, err := gr.DoFromArgs(ctx, http.MethodGet, requestUrl, t, nil, enums.None)
+func (gr gatewayRepository) UpdateAnalyticEventType(ctx context.Context, s vms.Server, t vms.Token, id string, definition *vms.AnalyticEventTypeDefinition) (*vms.AnalyticEventType, error) {
+	definition = withSourceArray(definition)
+	return doConfigItem[vms.AnalyticEventType](ctx, gr.configClient, s, t, http.MethodPatch, itemPath(constants.AnalyticEventTypes, id), "", definition)
+}
+
+func (gr gatewayRepository) DeleteAnalyticEventType(ctx context.Context, s vms.Server, t vms.Token, id string) error {
+	return gr.doJson(ctx, s, t, http.MethodDelete, itemPath(constants.AnalyticEventTypes, id), "", nil, nil)
+}
+
+func (gr gatewayRepository) CreateBookmark(ctx context.Context, s vms.Server, t vms.Token, b *vms.Bookmark) (*vms.Bookmark, error) {
+	return doConfigItem[vms.Bookmark](ctx, gr.configClient, s, t, http.MethodPost, constants.Bookmarks, "", b)
+}
+
+func (gr gatewayRepository) ListBookmarks(ctx context.Context, s vms.Server, t vms.Token) iter.Seq2[*vms.Bookmark, error] {
+	return listFromGateway[vms.Bookmark](ctx, gr.HttpBaseRepository, s, t, constants.Bookmarks)
+}
+
+func (gr gatewayRepository) RequestBookmark(ctx context.Context, s vms.Server, t vms.Token, id string) (*vms.Bookmark, error) {
+	return doConfigItem[vms.Bookmark](ctx, gr.configClient, s, t, http.MethodGet, itemPath(constants.Bookmarks, id), "", nil)
+}
+
+func (gr gatewayRepository) UpdateBookmark(ctx context.Context, s vms.Server, t vms.Token, id string, update *vms.BookmarkUpdate) (*vms.Bookmark, error) {
+	return doConfigItem[vms.Bookmark](ctx, gr.configClient, s, t, http.MethodPatch, itemPath(constants.Bookmarks, id), "", update)
+}
+
+func (gr gatewayRepository) DeleteBookmark(ctx context.Context, s vms.Server, t vms.Token, id string) error {
+	return gr.doJson(ctx, s, t, http.MethodDelete, itemPath(constants.Bookmarks, id), "", nil, nil)
+}
+
+func (gr gatewayRepository) SearchBookmarks(ctx context.Context, s vms.Server, t vms.Token, search *vms.BookmarkSearch) (*vms.Bookmarks, error) {
+	query := url.Values{}
+	query.Set(constants.ConfigTaskParam, constants.BookmarksSearchTask)
+
+	found, err := doConfigItem[[]*vms.Bookmark](ctx, gr.configClient, s, t, http.MethodPost, constants.Bookmarks, query.Encode(), search)
 	if err != nil {
 		return nil, err
 	}
 
-	type data struct {
-		Array []vms.AnalyticEventType `json:"array"`
+	bookmarks := vms.NewBookmarks()
+	if found != nil {
+		for _, b := range *found {
+			bookmarks.Add(b)
+		}
 	}
-	d := new(data)
-	if err := json.Unmarshal(response, d); err != nil {
-		return nil, err
+	return bookmarks, nil
+}
+
+func (gr gatewayRepository) ProbeEndpoint(ctx context.Context, s vms.Server, t vms.Token, path string) (int, error) {
+	requestUrl, err := newGatewayRequestUrl(s, path)
+	if err != nil {
+		return -1, err
 	}
+	// Keep the response small on list endpoints
+	requestUrl.RawQuery = "page=0&size=1"
 
-	analyticEvents := vms.NewAnalyticEventTypes()
-	for _, analyticEventType := range d.Array {
-		analyticEvents.Add(analyticEventType)
+	_, statusCode, err := gr.DoFromArgs(ctx, http.MethodGet, requestUrl, t, nil, enums.None)
+	if statusCode > 0 {
+		return statusCode, nil
 	}
+	return -1, err
+}
 
-	return analyticEvents, nil
+func (gr gatewayRepository) ProbeWebSocketEndpoint(ctx context.Context, s vms.Server, t vms.Token, path string) (int, error) {
+	requestUrl, err := newGatewayRequestUrl(s, path)
+	if err != nil {
+		return -1, err
+	}
+	requestUrl.Scheme = "ws"
+	if s.IsSecure() {
+		requestUrl.Scheme = "wss"
+	}
+	return gr.ProbeWebSocket(ctx, requestUrl, t)
 }
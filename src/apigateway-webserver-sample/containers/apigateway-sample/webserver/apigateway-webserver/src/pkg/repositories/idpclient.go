@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -18,6 +19,9 @@ type IdpRepository interface {
 	// Queries the IDP API well-known configuration (OpenId Configuration)
 	RequestIdpWellKnownConfig(ctx context.Context, s vms.Server) (*vms.IdpOpenIdConfigSchema, error)
 
+	// Reads the keys the IDP signs its tokens with, from the jwks_uri of its configuration
+	RequestSigningKeys(ctx context.Context, s vms.Server) (*vms.JSONWebKeySet, error)
+
 	// Sends a post request to get an access token for a "basic user" for the management server scope (not supported for windows users by design)
 	RequestAccessToken(ctx context.Context, u vms.User, s vms.Server, td TokenDispatcher) (vms.Token, error)
 }
@@ -51,6 +55,22 @@ func (ir idpRepository) RequestIdpWellKnownConfig(ctx context.Context, s vms.Ser
 	return &config, nil
 }
 
+func (ir idpRepository) RequestSigningKeys(ctx context.Context, s vms.Server) (*vms.JSONWebKeySet, error) {
+	if s.IdpOpenIdConfig == nil || s.IdpOpenIdConfig.JwksUri == "" {
+		return nil, errors.New("the IDP configuration has no jwks_uri")
+	}
+	requestUrl, err := url.ParseRequestURI(s.IdpOpenIdConfig.JwksUri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwks_uri: %w", err)
+	}
+
+	response, _, err := ir.DoFromArgs(ctx, http.MethodGet, requestUrl, nil, nil, enums.None)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute GET request: %w", err)
+	}
+	return vms.ParseJSONWebKeySet(response)
+}
+
 func (ir idpRepository) RequestAccessToken(ctx context.Context, u vms.User, s vms.Server, td TokenDispatcher) (vms.Token, error) {
 	// Build the request url
 	requestUrl, err := url.ParseRequestURI(s.IdpOpenIdConfig.TokenEndPoint)
@@ -58,22 +78,43 @@ func (ir idpRepository) RequestAccessToken(ctx context.Context, u vms.User, s vm
 		return nil, fmt.Errorf("invalid token endpoint URL: %w", err)
 	}
 
+	// The parameters of the request come from the token profile of the server, or the defaults of the credentials flow
+	profile := s.TokenProfile()
+	if profile == nil {
+		profile = vms.DefaultTokenProfile(u.CredentialsFlowType())
+	}
+
 	// Create basic user token request
 	payload := url.Values{}
+	clientID, clientSecret := profile.ClientID, profile.ClientSecret
 	if u.CredentialsFlowType() == enums.ClientCredentialsFlow {
 		payload.Set("grant_type", "client_credentials")
-		payload.Set("scope", "managementserver")
-		payload.Set("client_id", u.Username())     // At the ReadCredentialsFlowFiles the username is the client_id
-		payload.Set("client_secret", u.Password()) // And the password contains the client_secret
+		clientID = u.Username()     // At the ReadCredentials the username is the client_id
+		clientSecret = u.Password() // And the password contains the client_secret
 	} else {
 		payload.Set("grant_type", "password")
 		payload.Set("username", u.Username())
 		payload.Set("password", u.Password())
-		payload.Set("client_id", "GrantValidatorClient")
+	}
+	payload.Set("scope", profile.Scope())
+	for _, audience := range profile.Audiences {
+		payload.Add("audience", audience)
+	}
+	for name, value := range profile.ExtraParams {
+		payload.Set(name, value)
 	}
 
-	// Execute request
-	response, _, err := ir.DoFromArgs(ctx, http.MethodPost, requestUrl, nil, strings.NewReader(payload.Encode()), enums.Urlencoded)
+	// Execute request, with the client authenticated in the body or in the header
+	var response []byte
+	if profile.AuthMethod == vms.ClientSecretBasic {
+		response, _, err = ir.DoWithBasicAuth(ctx, http.MethodPost, requestUrl, clientID, clientSecret, strings.NewReader(payload.Encode()), enums.Urlencoded)
+	} else {
+		payload.Set("client_id", clientID)
+		if clientSecret != "" {
+			payload.Set("client_secret", clientSecret)
+		}
+		response, _, err = ir.DoFromArgs(ctx, http.MethodPost, requestUrl, nil, strings.NewReader(payload.Encode()), enums.Urlencoded)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute POST request: %w", err)
 	}
@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+var (
+	roleMappingsInstance *vms.RoleMappings
+	roleMappingsErr      error
+	roleMappingsOnce     sync.Once
+)
+
+// Returns the role mappings read from the file named by the ROLE_MAPPINGS_PATH environment variable.
+// Without the variable, every user is a viewer. The file is only read once.
+func GetRoleMappings() (*vms.RoleMappings, error) {
+	roleMappingsOnce.Do(func() {
+		path := os.Getenv(constants.RoleMappingsPathEnv)
+		if path == "" {
+			logger.Warn("No role mappings, every user is a viewer", "variable", constants.RoleMappingsPathEnv)
+			roleMappingsInstance = vms.NewRoleMappings()
+			return
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			roleMappingsErr = fmt.Errorf("reading role mappings %s: %w", path, err)
+			return
+		}
+		if roleMappingsInstance, roleMappingsErr = vms.ParseRoleMappings(data); roleMappingsErr != nil {
+			roleMappingsErr = fmt.Errorf("reading role mappings %s: %w", path, roleMappingsErr)
+			return
+		}
+		if len(roleMappingsInstance.Sites) == 0 {
+			logger.Warn("The role mappings trust no site, every user gets the default role", "path", path)
+		}
+		logger.Info("Role mappings read", "path", path, "sites", roleMappingsInstance.Sites)
+	})
+	return roleMappingsInstance, roleMappingsErr
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"sync"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/metrics"
+	"apigateway-webserver/src/pkg/tracing"
 )
 
 // Ensure a token dispatcher is responsible for the token dispatch function
@@ -44,19 +48,30 @@ func (td *tokenDispatcher) DispatchFunc() vms.TokenDispatchFunc {
 
 		// Execute function defined above
 		if tokenRenewCondition() {
-			td.mu.Lock()
-			defer td.mu.Unlock()
-			// Execute function defined above again after being inside the mutex lock
-			if tokenRenewCondition() {
-				dispatched, err := td.idpRepo.RequestAccessToken(ctx, *td.user, *td.server, td)
-				if err != nil {
-					return err
-				}
-
-				// Copy new token into current token
-				return current.Copy(dispatched)
-			}
+			ctx, span := tracing.Start(ctx, "Token renewal", attribute.String("credentials.flow", td.user.CredentialsFlowType().String()))
+			err := td.renew(ctx, current, tokenRenewCondition)
+			tracing.End(span, err)
+			return err
 		}
 		return nil
 	}
 }
+
+// Renews the token unless it was renewed while waiting for the lock.
+func (td *tokenDispatcher) renew(ctx context.Context, current vms.Token, tokenRenewCondition func() bool) error {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	// Execute function defined above again after being inside the mutex lock
+	if !tokenRenewCondition() {
+		return nil
+	}
+
+	dispatched, err := td.idpRepo.RequestAccessToken(ctx, *td.user, *td.server, td)
+	metrics.ObserveTokenRequest(td.user.CredentialsFlowType().String(), metrics.TokenRenewal, err)
+	if err != nil {
+		return err
+	}
+
+	// Copy new token into current token
+	return current.Copy(dispatched)
+}
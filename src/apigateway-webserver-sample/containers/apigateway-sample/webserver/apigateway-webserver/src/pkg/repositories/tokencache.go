@@ -0,0 +1,249 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/appcenter"
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/metrics"
+	"apigateway-webserver/src/pkg/tracing"
+)
+
+// Identifies the tokens of an app identity: the same client asking the same IDP with the same token profile gets the
+// same token.
+type TokenCacheKey struct {
+	TokenEndpoint string
+	ClientID      string
+	Scope         string
+	// Fingerprint of the whole resolved profile, see vms.TokenProfile.Fingerprint. Servers sharing an IDP, client and
+	// scope but asking for other audiences or parameters get their own token
+	Profile string
+}
+
+// Interface for sharing the client credentials tokens between all app contexts of the process.
+type TokenCache interface {
+	// Returns the token of the client credentials of the user on the server, requesting it only when no app context has it yet.
+	// The returned token is shared: it is renewed once for all its users when it expires.
+	RequestAccessToken(ctx context.Context, u vms.User, s vms.Server) (vms.Token, error)
+	// Tells the cache an app context doesn't use the token anymore. The token is forgotten once no app context uses it,
+	// the next login requests a new one. Tokens the cache didn't return are ignored.
+	ReleaseAccessToken(token vms.Token)
+}
+
+// Token of one app identity. It is its own token dispatcher, so every renewal goes through the same lock
+// and the callers waiting on it use the token renewed by the first one instead of asking the IDP again.
+type tokenCacheEntry struct {
+	idpRepo IdpRepository
+	user    vms.User
+	// Server the token is requested from, with the resolved profile of the key of the entry
+	server vms.Server
+
+	mu    sync.Mutex
+	token vms.Token
+
+	// App contexts using the token, and logins requesting it. Guarded by the lock of the cache
+	users int
+}
+
+type tokenCache struct {
+	idpRepo IdpRepository
+
+	mu      sync.Mutex
+	entries map[TokenCacheKey]*tokenCacheEntry
+	// Entries by the token they returned, to release them
+	tokens map[vms.Token]*tokenCacheEntry
+}
+
+var (
+	tokenCacheInstance TokenCache
+	tokenCacheOnce     sync.Once
+)
+
+func newTokenCache(idpRepo IdpRepository) *tokenCache {
+	return &tokenCache{
+		idpRepo: idpRepo,
+		entries: make(map[TokenCacheKey]*tokenCacheEntry),
+		tokens:  make(map[vms.Token]*tokenCacheEntry),
+	}
+}
+
+// Returns the token cache shared by all app contexts.
+// The tokens are requested again as soon as the credential provider reports new client credentials.
+func GetTokenCache() TokenCache {
+	tokenCacheOnce.Do(func() {
+		tc := newTokenCache(NewIdpRepository())
+		// Registered even when the provider can't be created yet, it gets the function once it is
+		appcenter.OnCredentialsChange(tc.reissueTokens)
+		tokenCacheInstance = tc
+	})
+	return tokenCacheInstance
+}
+
+// Requests new tokens with the current credentials for every token of the previous client, and replaces the shared tokens with them.
+// The app contexts keep using the same token objects, they get the new tokens without logging in again.
+func (tc *tokenCache) reissueTokens(previous, current appcenter.ClientCredentials) {
+	user := vms.NewUser(current.ClientID, current.ClientSecret, enums.ClientCredentialsFlow)
+
+	tc.mu.Lock()
+	entries := map[TokenCacheKey]*tokenCacheEntry{}
+	for key, entry := range tc.entries {
+		if key.ClientID == previous.ClientID {
+			entries[key] = entry
+		}
+	}
+	tc.mu.Unlock()
+
+	for key, entry := range entries {
+		if err := entry.reissue(context.Background(), *user); err != nil {
+			logger.Warn("Requesting a new client credentials token", "clientId", user.Username(), "tokenEndpoint", key.TokenEndpoint, "error", err)
+		}
+
+		// Logins with the new client id find the same token
+		if key.ClientID != current.ClientID {
+			newKey := key
+			newKey.ClientID = current.ClientID
+			tc.mu.Lock()
+			delete(tc.entries, key)
+			if _, exists := tc.entries[newKey]; !exists {
+				tc.entries[newKey] = entry
+			}
+			tc.mu.Unlock()
+		}
+	}
+}
+
+func (tc *tokenCache) entry(u vms.User, s vms.Server) *tokenCacheEntry {
+	profile := s.TokenProfile()
+	if profile == nil {
+		profile = vms.DefaultTokenProfile(u.CredentialsFlowType())
+	}
+	key := TokenCacheKey{
+		TokenEndpoint: s.IdpOpenIdConfig.TokenEndPoint,
+		ClientID:      u.Username(),
+		Scope:         profile.Scope(),
+		Profile:       profile.Fingerprint(),
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	entry, ok := tc.entries[key]
+	if !ok {
+		// The renewals use the profile of the key, whichever server of the entry logged in first
+		server := s
+		server.SetTokenProfile(profile)
+		entry = &tokenCacheEntry{
+			idpRepo: tc.idpRepo,
+			user:    u,
+			server:  server,
+		}
+		tc.entries[key] = entry
+	}
+	entry.users++
+	return entry
+}
+
+// Removes a user of the entry, and the entry itself when it was the last one.
+func (tc *tokenCache) release(entry *tokenCacheEntry) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	entry.users--
+	if entry.users > 0 {
+		return
+	}
+	for key, e := range tc.entries {
+		if e == entry {
+			delete(tc.entries, key)
+		}
+	}
+	for token, e := range tc.tokens {
+		if e == entry {
+			delete(tc.tokens, token)
+		}
+	}
+}
+
+func (tc *tokenCache) RequestAccessToken(ctx context.Context, u vms.User, s vms.Server) (vms.Token, error) {
+	entry := tc.entry(u, s)
+
+	// Concurrent logins of the same identity wait for the first request instead of sending their own
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.token != nil {
+		return entry.token, nil
+	}
+
+	token, err := entry.idpRepo.RequestAccessToken(ctx, entry.user, entry.server, entry)
+	metrics.ObserveTokenRequest(u.CredentialsFlowType().String(), metrics.TokenIssue, err)
+	if err != nil {
+		tc.release(entry)
+		return nil, err
+	}
+	logger.InfoContext(ctx, "Client credentials token requested, shared by all sessions of this client", "clientId", u.Username(), "tokenEndpoint", s.IdpOpenIdConfig.TokenEndPoint)
+	entry.token = token
+	tc.mu.Lock()
+	tc.tokens[token] = entry
+	tc.mu.Unlock()
+	return token, nil
+}
+
+func (tc *tokenCache) ReleaseAccessToken(token vms.Token) {
+	tc.mu.Lock()
+	entry, ok := tc.tokens[token]
+	tc.mu.Unlock()
+	if ok {
+		tc.release(entry)
+	}
+}
+
+// Replaces the credentials of the entry and its token with a token requested with them.
+// The new credentials are kept even when the request fails, the next renewal tries again with them.
+func (tce *tokenCacheEntry) reissue(ctx context.Context, u vms.User) error {
+	tce.mu.Lock()
+	defer tce.mu.Unlock()
+	tce.user = u
+	if tce.token == nil {
+		return nil
+	}
+
+	dispatched, err := tce.idpRepo.RequestAccessToken(ctx, tce.user, tce.server, tce)
+	metrics.ObserveTokenRequest(u.CredentialsFlowType().String(), metrics.TokenReissue, err)
+	if err != nil {
+		return err
+	}
+	logger.InfoContext(ctx, "Client credentials token requested again after the credentials changed", "clientId", u.Username(), "tokenEndpoint", tce.server.IdpOpenIdConfig.TokenEndPoint)
+	return tce.token.Copy(dispatched)
+}
+
+// Return an implementation of the TokenDispatchFunc function renewing the shared token
+func (tce *tokenCacheEntry) DispatchFunc() vms.TokenDispatchFunc {
+	return func(ctx context.Context, current vms.Token) error {
+		if !current.HasExpired() {
+			return nil
+		}
+
+		ctx, span := tracing.Start(ctx, "Token renewal", attribute.String("credentials.flow", enums.ClientCredentialsFlow.String()))
+		err := tce.renew(ctx, current)
+		tracing.End(span, err)
+		return err
+	}
+}
+
+// Renews the shared token unless another caller renewed it while waiting for the lock.
+func (tce *tokenCacheEntry) renew(ctx context.Context, current vms.Token) error {
+	tce.mu.Lock()
+	defer tce.mu.Unlock()
+	if !current.HasExpired() {
+		return nil
+	}
+
+	dispatched, err := tce.idpRepo.RequestAccessToken(ctx, tce.user, tce.server, tce)
+	metrics.ObserveTokenRequest(tce.user.CredentialsFlowType().String(), metrics.TokenRenewal, err)
+	if err != nil {
+		return err
+	}
+	return current.Copy(dispatched)
+}
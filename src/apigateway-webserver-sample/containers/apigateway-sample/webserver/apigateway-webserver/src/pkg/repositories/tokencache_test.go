@@ -0,0 +1,231 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/appcenter"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// IDP issuing numbered tokens, counting the requests. Tokens expire right away when expiresIn is 0.
+type fakeIdpRepository struct {
+	requests  atomic.Int32
+	expiresIn atomic.Int64
+}
+
+func (fir *fakeIdpRepository) RequestIdpWellKnownConfig(ctx context.Context, s vms.Server) (*vms.IdpOpenIdConfigSchema, error) {
+	return s.IdpOpenIdConfig, nil
+}
+
+func (fir *fakeIdpRepository) RequestSigningKeys(ctx context.Context, s vms.Server) (*vms.JSONWebKeySet, error) {
+	return nil, errors.New("no signing keys")
+}
+
+func (fir *fakeIdpRepository) RequestAccessToken(ctx context.Context, u vms.User, s vms.Server, td TokenDispatcher) (vms.Token, error) {
+	n := fir.requests.Add(1)
+	// Leave time to the other callers to pile up on the lock
+	time.Sleep(time.Millisecond)
+	tokenData := fmt.Sprintf(`{"access_token":"%s-%d","expires_in":%d}`, u.Username(), n, fir.expiresIn.Load())
+	return vms.NewToken([]byte(tokenData), td.DispatchFunc())
+}
+
+func newTestServer(hostname string, profile *vms.TokenProfile) *vms.Server {
+	s := vms.NewServer(&url.URL{Scheme: "http", Host: hostname})
+	s.IdpOpenIdConfig.TokenEndPoint = "http://" + hostname + "/idp/connect/token"
+	s.SetTokenProfile(profile)
+	return s
+}
+
+// Expired tokens used by many requests at once are renewed once. Run with -race.
+func TestTokenDispatcherRenewsOnceForConcurrentRequests(t *testing.T) {
+	idp := &fakeIdpRepository{}
+	user := vms.NewUser("operator", "secret", enums.LoginForm)
+	dispatcher := NewTokenDispatcher(idp, user, newTestServer("vms", nil))
+
+	token, err := idp.RequestAccessToken(context.Background(), *user, vms.Server{}, dispatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.expiresIn.Store(3600)
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := token.DispatchToken(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			if value != "operator-2" {
+				t.Errorf("dispatched %s, expected the renewed token operator-2", value)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if requests := idp.requests.Load(); requests != 2 {
+		t.Fatalf("%d token requests, expected the login and a single renewal", requests)
+	}
+}
+
+// Sessions of the same client share one token, requested and renewed once, while other clients and profiles get their own.
+func TestTokenCacheSharesTokensBetweenConcurrentLogins(t *testing.T) {
+	idp := &fakeIdpRepository{}
+	tc := newTokenCache(idp)
+
+	profile := vms.DefaultTokenProfile(enums.ClientCredentialsFlow)
+	otherAudience := profile.Merge(&vms.TokenProfile{Audiences: []string{"other"}})
+	servers := []*vms.Server{newTestServer("vms", profile), newTestServer("vms", otherAudience)}
+	clients := []string{"client-a", "client-b"}
+
+	tokens := make(chan vms.Token, 200)
+	var wg sync.WaitGroup
+	for i := range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user := vms.NewUser(clients[i%2], "secret", enums.ClientCredentialsFlow)
+			token, err := tc.RequestAccessToken(context.Background(), *user, *servers[i/2%2])
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			tokens <- token
+		}()
+	}
+	wg.Wait()
+	close(tokens)
+
+	distinct := map[vms.Token]bool{}
+	for token := range tokens {
+		distinct[token] = true
+	}
+	if len(distinct) != 4 || idp.requests.Load() != 4 {
+		t.Fatalf("%d tokens from %d requests, expected one per client and profile", len(distinct), idp.requests.Load())
+	}
+
+	// All tokens expire, every session renews at once
+	idp.expiresIn.Store(3600)
+	for token := range distinct {
+		token.Copy(expiredToken(t))
+	}
+	for token := range distinct {
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := token.DispatchToken(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if requests := idp.requests.Load(); requests != 8 {
+		t.Fatalf("%d token requests, expected a single renewal per shared token", requests)
+	}
+}
+
+// Credentials rotated while the sessions renew their tokens replace the shared tokens without races.
+func TestTokenCacheReissuesDuringRenewals(t *testing.T) {
+	idp := &fakeIdpRepository{}
+	idp.expiresIn.Store(3600)
+	tc := newTokenCache(idp)
+	user := vms.NewUser("client-a", "secret", enums.ClientCredentialsFlow)
+	server := newTestServer("vms", nil)
+
+	token, err := tc.RequestAccessToken(context.Background(), *user, *server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				if _, err := token.DispatchToken(context.Background()); err != nil {
+					t.Error(err)
+				}
+				token.GetSchema()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tc.reissueTokens(appcenter.ClientCredentials{ClientID: "client-a"}, appcenter.ClientCredentials{ClientID: "client-b", ClientSecret: "new"})
+	}()
+	wg.Wait()
+
+	if value := token.GetSchema().AccessToken; value != "client-b-2" {
+		t.Fatalf("the shared token is %s, expected the token of the new credentials", value)
+	}
+	// Logins with the new client find the reissued token
+	renamed := vms.NewUser("client-b", "new", enums.ClientCredentialsFlow)
+	if found, _ := tc.RequestAccessToken(context.Background(), *renamed, *server); found != token {
+		t.Fatal("the new client id doesn't find the shared token")
+	}
+}
+
+func expiredToken(t *testing.T) vms.Token {
+	t.Helper()
+	token, err := vms.NewToken([]byte(`{"access_token":"expired","expires_in":0}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without lifetime, the token expires once issued
+	time.Sleep(time.Millisecond)
+	return token
+}
+
+// A token is kept while an app context uses it, and forgotten once the last one releases it.
+func TestTokenCacheForgetsReleasedTokens(t *testing.T) {
+	idp := &fakeIdpRepository{}
+	idp.expiresIn.Store(3600)
+	tc := newTokenCache(idp)
+	user := vms.NewUser("client-a", "secret", enums.ClientCredentialsFlow)
+	server := newTestServer("vms", nil)
+
+	first, err := tc.RequestAccessToken(context.Background(), *user, *server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := tc.RequestAccessToken(context.Background(), *user, *server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("logins of the same client got different tokens")
+	}
+
+	tc.ReleaseAccessToken(first)
+	if len(tc.entries) != 1 {
+		t.Fatal("token forgotten while an app context still uses it")
+	}
+	tc.ReleaseAccessToken(second)
+	if len(tc.entries) != 0 || len(tc.tokens) != 0 {
+		t.Fatalf("%d entries and %d tokens left, expected none", len(tc.entries), len(tc.tokens))
+	}
+	// Releasing a token the cache doesn't know does nothing
+	tc.ReleaseAccessToken(second)
+
+	third, err := tc.RequestAccessToken(context.Background(), *user, *server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first || idp.requests.Load() != 2 {
+		t.Fatalf("%d token requests, expected a new token after the release", idp.requests.Load())
+	}
+}
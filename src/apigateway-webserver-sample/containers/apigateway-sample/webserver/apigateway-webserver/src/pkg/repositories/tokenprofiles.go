@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+var (
+	tokenProfilesInstance *vms.TokenProfiles
+	tokenProfilesErr      error
+	tokenProfilesOnce     sync.Once
+)
+
+// Returns the token profiles read from the file named by the TOKEN_PROFILES_PATH environment variable.
+// Without the variable, every server uses the defaults of the credentials flows. The file is only read once.
+func GetTokenProfiles() (*vms.TokenProfiles, error) {
+	tokenProfilesOnce.Do(func() {
+		path := os.Getenv(constants.TokenProfilesPathEnv)
+		if path == "" {
+			tokenProfilesInstance = vms.NewTokenProfiles()
+			return
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			tokenProfilesErr = fmt.Errorf("reading token profiles %s: %w", path, err)
+			return
+		}
+		if tokenProfilesInstance, tokenProfilesErr = vms.ParseTokenProfiles(data); tokenProfilesErr != nil {
+			tokenProfilesErr = fmt.Errorf("reading token profiles %s: %w", path, tokenProfilesErr)
+			return
+		}
+		logger.Info("Token profiles read", "path", path)
+	})
+	return tokenProfilesInstance, tokenProfilesErr
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Interval between two reads of the certificate files. Read when a reloader is created.
+var certificatePollInterval = 10 * time.Second
+
+// Serves the certificate of a pair of PEM files, and loads it again when the files change so that renewed certificates
+// are served without a restart. Files are polled like the credential files, mounted secrets are replaced through
+// symbolic links which file events don't follow reliably.
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	// Content of the files of the certificate served, to tell when they changed
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// Loads the certificate once, failing when it can't be loaded.
+func newCertificateReloader(certFile, keyFile string) (*certificateReloader, error) {
+	cr := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: certificatePollInterval,
+	}
+	if _, err := cr.load(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Loads the certificate when the files changed since the last load, and tells whether it did.
+func (cr *certificateReloader) load() (bool, error) {
+	certPEM, err := os.ReadFile(cr.certFile)
+	if err != nil {
+		return false, fmt.Errorf("reading the certificate file: %w", err)
+	}
+	keyPEM, err := os.ReadFile(cr.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("reading the key file: %w", err)
+	}
+
+	cr.mu.RLock()
+	unchanged := bytes.Equal(certPEM, cr.certPEM) && bytes.Equal(keyPEM, cr.keyPEM)
+	cr.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("loading the certificate %s with the key %s: %w", cr.certFile, cr.keyFile, err)
+	}
+	logger.Info("Certificate loaded", "subject", certificate.Leaf.Subject.String(), "notAfter", certificate.Leaf.NotAfter)
+
+	cr.mu.Lock()
+	cr.certificate = &certificate
+	cr.certPEM = certPEM
+	cr.keyPEM = keyPEM
+	cr.mu.Unlock()
+	return true, nil
+}
+
+// Reads the files again periodically until the context is done.
+func (cr *certificateReloader) watch(ctx context.Context) {
+	ticker := time.NewTicker(cr.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := cr.load(); err != nil {
+			// Files replaced one after the other don't match for a moment, the previous certificate is served meanwhile
+			logger.Warn("Loading the certificate again", "error", err)
+		}
+	}
+}
+
+// Returns the certificate served, see tls.Config.GetCertificate.
+func (cr *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.certificate, nil
+}
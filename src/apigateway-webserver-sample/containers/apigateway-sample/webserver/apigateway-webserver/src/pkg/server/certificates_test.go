@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// PEM files of a self-signed certificate and its key.
+type certificatePair struct {
+	cert []byte
+	key  []byte
+}
+
+func newCertificatePair(t *testing.T, commonName string) certificatePair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{commonName},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificatePair{
+		cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func writePair(t *testing.T, certFile, keyFile string, cert, key []byte) {
+	t.Helper()
+	if err := os.WriteFile(certFile, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedName(t *testing.T, cr *certificateReloader) string {
+	t.Helper()
+	certificate, err := cr.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate.Leaf.Subject.CommonName
+}
+
+// Certificates written on disk are served without a restart, pairs that can't be loaded leave the previous one served.
+func TestCertificateReloaderReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	first, renewed := newCertificatePair(t, "first"), newCertificatePair(t, "renewed")
+	writePair(t, certFile, keyFile, first.cert, first.key)
+
+	cr, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := servedName(t, cr); name != "first" {
+		t.Fatalf("serving %s, expected first", name)
+	}
+	if loaded, err := cr.load(); loaded || err != nil {
+		t.Fatalf("unchanged files loaded again: %t, %v", loaded, err)
+	}
+
+	tests := []struct {
+		name string
+		cert []byte
+		key  []byte
+	}{
+		{"key of another certificate", renewed.cert, first.key},
+		{"certificate of another key", first.cert, renewed.key},
+		{"invalid certificate", []byte("not a certificate"), first.key},
+		{"empty key", first.cert, []byte{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writePair(t, certFile, keyFile, test.cert, test.key)
+			if _, err := cr.load(); err == nil {
+				t.Fatal("invalid pair loaded")
+			}
+			if name := servedName(t, cr); name != "first" {
+				t.Fatalf("serving %s, expected the previous certificate", name)
+			}
+		})
+	}
+
+	writePair(t, certFile, keyFile, renewed.cert, renewed.key)
+	if loaded, err := cr.load(); !loaded || err != nil {
+		t.Fatalf("renewed certificate not loaded: %t, %v", loaded, err)
+	}
+	if name := servedName(t, cr); name != "renewed" {
+		t.Fatalf("serving %s, expected renewed", name)
+	}
+}
+
+// The watcher picks the renewed certificate up by itself.
+func TestCertificateReloaderWatchesTheFiles(t *testing.T) {
+	interval := certificatePollInterval
+	certificatePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { certificatePollInterval = interval })
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	first, renewed := newCertificatePair(t, "first"), newCertificatePair(t, "renewed")
+	writePair(t, certFile, keyFile, first.cert, first.key)
+
+	cr, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		cr.watch(ctx)
+		close(stopped)
+	}()
+	// The files are removed once the watcher stopped reading them
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	writePair(t, certFile, keyFile, renewed.cert, renewed.key)
+	deadline := time.Now().Add(5 * time.Second)
+	for servedName(t, cr) != "renewed" {
+		if time.Now().After(deadline) {
+			t.Fatal("renewed certificate not served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCertificateReloaderNeedsAValidPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if _, err := newCertificateReloader(certFile, keyFile); err == nil {
+		t.Fatal("reloader created without files")
+	}
+
+	first, other := newCertificatePair(t, "first"), newCertificatePair(t, "other")
+	writePair(t, certFile, keyFile, first.cert, other.key)
+	if _, err := newCertificateReloader(certFile, keyFile); err == nil {
+		t.Fatal("reloader created with a key of another certificate")
+	}
+}
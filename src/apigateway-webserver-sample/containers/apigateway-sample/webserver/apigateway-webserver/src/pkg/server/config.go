@@ -0,0 +1,293 @@
+package server
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"apigateway-webserver/src/pkg/constants"
+)
+
+// Duration read from JSON as a string like "30s", see time.ParseDuration.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Configuration of the HTTP server. Timeouts of 0 mean no timeout.
+type Config struct {
+	// Address listened to, e.g. ":8080"
+	Addr string `json:"addr"`
+	// Path prefix of all pages when the webserver is published behind a reverse proxy, e.g. "/apigateway". Empty to serve from the root
+	BasePath          string   `json:"basePath"`
+	ReadTimeout       Duration `json:"readTimeout"`
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout"`
+	// Not applied to the requests waiting for events, they last until events arrive
+	WriteTimeout Duration `json:"writeTimeout"`
+	IdleTimeout  Duration `json:"idleTimeout"`
+	// Time given to the requests in progress to finish when stopping
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+	// Management server probed by the readiness endpoint, e.g. "http://vms". Empty to not probe any server
+	ReadinessServer string `json:"readinessServer"`
+	// Maximum duration of each readiness check
+	ReadinessTimeout Duration `json:"readinessTimeout"`
+	// Duration the readiness report is reused before the checks run again
+	ReadinessCacheTTL Duration `json:"readinessCacheTtl"`
+	// Maximum duration of each request reading the well-known URIs, the IDP configuration and the features of a server at login
+	DiscoveryTimeout Duration `json:"discoveryTimeout"`
+	// Maximum duration of a token request
+	TokenTimeout Duration `json:"tokenTimeout"`
+	// Maximum duration of each list read for a page or the API, e.g. the cameras of a site
+	ListTimeout Duration `json:"listTimeout"`
+	// PEM files of the certificate chain and private key served over HTTPS, loaded again when they change. Empty to serve HTTP
+	TLSCertFile string `json:"tlsCertFile"`
+	TLSKeyFile  string `json:"tlsKeyFile"`
+	// Address answering plain HTTP requests with a redirect to HTTPS, e.g. ":8080". Empty to not listen to HTTP
+	HTTPRedirectAddr string `json:"httpRedirectAddr"`
+	// Duration browsers only connect with HTTPS, sent in the Strict-Transport-Security header of the HTTPS responses. 0 to not send it
+	HSTSMaxAge Duration `json:"hstsMaxAge"`
+	// Sends the Content-Security-Policy, X-Frame-Options and other security headers with every response
+	SecureHeaders bool `json:"secureHeaders"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Addr:              ":8080",
+		ReadTimeout:       Duration(30 * time.Second),
+		ReadHeaderTimeout: Duration(10 * time.Second),
+		WriteTimeout:      Duration(60 * time.Second),
+		IdleTimeout:       Duration(120 * time.Second),
+		ShutdownTimeout:   Duration(30 * time.Second),
+		ReadinessTimeout:  Duration(5 * time.Second),
+		ReadinessCacheTTL: Duration(10 * time.Second),
+		DiscoveryTimeout:  Duration(10 * time.Second),
+		TokenTimeout:      Duration(15 * time.Second),
+		ListTimeout:       Duration(30 * time.Second),
+		SecureHeaders:     true,
+	}
+}
+
+// Reads the configuration from the defaults, then the JSON file, then the environment variables, then the command line flags,
+// each one replacing the values set by the previous ones. The file is named by the -config flag or the SERVER_CONFIG_PATH variable.
+func LoadConfig(args []string) (*Config, error) {
+	config := NewDefaultConfig()
+
+	flags := flag.NewFlagSet("apigateway-webserver", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv(constants.ServerConfigPathEnv), "path of the JSON configuration file")
+	addr := flags.String("addr", "", "address to listen to, e.g. :8080")
+	basePath := flags.String("base-path", "", "path prefix of all pages, e.g. /apigateway")
+	readTimeout := flags.Duration("read-timeout", 0, "maximum duration for reading a request")
+	readHeaderTimeout := flags.Duration("read-header-timeout", 0, "maximum duration for reading the headers of a request")
+	writeTimeout := flags.Duration("write-timeout", 0, "maximum duration for writing a response")
+	idleTimeout := flags.Duration("idle-timeout", 0, "maximum duration of an idle keep-alive connection")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 0, "maximum duration for the requests in progress to finish when stopping")
+	readinessServer := flags.String("readiness-server", "", "management server probed by the readiness endpoint, e.g. http://vms")
+	readinessTimeout := flags.Duration("readiness-timeout", 0, "maximum duration of each readiness check")
+	readinessCacheTTL := flags.Duration("readiness-cache-ttl", 0, "duration the readiness report is reused")
+	discoveryTimeout := flags.Duration("discovery-timeout", 0, "maximum duration of each discovery request at login")
+	tokenTimeout := flags.Duration("token-timeout", 0, "maximum duration of a token request")
+	listTimeout := flags.Duration("list-timeout", 0, "maximum duration of each list read for a page or the API")
+	tlsCertFile := flags.String("tls-cert-file", "", "PEM file of the certificate chain served over HTTPS")
+	tlsKeyFile := flags.String("tls-key-file", "", "PEM file of the private key of the certificate")
+	httpRedirectAddr := flags.String("http-redirect-addr", "", "address answering plain HTTP requests with a redirect to HTTPS, e.g. :8080")
+	hstsMaxAge := flags.Duration("hsts-max-age", 0, "duration browsers only connect with HTTPS, 0 to not send the HSTS header")
+	secureHeaders := flags.Bool("secure-headers", true, "send the Content-Security-Policy and other security headers")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading the configuration file: %w", err)
+		}
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("reading the configuration file %s: %w", *configPath, err)
+		}
+	}
+
+	if err := config.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	// Only the flags given on the command line replace the previous values
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			config.Addr = *addr
+		case "base-path":
+			config.BasePath = *basePath
+		case "read-timeout":
+			config.ReadTimeout = Duration(*readTimeout)
+		case "read-header-timeout":
+			config.ReadHeaderTimeout = Duration(*readHeaderTimeout)
+		case "write-timeout":
+			config.WriteTimeout = Duration(*writeTimeout)
+		case "idle-timeout":
+			config.IdleTimeout = Duration(*idleTimeout)
+		case "shutdown-timeout":
+			config.ShutdownTimeout = Duration(*shutdownTimeout)
+		case "readiness-server":
+			config.ReadinessServer = *readinessServer
+		case "readiness-timeout":
+			config.ReadinessTimeout = Duration(*readinessTimeout)
+		case "readiness-cache-ttl":
+			config.ReadinessCacheTTL = Duration(*readinessCacheTTL)
+		case "discovery-timeout":
+			config.DiscoveryTimeout = Duration(*discoveryTimeout)
+		case "token-timeout":
+			config.TokenTimeout = Duration(*tokenTimeout)
+		case "list-timeout":
+			config.ListTimeout = Duration(*listTimeout)
+		case "tls-cert-file":
+			config.TLSCertFile = *tlsCertFile
+		case "tls-key-file":
+			config.TLSKeyFile = *tlsKeyFile
+		case "http-redirect-addr":
+			config.HTTPRedirectAddr = *httpRedirectAddr
+		case "hsts-max-age":
+			config.HSTSMaxAge = Duration(*hstsMaxAge)
+		case "secure-headers":
+			config.SecureHeaders = *secureHeaders
+		}
+	})
+
+	if err := config.normalize(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) loadEnv() error {
+	if value := os.Getenv(constants.ListenAddrEnv); value != "" {
+		c.Addr = value
+	}
+	if value, ok := os.LookupEnv(constants.BasePathEnv); ok {
+		c.BasePath = value
+	}
+	if value, ok := os.LookupEnv(constants.ReadinessServerEnv); ok {
+		c.ReadinessServer = value
+	}
+	if value, ok := os.LookupEnv(constants.TLSCertFileEnv); ok {
+		c.TLSCertFile = value
+	}
+	if value, ok := os.LookupEnv(constants.TLSKeyFileEnv); ok {
+		c.TLSKeyFile = value
+	}
+	if value, ok := os.LookupEnv(constants.HTTPRedirectAddrEnv); ok {
+		c.HTTPRedirectAddr = value
+	}
+	if value := os.Getenv(constants.SecureHeadersEnv); value != "" {
+		secureHeaders, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("environment variable %s: %w", constants.SecureHeadersEnv, err)
+		}
+		c.SecureHeaders = secureHeaders
+	}
+
+	durations := map[string]*Duration{
+		constants.ReadTimeoutEnv:       &c.ReadTimeout,
+		constants.ReadHeaderTimeoutEnv: &c.ReadHeaderTimeout,
+		constants.WriteTimeoutEnv:      &c.WriteTimeout,
+		constants.IdleTimeoutEnv:       &c.IdleTimeout,
+		constants.ShutdownTimeoutEnv:   &c.ShutdownTimeout,
+		constants.ReadinessTimeoutEnv:  &c.ReadinessTimeout,
+		constants.ReadinessCacheTTLEnv: &c.ReadinessCacheTTL,
+		constants.DiscoveryTimeoutEnv:  &c.DiscoveryTimeout,
+		constants.TokenTimeoutEnv:      &c.TokenTimeout,
+		constants.ListTimeoutEnv:       &c.ListTimeout,
+		constants.HSTSMaxAgeEnv:        &c.HSTSMaxAge,
+	}
+	for name, duration := range durations {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("environment variable %s: %w", name, err)
+		}
+		*duration = Duration(parsed)
+	}
+	return nil
+}
+
+// Checks the values and gives the base path the form "/prefix", without trailing slash.
+func (c *Config) normalize() error {
+	if c.Addr == "" {
+		return fmt.Errorf("empty listen address")
+	}
+	for name, duration := range map[string]Duration{
+		"read timeout":        c.ReadTimeout,
+		"read header timeout": c.ReadHeaderTimeout,
+		"write timeout":       c.WriteTimeout,
+		"idle timeout":        c.IdleTimeout,
+		"shutdown timeout":    c.ShutdownTimeout,
+		"readiness timeout":   c.ReadinessTimeout,
+		"readiness cache ttl": c.ReadinessCacheTTL,
+		"discovery timeout":   c.DiscoveryTimeout,
+		"token timeout":       c.TokenTimeout,
+		"list timeout":        c.ListTimeout,
+		"HSTS max age":        c.HSTSMaxAge,
+	} {
+		if duration < 0 {
+			return fmt.Errorf("negative %s", name)
+		}
+	}
+
+	if _, err := c.ReadinessServerURL(); err != nil {
+		return err
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("HTTPS needs both a certificate file and a key file")
+	}
+	if c.HTTPRedirectAddr != "" && !c.TLS() {
+		return fmt.Errorf("the HTTP redirect address needs HTTPS, set a certificate file and a key file")
+	}
+
+	c.BasePath = strings.TrimRight(c.BasePath, "/")
+	if c.BasePath != "" && !strings.HasPrefix(c.BasePath, "/") {
+		c.BasePath = "/" + c.BasePath
+	}
+	return nil
+}
+
+// Tells whether the server is served over HTTPS.
+func (c *Config) TLS() bool {
+	return c.TLSCertFile != ""
+}
+
+// Returns the URL of the management server probed by the readiness endpoint, nil when none is configured.
+func (c *Config) ReadinessServerURL() (*url.URL, error) {
+	if c.ReadinessServer == "" {
+		return nil, nil
+	}
+	serverURL, err := url.Parse(c.ReadinessServer)
+	if err != nil {
+		return nil, fmt.Errorf("readiness server: %w", err)
+	}
+	if (serverURL.Scheme != "http" && serverURL.Scheme != "https") || serverURL.Host == "" {
+		return nil, fmt.Errorf("readiness server must be an http or https URL, e.g. http://vms")
+	}
+	return &url.URL{Scheme: serverURL.Scheme, Host: serverURL.Host}, nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"apigateway-webserver/src/pkg/logging"
+)
+
+// Request ids sent by the clients are kept when they are short and printable, others are replaced.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Length of the error responses kept for the logs.
+const maxLoggedErrorLength = 512
+
+// Last segment of the paths of the probes and metrics, called every few seconds and only logged at debug level.
+var probePaths = map[string]bool{
+	"healthz": true,
+	"readyz":  true,
+	"metrics": true,
+}
+
+// Records the status of a response and the beginning of its body when it is an error.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	errorText strings.Builder
+}
+
+func (lw *loggingResponseWriter) WriteHeader(status int) {
+	if lw.status == 0 {
+		lw.status = status
+	}
+	lw.ResponseWriter.WriteHeader(status)
+}
+
+func (lw *loggingResponseWriter) Write(b []byte) (int, error) {
+	if lw.status == 0 {
+		lw.status = http.StatusOK
+	}
+	if lw.status >= 400 && lw.errorText.Len() < maxLoggedErrorLength {
+		lw.errorText.Write(b[:min(len(b), maxLoggedErrorLength-lw.errorText.Len())])
+	}
+	return lw.ResponseWriter.Write(b)
+}
+
+// Lets http.ResponseController reach the connection, e.g. to lift the write deadline.
+func (lw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+// Gives every request an id, the one of the X-Request-ID header when the client sent one, carried by the request context
+// into the services and repositories and sent back in the response. Every request is logged once answered, with its
+// status and duration, and the text of the error responses.
+func withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(logging.RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = logging.NewRequestID()
+		}
+		w.Header().Set(logging.RequestIDHeader, requestID)
+		ctx := logging.WithRequestID(r.Context(), requestID)
+
+		start := time.Now()
+		lw := &loggingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r.WithContext(ctx))
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case lw.status >= 500:
+			level = slog.LevelError
+		case lw.status >= 400:
+			level = slog.LevelWarn
+		case probePaths[path.Base(r.URL.Path)]:
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("url", logging.RedactURL(r.URL)),
+			slog.Int("status", lw.status),
+			slog.Int64("durationMs", time.Since(start).Milliseconds()),
+		}
+		if lw.errorText.Len() > 0 {
+			attrs = append(attrs, slog.String("error", strings.TrimSpace(lw.errorText.String())))
+		}
+		logger.LogAttrs(ctx, level, "Request served", attrs...)
+	})
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/api"
+	"apigateway-webserver/src/pkg/entities/events"
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/handlers"
+	"apigateway-webserver/src/pkg/metrics"
+	"apigateway-webserver/src/pkg/openapi"
+	"apigateway-webserver/src/pkg/services"
+	"apigateway-webserver/src/pkg/tracing"
+)
+
+// Path and version of the JSON API.
+const (
+	apiPrefix  = "/api/v1"
+	apiVersion = "1.0.0"
+)
+
+// Returns the router of all pages and endpoints, served under the base path of the configuration.
+// The health and metrics endpoints are served both at the root and under the base path.
+func NewRouter(config *Config) (http.Handler, error) {
+	readinessServerURL, err := config.ReadinessServerURL()
+	if err != nil {
+		return nil, err
+	}
+	var readinessService services.ReadinessService
+	if readinessServerURL != nil {
+		readinessService = services.NewReadinessService(readinessServerURL, services.NewGatewayService(), services.NewIdpService(),
+			time.Duration(config.ReadinessTimeout), time.Duration(config.ReadinessCacheTTL))
+	}
+	healthHandler := handlers.NewHealthHandler(readinessService)
+	// Deadlines of the requests sent to the VMS while answering the pages and the API
+	timeouts := handlers.Timeouts{
+		Discovery: time.Duration(config.DiscoveryTimeout),
+		Token:     time.Duration(config.TokenTimeout),
+		List:      time.Duration(config.ListTimeout),
+	}
+
+	mux := http.NewServeMux()
+	// Every route is measured under its own name in the metrics and traced in its own span.
+	// The method of the patterns of the API is left out of the name, the metrics and spans have it already.
+	handle := func(pattern string, handler http.HandlerFunc) {
+		route := pattern
+		if _, path, found := strings.Cut(pattern, " "); found {
+			route = path
+		}
+		mux.Handle(pattern, tracing.InstrumentHandler(route, metrics.InstrumentHandler(route, handler)))
+	}
+	mux.HandleFunc("/healthz", healthHandler.Handle)
+	mux.HandleFunc("/readyz", healthHandler.ReadyHandle)
+	mux.Handle("/metrics", metrics.Handler())
+
+	// Every session may watch, the routes acting on the VMS require the operator role and the ones changing its
+	// configuration or the sessions the admin role
+	homeHandler := handlers.NewHomeHandler()
+	loginHandler := handlers.NewLoginHandler(timeouts)
+	handle("/", homeHandler.Handle)
+	handle("/_login/", lastsForLogin(config, timeouts, loginHandler.Handle))
+
+	viewHandler := handlers.NewViewHandler(timeouts)
+	eventHandler := handlers.NewEventHandler()
+	handle("/view_events/", viewHandler.Handle)
+	handle("/view_events/_group_cameras_request/", viewHandler.RequestGroupCamerasHandle)
+	handle("/view_events/_config_changes/", viewHandler.RequestConfigChangesHandle)
+	handle("/view_events/_events_start/", eventHandler.StartSubscriptionHandle)
+	handle("/view_events/_events_request/", waitsForEvents(eventHandler.RequestEventsHandle))
+	handle("/view_events/_events_trigger/", handlers.RequireRole(enums.RoleOperator, eventHandler.TriggerEventHandle))
+	handle("/view_events/_events_bookmark/", handlers.RequireRole(enums.RoleOperator, eventHandler.BookmarkEventHandle))
+
+	alarmHandler := handlers.NewAlarmHandler()
+	handle("/alarms/", alarmHandler.Handle)
+	handle("/alarms/_alarms_request/", alarmHandler.RequestAlarmsHandle)
+	handle("/alarms/_alarm_request/", alarmHandler.RequestAlarmHandle)
+	handle("/alarms/_alarm_update/", handlers.RequireRole(enums.RoleOperator, alarmHandler.UpdateAlarmHandle))
+	handle("/alarms/_alarms_start/", alarmHandler.StartSubscriptionHandle)
+	handle("/alarms/_alarms_events/", waitsForEvents(alarmHandler.RequestEventsHandle))
+
+	eventTypesHandler := handlers.NewEventTypesHandler()
+	handle("/event_types/", eventTypesHandler.Handle)
+	handle("/event_types/_event_types_request/", eventTypesHandler.RequestEventTypesHandle)
+	handle("/event_types/_event_type_create/", handlers.RequireRole(enums.RoleAdmin, eventTypesHandler.CreateEventTypeHandle))
+	handle("/event_types/_event_type_update/", handlers.RequireRole(enums.RoleAdmin, eventTypesHandler.UpdateEventTypeHandle))
+	handle("/event_types/_event_type_delete/", handlers.RequireRole(enums.RoleAdmin, eventTypesHandler.DeleteEventTypeHandle))
+	handle("/event_types/_event_types_export/", eventTypesHandler.ExportEventTypesHandle)
+	handle("/event_types/_event_types_import/", handlers.RequireRole(enums.RoleAdmin, eventTypesHandler.ImportEventTypesHandle))
+
+	hierarchyHandler := handlers.NewHierarchyHandler()
+	handle("/hierarchy/", hierarchyHandler.Handle)
+	handle("/hierarchy/_hierarchy_request/", hierarchyHandler.RequestHierarchyHandle)
+
+	cameraHandler := handlers.NewCameraHandler()
+	handle("/camera/", cameraHandler.Handle)
+	handle("/camera/_camera_actions_request/", cameraHandler.RequestCameraActionsHandle)
+	handle("/camera/_camera_action_run/", handlers.RequireRole(enums.RoleOperator, cameraHandler.RunActionHandle))
+	// Actions on the cameras of the event rows
+	handle("/view_events/_camera_actions_request/", cameraHandler.RequestCameraActionsHandle)
+	handle("/view_events/_camera_action_run/", handlers.RequireRole(enums.RoleOperator, cameraHandler.RunActionHandle))
+
+	// The operations of the API are described in its OpenAPI document as they are registered
+	document := openapi.NewDocument(openapi.Info{
+		Title:       constants.AppName + " API",
+		Description: "Sessions, cameras, event types, subscriptions and events of the users logged in to the webserver.",
+		Version:     apiVersion,
+	}, config.BasePath+apiPrefix, api.Problem{}, api.ProblemContentType)
+	apiHandler := handlers.NewApiHandler(document, timeouts)
+	handleApi := func(op openapi.Operation, handler http.HandlerFunc) {
+		document.Add(op)
+		handle(op.Method+" "+apiPrefix+op.Path, handler)
+	}
+	handle(apiPrefix+"/", apiHandler.NotFoundHandle)
+	handle("GET "+apiPrefix+"/openapi.json", apiHandler.OpenAPIHandle)
+
+	handleApi(openapi.Operation{
+		ID:       "createSession",
+		Method:   http.MethodPost,
+		Path:     "/sessions",
+		Tag:      "sessions",
+		Summary:  "Log in to a management server, replacing the session of the user or adding a site to it",
+		Request:  api.LoginRequest{},
+		Status:   http.StatusCreated,
+		Response: api.Session{},
+	}, lastsForLogin(config, timeouts, apiHandler.CreateSessionHandle))
+	handleApi(openapi.Operation{
+		ID:       "getSession",
+		Method:   http.MethodGet,
+		Path:     "/sessions/{username}",
+		Tag:      "sessions",
+		Summary:  "Get the sites of the session of a user",
+		Status:   http.StatusOK,
+		Response: api.Session{},
+	}, apiHandler.RequireRole(enums.RoleViewer, apiHandler.GetSessionHandle))
+	handleApi(openapi.Operation{
+		ID:      "deleteSession",
+		Method:  http.MethodDelete,
+		Path:    "/sessions/{username}",
+		Tag:     "sessions",
+		Summary: "Log out of all sites of the session. Requires the admin role, or the session cookie of the same user",
+		Status:  http.StatusNoContent,
+	}, apiHandler.RequireRoleOrSelf(enums.RoleAdmin, apiHandler.DeleteSessionHandle))
+
+	siteQuery := []openapi.Parameter{{Name: "site", Description: "Site of the session, the first site when missing"}}
+	handleApi(openapi.Operation{
+		ID:       "listCameras",
+		Method:   http.MethodGet,
+		Path:     "/sessions/{username}/cameras",
+		Tag:      "cameras",
+		Summary:  "List the cameras of a site",
+		Query:    siteQuery,
+		Status:   http.StatusOK,
+		Response: []*vms.Camera{},
+	}, apiHandler.RequireRole(enums.RoleViewer, apiHandler.ListCamerasHandle))
+	handleApi(openapi.Operation{
+		ID:       "listEventTypes",
+		Method:   http.MethodGet,
+		Path:     "/sessions/{username}/event-types",
+		Tag:      "event types",
+		Summary:  "List the analytic event types of a site",
+		Query:    siteQuery,
+		Status:   http.StatusOK,
+		Response: []*vms.AnalyticEventType{},
+	}, apiHandler.RequireRole(enums.RoleViewer, apiHandler.ListEventTypesHandle))
+
+	handleApi(openapi.Operation{
+		ID:       "createSubscription",
+		Method:   http.MethodPost,
+		Path:     "/sessions/{username}/subscriptions",
+		Tag:      "subscriptions",
+		Summary:  "Subscribe to the events of a camera or camera group, replacing the subscription of the site",
+		Request:  api.SubscriptionRequest{},
+		Status:   http.StatusCreated,
+		Response: api.Subscription{},
+	}, apiHandler.RequireRole(enums.RoleViewer, apiHandler.CreateSubscriptionHandle))
+	handleApi(openapi.Operation{
+		ID:      "deleteSubscription",
+		Method:  http.MethodDelete,
+		Path:    "/sessions/{username}/subscriptions/{site}",
+		Tag:     "subscriptions",
+		Summary: "Stop the subscription of a site",
+		Status:  http.StatusNoContent,
+	}, apiHandler.RequireRole(enums.RoleViewer, apiHandler.DeleteSubscriptionHandle))
+
+	handleApi(openapi.Operation{
+		ID:       "listEvents",
+		Method:   http.MethodGet,
+		Path:     "/sessions/{username}/events",
+		Tag:      "events",
+		Summary:  "Wait for the events of the subscriptions of all sites",
+		Status:   http.StatusOK,
+		Response: []events.AnalyticsEvent{},
+	}, waitsForEvents(apiHandler.RequireRole(enums.RoleViewer, apiHandler.ListEventsHandle)))
+	handleApi(openapi.Operation{
+		ID:       "triggerEvent",
+		Method:   http.MethodPost,
+		Path:     "/sessions/{username}/events",
+		Tag:      "events",
+		Summary:  "Trigger an event, e.g. a user-defined event. Requires the operator role",
+		Request:  api.EventTriggerRequest{},
+		Status:   http.StatusCreated,
+		Response: events.Event{},
+	}, apiHandler.RequireRole(enums.RoleOperator, apiHandler.TriggerEventHandle))
+
+	var router http.Handler = mux
+	if basePath := config.BasePath; basePath != "" {
+		// The pages only use relative links, they work the same under the base path
+		root := http.NewServeMux()
+		root.Handle(basePath+"/", http.StripPrefix(basePath, mux))
+		root.Handle(basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+		root.HandleFunc("/healthz", healthHandler.Handle)
+		root.HandleFunc("/readyz", healthHandler.ReadyHandle)
+		root.Handle("/metrics", metrics.Handler())
+		router = root
+	}
+
+	if config.SecureHeaders {
+		router = withSecureHeaders(router, time.Duration(config.HSTSMaxAge))
+	}
+	return withRequestLogging(router), nil
+}
+
+// Time left to write the answer of a login once its requests to the VMS took all their time.
+const loginWriteMargin = 5 * time.Second
+
+// Extends the write timeout of the server for the logins, so that a slow login is answered with the 504 of the request
+// that timed out rather than a dropped connection. The timeout is lifted when a login request has no limit.
+func lastsForLogin(config *Config, timeouts handlers.Timeouts, handle http.HandlerFunc) http.HandlerFunc {
+	writeTimeout, loginTimeout := time.Duration(config.WriteTimeout), timeouts.Login()
+	if writeTimeout <= 0 || (loginTimeout > 0 && loginTimeout+loginWriteMargin <= writeTimeout) {
+		return handle
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		deadline := time.Time{}
+		if loginTimeout > 0 {
+			deadline = time.Now().Add(loginTimeout + loginWriteMargin)
+		}
+		http.NewResponseController(w).SetWriteDeadline(deadline)
+		handle(w, r)
+	}
+}
+
+// Lifts the write timeout of the server for the requests waiting for events, which only answer once events arrive.
+func waitsForEvents(handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		handle(w, r)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"apigateway-webserver/src/pkg/view"
+)
+
+// Host names written in the Content-Security-Policy, others are left out so that a Host header can't change the policy.
+var cspHostPattern = regexp.MustCompile(`^[A-Za-z0-9.-]{1,253}$`)
+
+// Sends the security headers with every response. The Content-Security-Policy fits the embedded templates:
+//   - their inline scripts run with the nonce of the response, no other script does
+//   - their inline styles and style attributes are allowed, they load nothing else
+//   - they fetch the endpoints of the webserver, and the VMS settings of App Center on ports 80 and 443 of the same host
+//
+// The Strict-Transport-Security header is sent with the HTTPS responses when hstsMaxAge isn't 0.
+func withSecureHeaders(next http.Handler, hstsMaxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce, err := newCSPNonce()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Creating the nonce of the page scripts: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		header := w.Header()
+		header.Set("Content-Security-Policy", contentSecurityPolicy(r, nonce))
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("X-Content-Type-Options", "nosniff")
+		// The pages have the username in their query, it isn't sent to other sites
+		header.Set("Referrer-Policy", "same-origin")
+		header.Set("Cross-Origin-Opener-Policy", "same-origin")
+		header.Set("Permissions-Policy", "camera=(), microphone=(), geolocation=()")
+		if r.TLS != nil && hstsMaxAge > 0 {
+			header.Set("Strict-Transport-Security", "max-age="+strconv.FormatInt(int64(hstsMaxAge/time.Second), 10))
+		}
+
+		next.ServeHTTP(w, r.WithContext(view.WithCSPNonce(r.Context(), nonce)))
+	})
+}
+
+func contentSecurityPolicy(r *http.Request, nonce string) string {
+	connectSrc := "'self'"
+	if host := hostname(r.Host); cspHostPattern.MatchString(host) {
+		connectSrc += " http://" + host + " https://" + host
+	}
+	return strings.Join([]string{
+		"default-src 'none'",
+		"script-src 'nonce-" + nonce + "'",
+		"style-src 'unsafe-inline'",
+		"connect-src " + connectSrc,
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+		"base-uri 'none'",
+	}, "; ")
+}
+
+// Returns a random nonce, different for every response.
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Answers the plain HTTP requests with a permanent redirect to the same URL over HTTPS, on the port of the given address.
+// The method and body are kept, so that the redirected requests of scripts still work.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := hostname(r.Host)
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// Returns the host of a Host header, without its port.
+func hostname(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return strings.Trim(host, "[]")
+	}
+	return strings.Trim(hostport, "[]")
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"html"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"apigateway-webserver/src/pkg/view"
+)
+
+var scriptTagPattern = regexp.MustCompile(`<script[^>]*>`)
+
+// Every response gets its own nonce, allowed by its Content-Security-Policy and given to its templates.
+func TestSecureHeadersNonceIsUniquePerRequest(t *testing.T) {
+	var nonce string
+	handler := withSecureHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = view.CSPNonce(r.Context())
+	}), 0)
+
+	seen := map[string]bool{}
+	for range 100 {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if nonce == "" || seen[nonce] {
+			t.Fatalf("nonce %q empty or given to another response", nonce)
+		}
+		seen[nonce] = true
+		if csp := recorder.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'nonce-"+nonce+"'") {
+			t.Fatalf("policy %q doesn't allow the nonce %s", csp, nonce)
+		}
+	}
+}
+
+// The inline scripts of every page template are rendered with the nonce of the response, none is left without it.
+func TestTemplatesUseTheNonce(t *testing.T) {
+	templates, err := fs.Glob(view.TemplateFS, "templates/*.html")
+	if err != nil || len(templates) == 0 {
+		t.Fatalf("no templates: %v", err)
+	}
+
+	handler := withSecureHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, templatePath := range templates {
+			content, err := fs.ReadFile(view.TemplateFS, templatePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scripts := scriptTagPattern.FindAllString(string(content), -1)
+			for _, script := range scripts {
+				if script != `<script nonce="{{ cspNonce }}">` {
+					t.Errorf("%s: script %s without the nonce of the response", templatePath, script)
+				}
+			}
+
+			// Only the script tags are rendered, the rest of the page needs the data of its handler
+			tmpl, err := view.ParseTemplate(templatePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tmpl, err = tmpl.New("scripts").Parse(strings.Join(scripts, "</script>") + "</script>")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rendered strings.Builder
+			if err := tmpl.Funcs(view.Funcs(r.Context())).Execute(&rendered, nil); err != nil {
+				t.Fatal(err)
+			}
+			// Attribute values are HTML escaped, the browser reads the nonce unescaped
+			expected := strings.Repeat(`<script nonce="`+view.CSPNonce(r.Context())+`"></script>`, len(scripts))
+			if html.UnescapeString(rendered.String()) != expected {
+				t.Errorf("%s: rendered %s, expected %s", templatePath, rendered.String(), expected)
+			}
+		}
+	}), 0)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
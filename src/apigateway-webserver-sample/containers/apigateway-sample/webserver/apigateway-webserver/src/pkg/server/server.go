@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	handlers_context "apigateway-webserver/src/pkg/handlers/context"
+	"apigateway-webserver/src/pkg/logging"
+)
+
+var logger = logging.For("server")
+
+// Creates the HTTP server of the configuration, serving the given handler.
+func New(config *Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              config.Addr,
+		Handler:           handler,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		ReadTimeout:       time.Duration(config.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(config.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(config.WriteTimeout),
+		IdleTimeout:       time.Duration(config.IdleTimeout),
+	}
+}
+
+// Serves until SIGTERM or SIGINT is received, then shuts down gracefully: the user sessions are closed, so the requests
+// waiting for events return, and the requests in progress get the shutdown timeout to finish.
+// With a certificate, serves HTTPS with the certificate loaded again when its files change, and redirects the plain HTTP
+// requests of the redirect address to it.
+func Run(config *Config, srv *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	var redirectSrv *http.Server
+	if config.TLS() {
+		certificates, err := newCertificateReloader(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+		go certificates.watch(ctx)
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certificates.GetCertificate,
+		}
+
+		if config.HTTPRedirectAddr != "" {
+			redirectSrv = New(config, redirectToHTTPS(config.Addr))
+			redirectSrv.Addr = config.HTTPRedirectAddr
+		}
+	}
+
+	// Both servers stop serving when one of them fails
+	serveErr := make(chan error, 2)
+	servers := 1
+	go func() {
+		logger.Info("Listening", "addr", config.Addr, "basePath", config.BasePath, "https", config.TLS())
+		if config.TLS() {
+			// The certificate comes from the TLS configuration
+			serveErr <- srv.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+	if redirectSrv != nil {
+		servers++
+		go func() {
+			logger.Info("Redirecting HTTP to HTTPS", "addr", redirectSrv.Addr)
+			serveErr <- redirectSrv.ListenAndServe()
+		}()
+	}
+
+	var failure error
+	select {
+	case failure = <-serveErr:
+		servers--
+	case <-ctx.Done():
+	}
+	logger.Info("Shutting down")
+
+	// Stop the background work of every session and close their events sessions before draining the requests
+	handlers_context.GetAppContextsInstance().CloseAll()
+
+	shutdownCtx, cancel := context.WithCancel(context.Background())
+	if config.ShutdownTimeout > 0 {
+		shutdownCtx, cancel = context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeout))
+	}
+	defer cancel()
+	if redirectSrv != nil {
+		if err := redirectSrv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	for range servers {
+		if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	if failure != nil {
+		return failure
+	}
+	logger.Info("Shut down")
+	return nil
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/repositories"
+)
+
+// Defines the interface for handling alarms.
+type AlarmsService interface {
+	// Queries the alarms matching the given filter. A nil filter returns all alarms.
+	RequestAlarms(ctx context.Context, s *vms.Server, t vms.Token, filter *vms.AlarmFilter) (*vms.Alarms, error)
+	// Queries a single alarm by id.
+	RequestAlarm(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.Alarm, error)
+	// Acknowledges an alarm: the alarm is set in progress and assigned to the given owner.
+	AcknowledgeAlarm(ctx context.Context, s *vms.Server, t vms.Token, id string, owner string) (*vms.Alarm, error)
+	// Changes the state of an alarm, with an optional comment.
+	ChangeAlarmState(ctx context.Context, s *vms.Server, t vms.Token, id string, state enums.AlarmState, comment string) (*vms.Alarm, error)
+	// Assigns an alarm to the given owner.
+	AssignAlarm(ctx context.Context, s *vms.Server, t vms.Token, id string, owner string) (*vms.Alarm, error)
+}
+
+type alarmsService struct {
+	ar repositories.AlarmsRepository
+}
+
+// Creates a new instance of AlarmsService.
+func NewAlarmsService() AlarmsService {
+	return &alarmsService{
+		ar: repositories.NewAlarmsRepository(),
+	}
+}
+
+func (as *alarmsService) RequestAlarms(ctx context.Context, s *vms.Server, t vms.Token, filter *vms.AlarmFilter) (*vms.Alarms, error) {
+	alarms := vms.NewAlarms()
+	for alarm, err := range as.ar.ListAlarms(ctx, *s, t, filter) {
+		if err != nil {
+			return nil, err
+		}
+		alarms.Add(alarm)
+	}
+	return alarms, nil
+}
+
+func (as *alarmsService) RequestAlarm(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.Alarm, error) {
+	return as.ar.RequestAlarm(ctx, *s, t, id)
+}
+
+func (as *alarmsService) AcknowledgeAlarm(ctx context.Context, s *vms.Server, t vms.Token, id string, owner string) (*vms.Alarm, error) {
+	state := enums.AlarmInProgress
+	return as.ar.UpdateAlarm(ctx, *s, t, id, &vms.AlarmUpdate{
+		State:      &state,
+		AssignedTo: &owner,
+		Comment:    "Acknowledged by " + owner,
+	})
+}
+
+func (as *alarmsService) ChangeAlarmState(ctx context.Context, s *vms.Server, t vms.Token, id string, state enums.AlarmState, comment string) (*vms.Alarm, error) {
+	return as.ar.UpdateAlarm(ctx, *s, t, id, &vms.AlarmUpdate{
+		State:   &state,
+		Comment: comment,
+	})
+}
+
+func (as *alarmsService) AssignAlarm(ctx context.Context, s *vms.Server, t vms.Token, id string, owner string) (*vms.Alarm, error) {
+	return as.ar.UpdateAlarm(ctx, *s, t, id, &vms.AlarmUpdate{
+		AssignedTo: &owner,
+	})
+}
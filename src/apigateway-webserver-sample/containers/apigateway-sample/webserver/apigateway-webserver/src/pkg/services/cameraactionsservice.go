@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// Defines the interface for invoking the tasks of cameras, their PTZ presets and outputs through the Configuration API.
+type CameraActionsService interface {
+	// Queries all outputs.
+	RequestOutputs(ctx context.Context, s *vms.Server, t vms.Token) (*vms.Outputs, error)
+	// Queries the tasks available on an output.
+	RequestOutputTasks(ctx context.Context, s *vms.Server, t vms.Token, outputID string) (*vms.ConfigTasks, error)
+	// Queries a camera together with its tasks, its PTZ presets and the outputs of its hardware with their tasks.
+	RequestCameraActions(ctx context.Context, s *vms.Server, t vms.Token, cameraID string) (*vms.CameraActions, error)
+	// Invokes a task on an output and waits until it is done.
+	PerformOutputTask(ctx context.Context, s *vms.Server, t vms.Token, outputID, task string) (*vms.ConfigTaskResult, error)
+	// Invokes a task on a camera and waits until it is done.
+	PerformCameraTask(ctx context.Context, s *vms.Server, t vms.Token, cameraID, task string, payload any) (*vms.ConfigTaskResult, error)
+	// Moves a PTZ camera to one of its presets and waits until the task is done.
+	ActivatePtzPreset(ctx context.Context, s *vms.Server, t vms.Token, presetID string) (*vms.ConfigTaskResult, error)
+}
+
+type cameraActionsService struct {
+	cameras         ConfigService[vms.Camera]
+	outputs         ConfigService[vms.Output]
+	ptzPresets      ConfigService[vms.PtzPreset]
+	hardwareOutputs ConfigChildService[vms.Output]
+	cameraPresets   ConfigChildService[vms.PtzPreset]
+	tasks           ConfigTaskService
+}
+
+// Creates a new instance of CameraActionsService.
+func NewCameraActionsService() CameraActionsService {
+	return &cameraActionsService{
+		cameras:         NewConfigService[vms.Camera](constants.CamerasResource),
+		outputs:         NewConfigService[vms.Output](constants.OutputsResource),
+		ptzPresets:      NewConfigService[vms.PtzPreset](constants.PtzPresetsResource),
+		hardwareOutputs: NewConfigChildService[vms.Output](constants.HardwareResource, constants.OutputsResource),
+		cameraPresets:   NewConfigChildService[vms.PtzPreset](constants.CamerasResource, constants.PtzPresetsResource),
+		tasks:           NewConfigTaskService(),
+	}
+}
+
+func (cas *cameraActionsService) RequestOutputs(ctx context.Context, s *vms.Server, t vms.Token) (*vms.Outputs, error) {
+	outputs := vms.NewOutputs()
+	for output, err := range cas.outputs.List(ctx, s, t) {
+		if err != nil {
+			return nil, err
+		}
+		outputs.Add(output)
+	}
+	return outputs, nil
+}
+
+func (cas *cameraActionsService) RequestOutputTasks(ctx context.Context, s *vms.Server, t vms.Token, outputID string) (*vms.ConfigTasks, error) {
+	return cas.outputs.ListTasks(ctx, s, t, outputID)
+}
+
+func (cas *cameraActionsService) RequestCameraActions(ctx context.Context, s *vms.Server, t vms.Token, cameraID string) (*vms.CameraActions, error) {
+	camera, err := cas.cameras.Get(ctx, s, t, cameraID)
+	if err != nil {
+		return nil, err
+	}
+	if camera == nil {
+		return nil, fmt.Errorf("camera %s not found", cameraID)
+	}
+	actions := vms.NewCameraActions(camera)
+
+	tasks, err := cas.cameras.ListTasks(ctx, s, t, cameraID)
+	if err != nil {
+		return nil, err
+	}
+	actions.Tasks = tasks.Tasks
+
+	// Cameras without PTZ support may not expose presets at all, they simply have no preset actions
+	for preset, err := range cas.cameraPresets.List(ctx, s, t, cameraID) {
+		if err != nil {
+			logger.WarnContext(ctx, "Listing the PTZ presets of a camera", "cameraId", cameraID, "error", err)
+			break
+		}
+		actions.PtzPresets = append(actions.PtzPresets, preset)
+	}
+
+	hardwareID := camera.HardwareID()
+	if hardwareID == "" {
+		return actions, nil
+	}
+
+	// Collect the outputs before querying their tasks, so the list request is not kept open meanwhile
+	outputs := vms.NewOutputs()
+	for output, err := range cas.hardwareOutputs.List(ctx, s, t, hardwareID) {
+		if err != nil {
+			return nil, err
+		}
+		outputs.Add(output)
+	}
+	for _, output := range outputs.Outputs {
+		outputTasks, err := cas.RequestOutputTasks(ctx, s, t, output.ID)
+		if err != nil {
+			return nil, err
+		}
+		actions.Outputs = append(actions.Outputs, &vms.OutputActions{Output: output, Tasks: outputTasks.Tasks})
+	}
+
+	return actions, nil
+}
+
+func (cas *cameraActionsService) PerformOutputTask(ctx context.Context, s *vms.Server, t vms.Token, outputID, task string) (*vms.ConfigTaskResult, error) {
+	result, err := cas.outputs.PerformTask(ctx, s, t, outputID, task, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cas.waitForTask(ctx, s, t, result)
+}
+
+func (cas *cameraActionsService) PerformCameraTask(ctx context.Context, s *vms.Server, t vms.Token, cameraID, task string, payload any) (*vms.ConfigTaskResult, error) {
+	result, err := cas.cameras.PerformTask(ctx, s, t, cameraID, task, payload)
+	if err != nil {
+		return nil, err
+	}
+	return cas.waitForTask(ctx, s, t, result)
+}
+
+func (cas *cameraActionsService) ActivatePtzPreset(ctx context.Context, s *vms.Server, t vms.Token, presetID string) (*vms.ConfigTaskResult, error) {
+	result, err := cas.ptzPresets.PerformTask(ctx, s, t, presetID, constants.PtzPresetActivateTask, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cas.waitForTask(ctx, s, t, result)
+}
+
+// Tasks completing right away may answer without any result, those are reported as successful.
+func (cas *cameraActionsService) waitForTask(ctx context.Context, s *vms.Server, t vms.Token, result *vms.ConfigTaskResult) (*vms.ConfigTaskResult, error) {
+	if result == nil {
+		return &vms.ConfigTaskResult{State: vms.TaskStateSuccess}, nil
+	}
+	return cas.tasks.WaitForTask(ctx, s, t, result)
+}
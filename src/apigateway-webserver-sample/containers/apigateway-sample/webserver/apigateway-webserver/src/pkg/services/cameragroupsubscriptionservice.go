@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"apigateway-webserver/src/pkg/entities/events"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// Interval between two reads of the members of a subscribed camera group.
+const cameraGroupSyncInterval = 30 * time.Second
+
+// Defines the interface for subscribing to the events of all cameras of a camera group.
+type CameraGroupSubscriptionService interface {
+	// Subscribes to the events of the given type from all cameras of the group, including the cameras of nested groups.
+	// The subscription then follows the group membership until Stop is called. Returns the current cameras of the group.
+	Subscribe(ctx context.Context, s *vms.Server, t vms.Token, groupID string, eventTypeID string) (*vms.CamerasList, error)
+	// Stops following the group membership. The subscription itself lasts until the events session is closed.
+	Stop()
+}
+
+type cameraGroupSubscriptionService struct {
+	gs  GatewayService
+	wes WsEventsService
+
+	mu   sync.Mutex
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// Creates a new instance of CameraGroupSubscriptionService subscribing through the given events session.
+func NewCameraGroupSubscriptionService(gs GatewayService, wes WsEventsService) CameraGroupSubscriptionService {
+	return &cameraGroupSubscriptionService{
+		gs:  gs,
+		wes: wes,
+	}
+}
+
+// Adds a subscription on the given cameras and returns its id. No subscription is made for an empty list.
+func (cgss *cameraGroupSubscriptionService) subscribe(ctx context.Context, cameraIDs []string, eventTypeID string) (string, error) {
+	if len(cameraIDs) == 0 {
+		return "", nil
+	}
+
+	filters := &events.SubscriptionFilters{
+		Filters: []events.SubscriptionFilter{events.NewCamerasFilter(cameraIDs, eventTypeID)},
+	}
+	response, err := cgss.wes.RequestSubscribeFilters(ctx, filters)
+	if err != nil {
+		return "", err
+	}
+	return response.SubscriptionID, nil
+}
+
+func (cgss *cameraGroupSubscriptionService) Subscribe(ctx context.Context, s *vms.Server, t vms.Token, groupID string, eventTypeID string) (*vms.CamerasList, error) {
+	cgss.Stop()
+
+	cameras, err := cgss.gs.RequestCameraGroupCameras(ctx, s, t, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	cameraIDs := cameras.IDs()
+	slices.Sort(cameraIDs)
+	subscriptionID, err := cgss.subscribe(ctx, cameraIDs, eventTypeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Follow the group until stopped, independently of the request making the subscription
+	syncCtx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	cgss.mu.Lock()
+	previousStop, previousDone := cgss.stop, cgss.done
+	cgss.stop, cgss.done = stop, done
+	cgss.mu.Unlock()
+
+	// Subscribed concurrently since stopped, only the last group is followed
+	if previousStop != nil {
+		previousStop()
+		<-previousDone
+	}
+
+	go func() {
+		defer close(done)
+		cgss.sync(syncCtx, s, t, groupID, eventTypeID, cameraIDs, subscriptionID)
+	}()
+
+	return cameras, nil
+}
+
+// Reads the group members periodically and replaces the subscription when they changed.
+// The new subscription is added before removing the old one, so no event is missed in between.
+func (cgss *cameraGroupSubscriptionService) sync(ctx context.Context, s *vms.Server, t vms.Token, groupID, eventTypeID string, cameraIDs []string, subscriptionID string) {
+	ticker := time.NewTicker(cameraGroupSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cameras, err := cgss.gs.RequestCameraGroupCameras(ctx, s, t, groupID)
+		if err != nil {
+			logger.WarnContext(ctx, "Reading the cameras of a group", "groupId", groupID, "error", err)
+			continue
+		}
+
+		currentIDs := cameras.IDs()
+		slices.Sort(currentIDs)
+		if slices.Equal(currentIDs, cameraIDs) {
+			continue
+		}
+
+		newSubscriptionID, err := cgss.subscribe(ctx, currentIDs, eventTypeID)
+		if err != nil {
+			logger.WarnContext(ctx, "Subscribing to the cameras of a group", "groupId", groupID, "error", err)
+			continue
+		}
+		if subscriptionID != "" {
+			if _, err := cgss.wes.RequestUnsubscribe(ctx, subscriptionID); err != nil {
+				logger.WarnContext(ctx, "Removing the previous subscription of a group", "groupId", groupID, "error", err)
+			}
+		}
+
+		logger.InfoContext(ctx, "Camera group changed, subscription updated", "groupId", groupID, "previousCameras", len(cameraIDs), "cameras", len(currentIDs))
+		cameraIDs, subscriptionID = currentIDs, newSubscriptionID
+	}
+}
+
+func (cgss *cameraGroupSubscriptionService) Stop() {
+	cgss.mu.Lock()
+	stop, done := cgss.stop, cgss.done
+	cgss.stop, cgss.done = nil, nil
+	cgss.mu.Unlock()
+
+	if stop != nil {
+		stop()
+		<-done
+	}
+}
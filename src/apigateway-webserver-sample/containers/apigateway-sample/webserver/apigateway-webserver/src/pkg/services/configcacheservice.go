@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/repositories"
+)
+
+// Interval between two background refreshes of the configuration cache.
+const configCacheRefreshInterval = time.Minute
+
+// Defines the interface for reading the cameras and analytic event types of a server from a cache persisted on disk.
+// Every refresh compares the items with the cached ones and records the added, removed and modified items. The cache
+// is kept apart for every identity, so that users only get the items the VMS lets them read.
+type ConfigCacheService interface {
+	// Returns the cached cameras. The configuration is read from the server first when it was never cached.
+	RequestCameras(ctx context.Context, s *vms.Server, t vms.Token) (*vms.CamerasList, error)
+	// Returns the cached analytic event types. The configuration is read from the server first when it was never cached.
+	RequestAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token) (*vms.AnalyticEventTypes, error)
+	// Reads the configuration from the server, caches it and returns the changes from the previously cached configuration.
+	Refresh(ctx context.Context, s *vms.Server, t vms.Token) (*vms.ConfigChangeSet, error)
+	// Returns the recorded changes with a sequence number greater than since, oldest first.
+	RequestChangeSets(s *vms.Server, since uint64) (*vms.ConfigChangeSets, error)
+	// Refreshes the cache in the background until Stop is called.
+	Start(s *vms.Server, t vms.Token)
+	// Stops the background refresh.
+	Stop()
+}
+
+type configCacheService struct {
+	gs GatewayService
+	cr repositories.ConfigCacheRepository
+	// Identity the configuration is read with, see vms.User.Identity
+	identity string
+
+	mu   sync.Mutex
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// Locks of the refreshes by server and identity, shared by the sessions of the same identity so every change set is
+// computed from the snapshot stored by the previous refresh. A lock is dropped once no refresh uses it.
+var (
+	refreshLocksMu sync.Mutex
+	refreshLocks   = map[string]*refreshLock{}
+)
+
+type refreshLock struct {
+	sync.Mutex
+	users int
+}
+
+// Locks the refreshes of the server and identity, and returns the function unlocking them.
+func lockRefresh(server, identity string) func() {
+	key := server + "\x00" + identity
+	refreshLocksMu.Lock()
+	lock, exists := refreshLocks[key]
+	if !exists {
+		lock = &refreshLock{}
+		refreshLocks[key] = lock
+	}
+	lock.users++
+	refreshLocksMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		refreshLocksMu.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(refreshLocks, key)
+		}
+		refreshLocksMu.Unlock()
+	}
+}
+
+// Creates a new instance of ConfigCacheService reading the configuration of the user through the given gateway service.
+func NewConfigCacheService(gs GatewayService, u *vms.User) ConfigCacheService {
+	return &configCacheService{
+		gs:       gs,
+		cr:       repositories.GetConfigCacheRepository(),
+		identity: u.Identity(),
+	}
+}
+
+// Returns the cached snapshot of the server, reading it from the server when there is none.
+func (ccs *configCacheService) snapshot(ctx context.Context, s *vms.Server, t vms.Token) (*vms.ConfigSnapshot, error) {
+	snapshot, err := ccs.cr.LoadSnapshot(s.Hostname(), ccs.identity)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		return snapshot, nil
+	}
+
+	snapshot, _, err = ccs.refresh(ctx, s, t)
+	return snapshot, err
+}
+
+func (ccs *configCacheService) refresh(ctx context.Context, s *vms.Server, t vms.Token) (*vms.ConfigSnapshot, *vms.ConfigChangeSet, error) {
+	unlock := lockRefresh(s.Hostname(), ccs.identity)
+	defer unlock()
+
+	cameras, err := ccs.gs.RequestEnabledCameras(ctx, s, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	eventTypes, err := ccs.gs.RequestAnalyticEventTypes(ctx, s, t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	previous, err := ccs.cr.LoadSnapshot(s.Hostname(), ccs.identity)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot := vms.NewConfigSnapshot(s.Hostname(), ccs.identity, cameras, eventTypes)
+	changes := snapshot.Diff(previous)
+	if err := ccs.cr.SaveSnapshot(snapshot, changes); err != nil {
+		return nil, nil, err
+	}
+	return snapshot, changes, nil
+}
+
+func (ccs *configCacheService) RequestCameras(ctx context.Context, s *vms.Server, t vms.Token) (*vms.CamerasList, error) {
+	snapshot, err := ccs.snapshot(ctx, s, t)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.CamerasList(), nil
+}
+
+func (ccs *configCacheService) RequestAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token) (*vms.AnalyticEventTypes, error) {
+	snapshot, err := ccs.snapshot(ctx, s, t)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.AnalyticEventTypes(), nil
+}
+
+func (ccs *configCacheService) Refresh(ctx context.Context, s *vms.Server, t vms.Token) (*vms.ConfigChangeSet, error) {
+	_, changes, err := ccs.refresh(ctx, s, t)
+	return changes, err
+}
+
+func (ccs *configCacheService) RequestChangeSets(s *vms.Server, since uint64) (*vms.ConfigChangeSets, error) {
+	return ccs.cr.ListChangeSets(s.Hostname(), ccs.identity, since)
+}
+
+func (ccs *configCacheService) Start(s *vms.Server, t vms.Token) {
+	ccs.Stop()
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	ccs.mu.Lock()
+	previousStop, previousDone := ccs.stop, ccs.done
+	ccs.stop, ccs.done = stop, done
+	ccs.mu.Unlock()
+
+	// Started concurrently since stopped, only the last refresher is kept
+	if previousStop != nil {
+		previousStop()
+		<-previousDone
+	}
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(configCacheRefreshInterval)
+		defer ticker.Stop()
+		// Refresh right away, so the pages get the changes made while the webserver was stopped
+		for {
+			changes, err := ccs.Refresh(ctx, s, t)
+			if err != nil {
+				logger.WarnContext(ctx, "Refreshing the configuration cache", "site", s.Hostname(), "error", err)
+			} else if !changes.Empty() {
+				logger.InfoContext(ctx, "Configuration changed", "site", s.Hostname(), "changes", len(changes.Changes), "changeSet", changes.Sequence)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (ccs *configCacheService) Stop() {
+	ccs.mu.Lock()
+	stop, done := ccs.stop, ccs.done
+	ccs.stop, ccs.done = nil, nil
+	ccs.mu.Unlock()
+
+	if stop != nil {
+		stop()
+		<-done
+	}
+}
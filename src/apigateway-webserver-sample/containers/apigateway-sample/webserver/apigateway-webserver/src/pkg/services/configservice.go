@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"iter"
+	"time"
+
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/repositories"
+)
+
+// Interval between two reads of an asynchronous task state.
+const taskPollInterval = time.Second
+
+// Defines the interface for managing the items of any Configuration API resource type (e.g. hardware, recordingServers, roles).
+type ConfigService[T any] interface {
+	// Iterates over all items of the resource, requesting them page by page.
+	List(ctx context.Context, s *vms.Server, t vms.Token) iter.Seq2[*T, error]
+	// Queries a single item by id.
+	Get(ctx context.Context, s *vms.Server, t vms.Token, id string) (*T, error)
+	// Creates a new item.
+	Create(ctx context.Context, s *vms.Server, t vms.Token, item *T) (*T, error)
+	// Replaces all the fields of an item.
+	Update(ctx context.Context, s *vms.Server, t vms.Token, id string, item *T) (*T, error)
+	// Updates only the given fields of an item.
+	Patch(ctx context.Context, s *vms.Server, t vms.Token, id string, fields any) (*T, error)
+	// Deletes an item.
+	Delete(ctx context.Context, s *vms.Server, t vms.Token, id string) error
+	// Queries the tasks available on an item, or on the resource type when the id is empty.
+	ListTasks(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.ConfigTasks, error)
+	// Invokes a task on an item, or on the resource type when the id is empty.
+	// Tasks running asynchronously can be followed with ConfigTaskService.WaitForTask.
+	PerformTask(ctx context.Context, s *vms.Server, t vms.Token, id string, task string, payload any) (*vms.ConfigTaskResult, error)
+}
+
+// Defines the interface for managing the child items of a Configuration API resource type (e.g. the cameras of a hardware).
+type ConfigChildService[T any] interface {
+	// Iterates over all child items of the given parent item, requesting them page by page.
+	List(ctx context.Context, s *vms.Server, t vms.Token, parentID string) iter.Seq2[*T, error]
+	// Creates a new child item under the given parent item.
+	Create(ctx context.Context, s *vms.Server, t vms.Token, parentID string, item *T) (*T, error)
+	// Queries the tasks available on the child items of the given parent item.
+	ListTasks(ctx context.Context, s *vms.Server, t vms.Token, parentID string) (*vms.ConfigTasks, error)
+	// Invokes a task on the child items of the given parent item.
+	PerformTask(ctx context.Context, s *vms.Server, t vms.Token, parentID string, task string, payload any) (*vms.ConfigTaskResult, error)
+}
+
+// Defines the interface for following the tasks invoked through the Configuration API.
+type ConfigTaskService interface {
+	// Queries the state of a task by id.
+	RequestTask(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.ConfigTaskResult, error)
+	// Waits until the given task result is done and returns its final state.
+	// Results of tasks that completed right away are returned as they are.
+	WaitForTask(ctx context.Context, s *vms.Server, t vms.Token, result *vms.ConfigTaskResult) (*vms.ConfigTaskResult, error)
+}
+
+type configService[T any] struct {
+	cr repositories.ConfigRepository[T]
+}
+
+type configChildService[T any] struct {
+	ccr repositories.ConfigChildRepository[T]
+}
+
+type configTaskService struct {
+	ctr repositories.ConfigTaskRepository
+}
+
+// Creates a new instance of ConfigService for the given resource type (e.g. "hardware").
+func NewConfigService[T any](resource string) ConfigService[T] {
+	return &configService[T]{
+		cr: repositories.NewConfigRepository[T](resource),
+	}
+}
+
+// Creates a new instance of ConfigChildService for the given child item type of a resource type (e.g. "hardware", "cameras").
+func NewConfigChildService[T any](resource, childType string) ConfigChildService[T] {
+	return &configChildService[T]{
+		ccr: repositories.NewConfigChildRepository[T](resource, childType),
+	}
+}
+
+// Creates a new instance of ConfigTaskService.
+func NewConfigTaskService() ConfigTaskService {
+	return &configTaskService{
+		ctr: repositories.NewConfigTaskRepository(),
+	}
+}
+
+func (cs *configService[T]) List(ctx context.Context, s *vms.Server, t vms.Token) iter.Seq2[*T, error] {
+	return cs.cr.List(ctx, *s, t)
+}
+
+func (cs *configService[T]) Get(ctx context.Context, s *vms.Server, t vms.Token, id string) (*T, error) {
+	return cs.cr.Get(ctx, *s, t, id)
+}
+
+func (cs *configService[T]) Create(ctx context.Context, s *vms.Server, t vms.Token, item *T) (*T, error) {
+	return cs.cr.Create(ctx, *s, t, item)
+}
+
+func (cs *configService[T]) Update(ctx context.Context, s *vms.Server, t vms.Token, id string, item *T) (*T, error) {
+	return cs.cr.Update(ctx, *s, t, id, item)
+}
+
+func (cs *configService[T]) Patch(ctx context.Context, s *vms.Server, t vms.Token, id string, fields any) (*T, error) {
+	return cs.cr.Patch(ctx, *s, t, id, fields)
+}
+
+func (cs *configService[T]) Delete(ctx context.Context, s *vms.Server, t vms.Token, id string) error {
+	return cs.cr.Delete(ctx, *s, t, id)
+}
+
+func (cs *configService[T]) ListTasks(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.ConfigTasks, error) {
+	return cs.cr.ListTasks(ctx, *s, t, id)
+}
+
+func (cs *configService[T]) PerformTask(ctx context.Context, s *vms.Server, t vms.Token, id string, task string, payload any) (*vms.ConfigTaskResult, error) {
+	return cs.cr.PerformTask(ctx, *s, t, id, task, payload)
+}
+
+func (ccs *configChildService[T]) List(ctx context.Context, s *vms.Server, t vms.Token, parentID string) iter.Seq2[*T, error] {
+	return ccs.ccr.List(ctx, *s, t, parentID)
+}
+
+func (ccs *configChildService[T]) Create(ctx context.Context, s *vms.Server, t vms.Token, parentID string, item *T) (*T, error) {
+	return ccs.ccr.Create(ctx, *s, t, parentID, item)
+}
+
+func (ccs *configChildService[T]) ListTasks(ctx context.Context, s *vms.Server, t vms.Token, parentID string) (*vms.ConfigTasks, error) {
+	return ccs.ccr.ListTasks(ctx, *s, t, parentID)
+}
+
+func (ccs *configChildService[T]) PerformTask(ctx context.Context, s *vms.Server, t vms.Token, parentID string, task string, payload any) (*vms.ConfigTaskResult, error) {
+	return ccs.ccr.PerformTask(ctx, *s, t, parentID, task, payload)
+}
+
+func (cts *configTaskService) RequestTask(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.ConfigTaskResult, error) {
+	return cts.ctr.RequestTask(ctx, *s, t, id)
+}
+
+func (cts *configTaskService) WaitForTask(ctx context.Context, s *vms.Server, t vms.Token, result *vms.ConfigTaskResult) (*vms.ConfigTaskResult, error) {
+	if !result.IsAsync() {
+		return result, result.Err()
+	}
+
+	ticker := time.NewTicker(taskPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := cts.ctr.RequestTask(ctx, *s, t, result.Path.ID)
+		if err != nil {
+			return nil, err
+		}
+		if current.IsDone() {
+			return current, current.Err()
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"iter"
+
+	"apigateway-webserver/src/pkg/entities/events"
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/repositories"
+)
+
+// Defines the interface for interacting with the events REST API.
+type EventsRestService interface {
+	// Queries all user-defined events.
+	RequestUserDefinedEvents(ctx context.Context, s *vms.Server, t vms.Token) (*vms.UserDefinedEvents, error)
+	// Triggers an event of the given type. The source (e.g. cameras/{id}) and data are optional.
+	TriggerEvent(ctx context.Context, s *vms.Server, t vms.Token, request *events.EventTriggerRequest) (*events.Event, error)
+	// Iterates over the events history, requesting it page by page.
+	// The additional event data is only included when requested.
+	ListEvents(ctx context.Context, s *vms.Server, t vms.Token, includeData bool) iter.Seq2[*events.Event, error]
+	// Queries a single event by id.
+	// Only events of types with a retention time greater than zero can be read back.
+	RequestEvent(ctx context.Context, s *vms.Server, t vms.Token, id string) (*events.Event, error)
+}
+
+type eventsRestService struct {
+	evr repositories.EventsRestRepository
+}
+
+// Creates a new instance of EventsRestService.
+func NewEventsRestService() EventsRestService {
+	return &eventsRestService{
+		evr: repositories.NewEventsRestRepository(),
+	}
+}
+
+func (ers *eventsRestService) RequestUserDefinedEvents(ctx context.Context, s *vms.Server, t vms.Token) (*vms.UserDefinedEvents, error) {
+	userDefinedEvents := vms.NewUserDefinedEvents()
+	for userDefinedEvent, err := range ers.evr.ListUserDefinedEvents(ctx, *s, t) {
+		if err != nil {
+			return nil, err
+		}
+		userDefinedEvents.Add(userDefinedEvent)
+	}
+	return userDefinedEvents, nil
+}
+
+func (ers *eventsRestService) TriggerEvent(ctx context.Context, s *vms.Server, t vms.Token, request *events.EventTriggerRequest) (*events.Event, error) {
+	return ers.evr.TriggerEvent(ctx, *s, t, request)
+}
+
+func (ers *eventsRestService) ListEvents(ctx context.Context, s *vms.Server, t vms.Token, includeData bool) iter.Seq2[*events.Event, error] {
+	return ers.evr.ListEvents(ctx, *s, t, includeData)
+}
+
+func (ers *eventsRestService) RequestEvent(ctx context.Context, s *vms.Server, t vms.Token, id string) (*events.Event, error) {
+	return ers.evr.RequestEvent(ctx, *s, t, id)
+}
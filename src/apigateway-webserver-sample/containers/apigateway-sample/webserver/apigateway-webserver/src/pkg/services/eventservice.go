@@ -22,6 +22,12 @@ type WsEventsService interface {
 	// 2- subscribe to topic
 	RequestSubscribe(ctx context.Context, cameraId string, eventTypeId string) (*events.WsCommandResponse, error)
 
+	// 2- subscribe to topic using the given filters (e.g. all events of a resource type)
+	RequestSubscribeFilters(ctx context.Context, filters *events.SubscriptionFilters) (*events.WsCommandResponse, error)
+
+	// 2- remove a subscription by the id returned when it was added
+	RequestUnsubscribe(ctx context.Context, subscriptionId string) (*events.WsCommandResponse, error)
+
 	// 3- Subscribe to topic and loop
 	RequestEvents(ctx context.Context) (*events.AnalyticsEvents, error)
 
@@ -47,6 +53,14 @@ func (wes *wsEventsService) RequestSubscribe(ctx context.Context, cameraId strin
 	return wes.wer.RequestSubscribe(ctx, cameraId, eventTypeId)
 }
 
+func (wes *wsEventsService) RequestSubscribeFilters(ctx context.Context, filters *events.SubscriptionFilters) (*events.WsCommandResponse, error) {
+	return wes.wer.RequestSubscribeFilters(ctx, filters)
+}
+
+func (wes *wsEventsService) RequestUnsubscribe(ctx context.Context, subscriptionId string) (*events.WsCommandResponse, error) {
+	return wes.wer.RequestUnsubscribe(ctx, subscriptionId)
+}
+
 func (wes *wsEventsService) RequestEvents(ctx context.Context) (*events.AnalyticsEvents, error) {
 	return wes.wer.RequestEvents(ctx)
 }
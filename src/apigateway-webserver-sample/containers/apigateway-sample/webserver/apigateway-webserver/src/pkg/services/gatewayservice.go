@@ -2,7 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"time"
 
+	"apigateway-webserver/src/pkg/constants"
 	"apigateway-webserver/src/pkg/entities/vms"
 	"apigateway-webserver/src/pkg/repositories"
 )
@@ -16,8 +22,57 @@ type GatewayService interface {
 	RequestEnabledCameras(ctx context.Context, s *vms.Server, t vms.Token) (*vms.CamerasList, error)
 	// Queries all analytic event types.
 	RequestAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token) (*vms.AnalyticEventTypes, error)
+	// Iterates over all cameras, requesting them page by page.
+	// Stops early when the context is cancelled.
+	ListCameras(ctx context.Context, s *vms.Server, t vms.Token) iter.Seq2[*vms.Camera, error]
+	// Iterates over all analytic event types, requesting them page by page.
+	// Stops early when the context is cancelled.
+	ListAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token) iter.Seq2[*vms.AnalyticEventType, error]
+	// Queries the top level camera groups.
+	RequestCameraGroups(ctx context.Context, s *vms.Server, t vms.Token) (*vms.CameraGroups, error)
+	// Queries all cameras of a camera group, including the cameras of its nested groups.
+	// Every camera is returned once, even when it belongs to several of the nested groups.
+	RequestCameraGroupCameras(ctx context.Context, s *vms.Server, t vms.Token, groupID string) (*vms.CamerasList, error)
+	// Queries a single analytic event type by id.
+	RequestAnalyticEventType(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.AnalyticEventType, error)
+	// Creates an analytic event type.
+	CreateAnalyticEventType(ctx context.Context, s *vms.Server, t vms.Token, definition *vms.AnalyticEventTypeDefinition) (*vms.AnalyticEventType, error)
+	// Updates an analytic event type, unless it was modified after lastModified.
+	// Returns ErrAnalyticEventTypeModified when the event type has been changed by someone else in the meantime.
+	UpdateAnalyticEventType(ctx context.Context, s *vms.Server, t vms.Token, id string, lastModified string, definition *vms.AnalyticEventTypeDefinition) (*vms.AnalyticEventType, error)
+	// Deletes an analytic event type, unless it was modified after lastModified.
+	// Returns ErrAnalyticEventTypeModified when the event type has been changed by someone else in the meantime.
+	DeleteAnalyticEventType(ctx context.Context, s *vms.Server, t vms.Token, id string, lastModified string) error
+	// Exports all analytic event types as a catalog that can be imported on another site.
+	ExportAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token) (*vms.AnalyticEventCatalog, error)
+	// Imports a catalog of analytic event types. Event types are matched by name: missing ones are created, existing
+	// ones are left as they are and reported as unchanged or as conflicts when their definition differs.
+	ImportAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token, catalog *vms.AnalyticEventCatalog) (*vms.AnalyticEventTypeImport, error)
+	// Creates a bookmark.
+	CreateBookmark(ctx context.Context, s *vms.Server, t vms.Token, b *vms.Bookmark) (*vms.Bookmark, error)
+	// Creates a bookmark on a camera, starting pre before and ending post after the given time.
+	CreateBookmarkAround(ctx context.Context, s *vms.Server, t vms.Token, cameraID string, triggered time.Time, pre, post time.Duration, header, description string) (*vms.Bookmark, error)
+	// Iterates over all bookmarks, requesting them page by page.
+	ListBookmarks(ctx context.Context, s *vms.Server, t vms.Token) iter.Seq2[*vms.Bookmark, error]
+	// Queries a single bookmark by id.
+	RequestBookmark(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.Bookmark, error)
+	// Changes the header, description or time range of a bookmark.
+	UpdateBookmark(ctx context.Context, s *vms.Server, t vms.Token, id string, update *vms.BookmarkUpdate) (*vms.Bookmark, error)
+	// Deletes a bookmark.
+	DeleteBookmark(ctx context.Context, s *vms.Server, t vms.Token, id string) error
+	// Queries the bookmarks of a camera between from and to.
+	SearchBookmarks(ctx context.Context, s *vms.Server, t vms.Token, cameraID string, from, to time.Time) (*vms.Bookmarks, error)
+	// Detects the features supported by the server by probing the endpoint of every feature, with a WebSocket handshake
+	// for the events WebSocket. Features are only turned off when their endpoint doesn't exist.
+	DetectCapabilities(ctx context.Context, s *vms.Server, t vms.Token) *vms.Capabilities
+	// Sends an unauthenticated request to the first API gateway of the server and returns the HTTP status it answered.
+	// Any status, including 401, means the gateway is reachable.
+	ProbeGateway(ctx context.Context, s *vms.Server) (int, error)
 }
 
+// Returned when an analytic event type was modified by someone else since it was read.
+var ErrAnalyticEventTypeModified = errors.New("analytic event type was modified since it was read")
+
 type gatewayService struct {
 	gr repositories.GatewayRepository
 }
@@ -33,6 +88,10 @@ func (gs *gatewayService) RequestGatewayWellKnownUris(ctx context.Context, s *vm
 	return gs.gr.RequestGatewayWellKnownUris(ctx, *s)
 }
 
+func (gs *gatewayService) ProbeGateway(ctx context.Context, s *vms.Server) (int, error) {
+	return gs.gr.ProbeEndpoint(ctx, *s, nil, constants.EnabledCameras)
+}
+
 func (gs *gatewayService) RequestEnabledCameras(ctx context.Context, s *vms.Server, t vms.Token) (*vms.CamerasList, error) {
 	return gs.gr.RequestEnabledCameras(ctx, *s, t)
 }
@@ -40,3 +99,237 @@ func (gs *gatewayService) RequestEnabledCameras(ctx context.Context, s *vms.Serv
 func (gs *gatewayService) RequestAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token) (*vms.AnalyticEventTypes, error) {
 	return gs.gr.RequestAnalyticEventTypes(ctx, *s, t)
 }
+
+func (gs *gatewayService) ListCameras(ctx context.Context, s *vms.Server, t vms.Token) iter.Seq2[*vms.Camera, error] {
+	return gs.gr.ListCameras(ctx, *s, t)
+}
+
+func (gs *gatewayService) ListAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token) iter.Seq2[*vms.AnalyticEventType, error] {
+	return gs.gr.ListAnalyticEventTypes(ctx, *s, t)
+}
+
+func (gs *gatewayService) RequestCameraGroups(ctx context.Context, s *vms.Server, t vms.Token) (*vms.CameraGroups, error) {
+	groups := vms.NewCameraGroups()
+	for group, err := range gs.gr.ListCameraGroups(ctx, *s, t) {
+		if err != nil {
+			return nil, err
+		}
+		groups.Add(group)
+	}
+	return groups, nil
+}
+
+func (gs *gatewayService) RequestCameraGroupCameras(ctx context.Context, s *vms.Server, t vms.Token, groupID string) (*vms.CamerasList, error) {
+	cameras := vms.NewCamerasList()
+	if err := gs.addCameraGroupCameras(ctx, s, t, groupID, cameras, map[string]bool{}, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return cameras, nil
+}
+
+// Adds the cameras of a group and of its subgroups to the list.
+// The visited groups and added cameras are tracked to skip duplicates and to stop on groups containing each other.
+func (gs *gatewayService) addCameraGroupCameras(ctx context.Context, s *vms.Server, t vms.Token, groupID string, cameras *vms.CamerasList, visitedGroups, addedCameras map[string]bool) error {
+	if visitedGroups[groupID] {
+		return nil
+	}
+	visitedGroups[groupID] = true
+
+	for camera, err := range gs.gr.ListCameraGroupCameras(ctx, *s, t, groupID) {
+		if err != nil {
+			return err
+		}
+		if !addedCameras[camera.ID] {
+			addedCameras[camera.ID] = true
+			cameras.Add(camera)
+		}
+	}
+
+	// Read all subgroups before walking them, so only one page is streamed at a time
+	var subgroupIDs []string
+	for subgroup, err := range gs.gr.ListCameraGroupSubgroups(ctx, *s, t, groupID) {
+		if err != nil {
+			return err
+		}
+		subgroupIDs = append(subgroupIDs, subgroup.ID)
+	}
+	for _, subgroupID := range subgroupIDs {
+		if err := gs.addCameraGroupCameras(ctx, s, t, subgroupID, cameras, visitedGroups, addedCameras); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gs *gatewayService) RequestAnalyticEventType(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.AnalyticEventType, error) {
+	return gs.gr.RequestAnalyticEventType(ctx, *s, t, id)
+}
+
+func (gs *gatewayService) CreateAnalyticEventType(ctx context.Context, s *vms.Server, t vms.Token, definition *vms.AnalyticEventTypeDefinition) (*vms.AnalyticEventType, error) {
+	if err := definition.Validate(); err != nil {
+		return nil, err
+	}
+	return gs.gr.CreateAnalyticEventType(ctx, *s, t, definition)
+}
+
+// Reads the current version of an event type and checks it still has the given modification time.
+// The API has no conditional requests, so a change made between this check and the following request isn't detected.
+func (gs *gatewayService) checkAnalyticEventTypeUnchanged(ctx context.Context, s *vms.Server, t vms.Token, id string, lastModified string) error {
+	current, err := gs.gr.RequestAnalyticEventType(ctx, *s, t, id)
+	if err != nil {
+		return err
+	}
+	if current.LastModified != lastModified {
+		return fmt.Errorf("%w: last modified %s, expected %s", ErrAnalyticEventTypeModified, current.LastModified, lastModified)
+	}
+	return nil
+}
+
+func (gs *gatewayService) UpdateAnalyticEventType(ctx context.Context, s *vms.Server, t vms.Token, id string, lastModified string, definition *vms.AnalyticEventTypeDefinition) (*vms.AnalyticEventType, error) {
+	if err := definition.Validate(); err != nil {
+		return nil, err
+	}
+	if err := gs.checkAnalyticEventTypeUnchanged(ctx, s, t, id, lastModified); err != nil {
+		return nil, err
+	}
+	return gs.gr.UpdateAnalyticEventType(ctx, *s, t, id, definition)
+}
+
+func (gs *gatewayService) DeleteAnalyticEventType(ctx context.Context, s *vms.Server, t vms.Token, id string, lastModified string) error {
+	if err := gs.checkAnalyticEventTypeUnchanged(ctx, s, t, id, lastModified); err != nil {
+		return err
+	}
+	return gs.gr.DeleteAnalyticEventType(ctx, *s, t, id)
+}
+
+func (gs *gatewayService) ExportAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token) (*vms.AnalyticEventCatalog, error) {
+	aets, err := gs.gr.RequestAnalyticEventTypes(ctx, *s, t)
+	if err != nil {
+		return nil, err
+	}
+	return vms.NewAnalyticEventCatalog(aets), nil
+}
+
+func (gs *gatewayService) ImportAnalyticEventTypes(ctx context.Context, s *vms.Server, t vms.Token, catalog *vms.AnalyticEventCatalog) (*vms.AnalyticEventTypeImport, error) {
+	imported := vms.NewAnalyticEventTypeImport()
+	if err := catalog.Validate(); err != nil {
+		return imported, err
+	}
+
+	// Event type ids differ from one site to another, so match the existing event types by name. The API has no
+	// conditional requests, so existing event types aren't updated: an update could overwrite a change made meanwhile.
+	existing := map[string]*vms.AnalyticEventTypeDefinition{}
+	for aet, err := range gs.gr.ListAnalyticEventTypes(ctx, *s, t) {
+		if err != nil {
+			return imported, err
+		}
+		existing[aet.Name] = aet.Definition()
+	}
+
+	for _, definition := range catalog.EventTypes {
+		if current, exists := existing[definition.Name]; exists {
+			if current.Equal(definition) {
+				imported.Unchanged = append(imported.Unchanged, definition.Name)
+			} else {
+				imported.Conflicts = append(imported.Conflicts, definition.Name)
+			}
+			continue
+		}
+
+		aet, err := gs.gr.CreateAnalyticEventType(ctx, *s, t, definition)
+		if err != nil {
+			return imported, fmt.Errorf("importing event type %s: %w", definition.Name, err)
+		}
+		if aet != nil {
+			imported.Created = append(imported.Created, aet)
+		}
+		// A name repeated in the catalog is only created once
+		existing[definition.Name] = definition
+	}
+	return imported, nil
+}
+
+func (gs *gatewayService) CreateBookmark(ctx context.Context, s *vms.Server, t vms.Token, b *vms.Bookmark) (*vms.Bookmark, error) {
+	return gs.gr.CreateBookmark(ctx, *s, t, b)
+}
+
+func (gs *gatewayService) CreateBookmarkAround(ctx context.Context, s *vms.Server, t vms.Token, cameraID string, triggered time.Time, pre, post time.Duration, header, description string) (*vms.Bookmark, error) {
+	b := vms.NewBookmarkAround(cameraID, triggered, pre, post)
+	b.Header = header
+	b.Description = description
+	return gs.gr.CreateBookmark(ctx, *s, t, b)
+}
+
+func (gs *gatewayService) ListBookmarks(ctx context.Context, s *vms.Server, t vms.Token) iter.Seq2[*vms.Bookmark, error] {
+	return gs.gr.ListBookmarks(ctx, *s, t)
+}
+
+func (gs *gatewayService) RequestBookmark(ctx context.Context, s *vms.Server, t vms.Token, id string) (*vms.Bookmark, error) {
+	return gs.gr.RequestBookmark(ctx, *s, t, id)
+}
+
+func (gs *gatewayService) UpdateBookmark(ctx context.Context, s *vms.Server, t vms.Token, id string, update *vms.BookmarkUpdate) (*vms.Bookmark, error) {
+	return gs.gr.UpdateBookmark(ctx, *s, t, id, update)
+}
+
+func (gs *gatewayService) DeleteBookmark(ctx context.Context, s *vms.Server, t vms.Token, id string) error {
+	return gs.gr.DeleteBookmark(ctx, *s, t, id)
+}
+
+func (gs *gatewayService) SearchBookmarks(ctx context.Context, s *vms.Server, t vms.Token, cameraID string, from, to time.Time) (*vms.Bookmarks, error) {
+	return gs.gr.SearchBookmarks(ctx, *s, t, vms.NewBookmarkSearch(cameraID, from, to))
+}
+
+// Endpoint probed to find out whether a feature is available on the API gateway. The events WebSocket only answers
+// handshakes, it is probed with one.
+var featureProbePaths = map[vms.Feature]string{
+	vms.FeatureConfigApi:       constants.EnabledCameras,
+	vms.FeatureEventsWebsocket: constants.EventsWebsocket,
+	vms.FeatureEventsRest:      constants.Events,
+	vms.FeatureAlarms:          constants.Alarms,
+	vms.FeatureBookmarks:       constants.Bookmarks,
+}
+
+// Returns the product version reported by the API gateway, or by the IDP when the gateway reports none.
+func serverProductVersion(s *vms.Server) (vms.ProductVersion, error) {
+	version := ""
+	if s.ApiWellKnownUris != nil {
+		version = s.ApiWellKnownUris.ProductVersion
+	}
+	if version == "" && s.IdpOpenIdConfig != nil {
+		version = s.IdpOpenIdConfig.ServerVersion
+	}
+	return vms.ParseProductVersion(version)
+}
+
+func (gs *gatewayService) DetectCapabilities(ctx context.Context, s *vms.Server, t vms.Token) *vms.Capabilities {
+	capabilities := vms.NewCapabilities()
+
+	// The version is only shown, the features are found by probing their endpoints
+	if version, err := serverProductVersion(s); err == nil {
+		capabilities.SetVersion(version)
+	} else {
+		logger.WarnContext(ctx, "Unknown product version", "site", s.Hostname(), "error", err)
+	}
+
+	for _, feature := range vms.Features() {
+		var statusCode int
+		var err error
+		if feature == vms.FeatureEventsWebsocket {
+			statusCode, err = gs.gr.ProbeWebSocketEndpoint(ctx, *s, t, featureProbePaths[feature])
+		} else {
+			statusCode, err = gs.gr.ProbeEndpoint(ctx, *s, t, featureProbePaths[feature])
+		}
+		switch {
+		case err != nil:
+			// Don't turn off a feature only because the gateway couldn't be reached
+			logger.WarnContext(ctx, "Probing a feature", "feature", feature.Name(), "site", s.Hostname(), "error", err)
+			capabilities.Set(feature, true, "")
+		case statusCode == http.StatusNotFound:
+			capabilities.Set(feature, false, fmt.Sprintf("the API gateway has no %s endpoint", featureProbePaths[feature]))
+		default:
+			capabilities.Set(feature, true, "")
+		}
+	}
+	return capabilities
+}
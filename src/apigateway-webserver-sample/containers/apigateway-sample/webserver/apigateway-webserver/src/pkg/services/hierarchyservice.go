@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// Child item types of a hardware, in the order they are shown in the hierarchy.
+var hardwareDeviceTypes = []string{
+	constants.CamerasResource,
+	constants.MicrophonesResource,
+	constants.InputEventsResource,
+	constants.OutputsResource,
+}
+
+// Defines the interface for browsing the recording servers hierarchy through the Configuration API child item endpoints.
+type HierarchyService interface {
+	// Queries the recording servers, the hardware of every recording server and the devices of every hardware.
+	// The returned root node represents the site.
+	RequestHierarchy(ctx context.Context, s *vms.Server, t vms.Token) (*vms.HierarchyNode, error)
+	// Sets the recording server of every camera, read from the parent hardware of the camera.
+	LinkCameraParents(ctx context.Context, s *vms.Server, t vms.Token, cameras *vms.CamerasList) error
+}
+
+type hierarchyService struct {
+	recordingServers ConfigService[vms.ConfigItem]
+	hardware         ConfigService[vms.ConfigItem]
+	// Hardware of a recording server
+	recordingServerHardware ConfigChildService[vms.ConfigItem]
+	// Devices of a hardware, by device type
+	hardwareDevices map[string]ConfigChildService[vms.ConfigItem]
+}
+
+// Creates a new instance of HierarchyService.
+func NewHierarchyService() HierarchyService {
+	hs := &hierarchyService{
+		recordingServers:        NewConfigService[vms.ConfigItem](constants.RecordingServersResource),
+		hardware:                NewConfigService[vms.ConfigItem](constants.HardwareResource),
+		recordingServerHardware: NewConfigChildService[vms.ConfigItem](constants.RecordingServersResource, constants.HardwareResource),
+		hardwareDevices:         map[string]ConfigChildService[vms.ConfigItem]{},
+	}
+	for _, deviceType := range hardwareDeviceTypes {
+		hs.hardwareDevices[deviceType] = NewConfigChildService[vms.ConfigItem](constants.HardwareResource, deviceType)
+	}
+	return hs
+}
+
+func (hs *hierarchyService) RequestHierarchy(ctx context.Context, s *vms.Server, t vms.Token) (*vms.HierarchyNode, error) {
+	root := vms.NewHierarchyNode("site", vms.ConfigItem{"displayName": s.Hostname()}, nil)
+
+	for recordingServer, err := range hs.recordingServers.List(ctx, s, t) {
+		if err != nil {
+			return nil, err
+		}
+		recordingServerNode := vms.NewHierarchyNode(constants.RecordingServersResource, *recordingServer, root)
+		root.AddChild(recordingServerNode)
+
+		for hardware, err := range hs.recordingServerHardware.List(ctx, s, t, recordingServerNode.ID) {
+			if err != nil {
+				return nil, err
+			}
+			hardwareNode := vms.NewHierarchyNode(constants.HardwareResource, *hardware, recordingServerNode)
+			recordingServerNode.AddChild(hardwareNode)
+
+			for _, deviceType := range hardwareDeviceTypes {
+				for device, err := range hs.hardwareDevices[deviceType].List(ctx, s, t, hardwareNode.ID) {
+					if err != nil {
+						return nil, err
+					}
+					hardwareNode.AddChild(vms.NewHierarchyNode(deviceType, *device, hardwareNode))
+				}
+			}
+		}
+	}
+
+	return root, nil
+}
+
+func (hs *hierarchyService) LinkCameraParents(ctx context.Context, s *vms.Server, t vms.Token, cameras *vms.CamerasList) error {
+	// Many cameras share the same hardware, read every hardware only once
+	recordingServerIDs := map[string]string{}
+	for _, camera := range cameras.Cameras {
+		hardwareID := camera.HardwareID()
+		if hardwareID == "" {
+			continue
+		}
+
+		recordingServerID, known := recordingServerIDs[hardwareID]
+		if !known {
+			hardware, err := hs.hardware.Get(ctx, s, t, hardwareID)
+			if err != nil {
+				return err
+			}
+			if hardware != nil {
+				recordingServerID = hardware.ParentID()
+			}
+			recordingServerIDs[hardwareID] = recordingServerID
+		}
+		camera.RecordingServerID = recordingServerID
+	}
+	return nil
+}
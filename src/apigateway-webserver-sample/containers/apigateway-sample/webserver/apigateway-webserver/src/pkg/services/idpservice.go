@@ -3,8 +3,13 @@ package services
 import (
 	"context"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"apigateway-webserver/src/pkg/constants/enums"
 	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/metrics"
 	"apigateway-webserver/src/pkg/repositories"
+	"apigateway-webserver/src/pkg/tracing"
 )
 
 type IdpService interface {
@@ -12,16 +17,25 @@ type IdpService interface {
 	RequestIdpWellKnownConfig(ctx context.Context, s *vms.Server) (*vms.IdpOpenIdConfigSchema, error)
 
 	// Sends a POST request to get an access token for a basic user for management server scope (not supported for Windows users by design)..
+	// The request uses the token profile of the server, see RequestTokenProfile.
 	RequestAccessToken(ctx context.Context, u *vms.User, s *vms.Server) (vms.Token, error)
+
+	// Returns the token request parameters configured for the server and the credentials flow of the user.
+	RequestTokenProfile(u *vms.User, s *vms.Server) (*vms.TokenProfile, error)
+
+	// Tells that an app context doesn't use its token anymore. Shared client credentials tokens are forgotten once no app context uses them.
+	ReleaseAccessToken(token vms.Token)
 }
 
 type idpService struct {
 	ir repositories.IdpRepository
+	tc repositories.TokenCache
 }
 
 func NewIdpService() IdpService {
 	return &idpService{
 		ir: repositories.NewIdpRepository(),
+		tc: repositories.GetTokenCache(),
 	}
 }
 
@@ -29,12 +43,34 @@ func (is *idpService) RequestIdpWellKnownConfig(ctx context.Context, s *vms.Serv
 	return is.ir.RequestIdpWellKnownConfig(ctx, *s)
 }
 
-func (is *idpService) RequestAccessToken(ctx context.Context, u *vms.User, s *vms.Server) (vms.Token, error) {
+func (is *idpService) RequestAccessToken(ctx context.Context, u *vms.User, s *vms.Server) (token vms.Token, err error) {
+	ctx, span := tracing.Start(ctx, "Token request", attribute.String("credentials.flow", u.CredentialsFlowType().String()))
+	defer func() { tracing.End(span, err) }()
+
+	// Client credentials are one app identity shared by every login, all app contexts use the same token
+	if u.CredentialsFlowType() == enums.ClientCredentialsFlow {
+		return is.tc.RequestAccessToken(ctx, *u, *s)
+	}
+
 	// Create a token dispatcher (one per user and server combination).
 	// The reference to this instance will be stored in the token object.
 	// Every time the token value is requested, the token dispatcher will be called to check whether the token needs to be renewed or not.
 	tokenDispatcher := repositories.NewTokenDispatcher(is.ir, u, s)
 
 	// Sends a POST request to get the access token for the management server scope.
-	return is.ir.RequestAccessToken(ctx, *u, *s, tokenDispatcher)
+	token, err = is.ir.RequestAccessToken(ctx, *u, *s, tokenDispatcher)
+	metrics.ObserveTokenRequest(u.CredentialsFlowType().String(), metrics.TokenIssue, err)
+	return token, err
+}
+
+func (is *idpService) ReleaseAccessToken(token vms.Token) {
+	is.tc.ReleaseAccessToken(token)
+}
+
+func (is *idpService) RequestTokenProfile(u *vms.User, s *vms.Server) (*vms.TokenProfile, error) {
+	profiles, err := repositories.GetTokenProfiles()
+	if err != nil {
+		return nil, err
+	}
+	return profiles.Resolve(s.Hostname(), u.CredentialsFlowType())
 }
@@ -0,0 +1,6 @@
+package services
+
+import "apigateway-webserver/src/pkg/logging"
+
+// Logger of the package, see logging.For.
+var logger = logging.For("services")
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// Names of the readiness checks, in the order they run.
+const (
+	ReadinessCheckWellKnownUris = "wellKnownUris"
+	ReadinessCheckIdpDiscovery  = "idpDiscovery"
+	ReadinessCheckApiGateway    = "apiGateway"
+)
+
+// Checks that the management server the webserver depends on answers.
+type ReadinessService interface {
+	// Probes the well-known URIs of the management server, the IDP discovery document and the API gateway, each within
+	// its own timeout. The report is reused by the calls made within the cache duration, so that frequent probes don't
+	// load the server. Cancelling the context doesn't cancel the checks, their result is shared with the other callers.
+	Check(ctx context.Context) *vms.ReadinessReport
+}
+
+type readinessService struct {
+	serverURL *url.URL
+	gs        GatewayService
+	is        IdpService
+	timeout   time.Duration
+	cacheTTL  time.Duration
+
+	// Held while checking, concurrent calls wait for the running checks instead of starting their own
+	mu        sync.Mutex
+	report    *vms.ReadinessReport
+	checkedAt time.Time
+}
+
+// Creates a readiness service probing the management server at the given URL, e.g. http://vms.
+// A timeout of 0 means no timeout and a cache duration of 0 runs the checks on every call.
+func NewReadinessService(serverURL *url.URL, gs GatewayService, is IdpService, timeout, cacheTTL time.Duration) ReadinessService {
+	return &readinessService{
+		serverURL: serverURL,
+		gs:        gs,
+		is:        is,
+		timeout:   timeout,
+		cacheTTL:  cacheTTL,
+	}
+}
+
+func (rs *readinessService) Check(ctx context.Context) *vms.ReadinessReport {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.report != nil && time.Since(rs.checkedAt) < rs.cacheTTL {
+		return rs.report
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	report := vms.NewReadinessReport()
+
+	var wellKnownUris *vms.ApiWellKnownUrisSchema
+	report.Add(rs.run(ctx, ReadinessCheckWellKnownUris, func(ctx context.Context) error {
+		var err error
+		wellKnownUris, err = rs.gs.RequestGatewayWellKnownUris(ctx, rs.newServer())
+		return err
+	}))
+
+	report.Add(rs.run(ctx, ReadinessCheckIdpDiscovery, func(ctx context.Context) error {
+		config, err := rs.is.RequestIdpWellKnownConfig(ctx, rs.newServer())
+		if err != nil {
+			return err
+		}
+		if config.TokenEndPoint == "" {
+			return errors.New("no token endpoint in the OpenID configuration")
+		}
+		return nil
+	}))
+
+	// The gateway address is only known from the well-known URIs
+	if wellKnownUris == nil {
+		report.Add(vms.NewSkippedReadinessCheck(ReadinessCheckApiGateway, time.Now(), "well-known URIs not available"))
+	} else {
+		report.Add(rs.run(ctx, ReadinessCheckApiGateway, func(ctx context.Context) error {
+			s := rs.newServer()
+			s.ApiWellKnownUris = wellKnownUris
+			statusCode, err := rs.gs.ProbeGateway(ctx, s)
+			if err != nil {
+				return err
+			}
+			if statusCode >= 500 {
+				return fmt.Errorf("API gateway answered with status %d", statusCode)
+			}
+			return nil
+		}))
+	}
+
+	rs.report, rs.checkedAt = report, time.Now()
+	return report
+}
+
+// Runs a check within the timeout and measures how long it took.
+func (rs *readinessService) run(ctx context.Context, name string, check func(ctx context.Context) error) *vms.ReadinessCheck {
+	if rs.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rs.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check(ctx)
+	return vms.NewReadinessCheck(name, start, time.Since(start), err)
+}
+
+// Returns a server for one request. The repositories set the path of the server URL, each request gets its own copy.
+func (rs *readinessService) newServer() *vms.Server {
+	serverURL := *rs.serverURL
+	return vms.NewServer(&serverURL)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/vms"
+	"apigateway-webserver/src/pkg/repositories"
+)
+
+// Defines the interface for finding the role of a user in the webserver, see vms.RoleMappings.
+type RoleService interface {
+	// Returns the highest role mapped to the claims of the token or to the VMS roles the user is member of, or the
+	// default role when none is or when the mappings don't trust the site. The claims are only read from tokens signed
+	// by the IDP of the site.
+	RequestRole(ctx context.Context, u *vms.User, s *vms.Server, t vms.Token) (enums.Role, error)
+}
+
+type roleService struct {
+	ir        repositories.IdpRepository
+	roles     ConfigService[vms.ConfigItem]
+	roleUsers ConfigChildService[vms.ConfigItem]
+}
+
+// Creates a new instance of RoleService.
+func NewRoleService() RoleService {
+	return &roleService{
+		ir:        repositories.NewIdpRepository(),
+		roles:     NewConfigService[vms.ConfigItem](constants.RolesResource),
+		roleUsers: NewConfigChildService[vms.ConfigItem](constants.RolesResource, constants.RoleUsersResource),
+	}
+}
+
+func (rs *roleService) RequestRole(ctx context.Context, u *vms.User, s *vms.Server, t vms.Token) (enums.Role, error) {
+	mappings, err := repositories.GetRoleMappings()
+	if err != nil {
+		return 0, err
+	}
+
+	// Any server can issue tokens and answer the VMS roles, only the sites of the mappings tell who their users are
+	if !mappings.TrustsSite(s.Hostname()) {
+		logger.InfoContext(ctx, "Site not trusted by the role mappings, default role", "user", u, "site", s.Hostname(), "role", mappings.Default().String())
+		return mappings.Default(), nil
+	}
+
+	role, found := enums.Role(0), false
+	if mappings.UsesClaims() {
+		claims, err := rs.verifiedClaims(ctx, s, t)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return 0, err
+		case err != nil:
+			logger.WarnContext(ctx, "Checking the signature of the access token, its claims are ignored", "user", u, "site", s.Hostname(), "error", err)
+		default:
+			role, found = mappings.RoleOfClaims(claims)
+		}
+	}
+
+	if mappings.UsesVmsRoles() {
+		names, err := rs.vmsRolesOf(ctx, u, s, t)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return 0, err
+		case err != nil:
+			// Only users allowed to read the VMS roles can be mapped with them
+			logger.WarnContext(ctx, "Reading the VMS roles of the user", "user", u, "site", s.Hostname(), "error", err)
+		default:
+			if vmsRole, ok := mappings.RoleOfVmsRoles(names); ok && vmsRole > role {
+				role, found = vmsRole, true
+			}
+		}
+	}
+
+	if !found {
+		role = mappings.Default()
+	}
+	logger.InfoContext(ctx, "Role of the user", "user", u, "site", s.Hostname(), "role", role.String())
+	return role, nil
+}
+
+// Returns the claims of the token once its signature is checked against the signing keys of the IDP of the site, and
+// its issuer against the issuer of the IDP.
+func (rs *roleService) verifiedClaims(ctx context.Context, s *vms.Server, t vms.Token) (map[string]any, error) {
+	keys, err := rs.ir.RequestSigningKeys(ctx, *s)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := keys.Verify(t.GetSchema().AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	if issuer, _ := claims["iss"].(string); s.IdpOpenIdConfig.Issuer == "" || issuer != s.IdpOpenIdConfig.Issuer {
+		return nil, errors.New("access token issued by another IDP: " + issuer)
+	}
+	return claims, nil
+}
+
+// Returns the names of the VMS roles the user is member of.
+func (rs *roleService) vmsRolesOf(ctx context.Context, u *vms.User, s *vms.Server, t vms.Token) ([]string, error) {
+	var names []string
+	for role, err := range rs.roles.List(ctx, s, t) {
+		if err != nil {
+			return nil, err
+		}
+		for member, err := range rs.roleUsers.List(ctx, s, t, role.ID()) {
+			if err != nil {
+				return nil, err
+			}
+			if isRoleMember(*member, u.Username()) {
+				names = append(names, role.Name())
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+// Tells whether a member of a VMS role is the user, named by account, by display name or as DOMAIN\account.
+func isRoleMember(member vms.ConfigItem, username string) bool {
+	accountName, _ := member["accountName"].(string)
+	domain, _ := member["domain"].(string)
+	for _, name := range []string{accountName, member.Name(), domain + `\` + accountName} {
+		if name != "" && strings.EqualFold(name, username) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/constants/enums"
+	"apigateway-webserver/src/pkg/entities/vms"
+)
+
+// IDP publishing the public key of idpKey. The role mappings trust its site and map the ADM claim to admin.
+func newTrustedIdp(t *testing.T, idpKey *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA","use":"sig","kid":"idp-key","n":%q,"e":%q}]}`,
+			base64.RawURLEncoding.EncodeToString(idpKey.N.Bytes()),
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idpKey.E)).Bytes()))
+	}))
+	t.Cleanup(idp.Close)
+
+	mappingsPath := filepath.Join(t.TempDir(), "roles.json")
+	mappings := fmt.Sprintf(`{"sites":[%q],"claims":{"admin":["ADM"]}}`, idpHostname(idp))
+	if err := os.WriteFile(mappingsPath, []byte(mappings), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The mappings are read once, by the first test of the package asking for a role
+	t.Setenv(constants.RoleMappingsPathEnv, mappingsPath)
+	return idp
+}
+
+func idpHostname(idp *httptest.Server) string {
+	parsed, _ := url.Parse(idp.URL)
+	return parsed.Host
+}
+
+func signedToken(t *testing.T, key *rsa.PrivateKey, alg, kid string, claims map[string]any) vms.Token {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature := []byte{}
+	if key != nil {
+		digest := sha256.Sum256([]byte(signingInput))
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tokenData, _ := json.Marshal(map[string]any{
+		"access_token": signingInput + "." + base64.RawURLEncoding.EncodeToString(signature),
+		"expires_in":   3600,
+	})
+	token, err := vms.NewToken(tokenData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// Only tokens signed by the IDP of a trusted site get the role of their claims, forged tokens get the default role.
+func TestRequestRoleChecksTheTokenSignature(t *testing.T) {
+	idpKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgerKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := newTrustedIdp(t, idpKey)
+
+	trusted := vms.NewServer(&url.URL{Scheme: "http", Host: idpHostname(idp)})
+	trusted.IdpOpenIdConfig = &vms.IdpOpenIdConfigSchema{Issuer: idp.URL + "/idp", JwksUri: idp.URL + "/idp/.well-known/openid-configuration/jwks"}
+	// Same IDP keys, but a hostname the mappings don't list
+	untrusted := vms.NewServer(&url.URL{Scheme: "http", Host: "localhost:1"})
+	untrusted.IdpOpenIdConfig = trusted.IdpOpenIdConfig
+
+	admin := map[string]any{"iss": idp.URL + "/idp", "role": []string{"ADM"}}
+	otherIssuer := map[string]any{"iss": "http://evil/idp", "role": []string{"ADM"}}
+	tests := []struct {
+		name     string
+		server   *vms.Server
+		token    vms.Token
+		expected enums.Role
+	}{
+		{"signed by the IDP", trusted, signedToken(t, idpKey, "RS256", "idp-key", admin), enums.RoleAdmin},
+		{"signed by another key", trusted, signedToken(t, forgerKey, "RS256", "idp-key", admin), enums.RoleViewer},
+		{"signed by an unknown key", trusted, signedToken(t, forgerKey, "RS256", "forger-key", admin), enums.RoleViewer},
+		{"unsigned", trusted, signedToken(t, nil, "none", "", admin), enums.RoleViewer},
+		{"issued by another IDP", trusted, signedToken(t, idpKey, "RS256", "idp-key", otherIssuer), enums.RoleViewer},
+		{"of an untrusted site", untrusted, signedToken(t, idpKey, "RS256", "idp-key", admin), enums.RoleViewer},
+	}
+
+	rs := NewRoleService()
+	user := vms.NewUser("user", "secret", enums.LoginForm)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			role, err := rs.RequestRole(context.Background(), user, test.server, test.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if role != test.expected {
+				t.Fatalf("role %s, expected %s", role, test.expected)
+			}
+		})
+	}
+}
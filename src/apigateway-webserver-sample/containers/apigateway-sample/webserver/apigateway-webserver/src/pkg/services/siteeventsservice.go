@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"apigateway-webserver/src/pkg/entities/events"
+)
+
+// Number of event batches kept until read, for all sites together.
+const siteEventsBufferSize = 100
+
+// Defines the interface for merging the events sessions of several sites into one stream of events.
+type SiteEventsService interface {
+	// Reads the events of the session of a site until Unfollow is called or the session is closed.
+	// Replaces the previous session followed for the same site.
+	Follow(site string, wes WsEventsService)
+	// Stops reading the events of a site. The events already read are kept.
+	Unfollow(site string)
+	// Waits for the events of any followed site, every event tagged with its site.
+	RequestEvents(ctx context.Context) (*events.AnalyticsEvents, error)
+	// Stops reading the events of all sites, once the session ends. Waiting RequestEvents calls return an error.
+	Stop()
+}
+
+type siteFollower struct {
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+type siteEventsService struct {
+	events   chan *events.AnalyticsEvents
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	mu        sync.Mutex
+	followers map[string]*siteFollower
+}
+
+// Creates a new instance of SiteEventsService not following any site.
+func NewSiteEventsService() SiteEventsService {
+	return &siteEventsService{
+		events:    make(chan *events.AnalyticsEvents, siteEventsBufferSize),
+		stopped:   make(chan struct{}),
+		followers: make(map[string]*siteFollower),
+	}
+}
+
+func (ses *siteEventsService) Follow(site string, wes WsEventsService) {
+	ctx, stop := context.WithCancel(context.Background())
+	follower := &siteFollower{stop: stop, done: make(chan struct{})}
+	ses.mu.Lock()
+	previous, followed := ses.followers[site]
+	ses.followers[site] = follower
+	ses.mu.Unlock()
+
+	// Replaced in one step, so concurrent calls for the same site leave a single follower
+	if followed {
+		previous.stop()
+		<-previous.done
+	}
+
+	go func() {
+		defer close(follower.done)
+		ses.follow(ctx, site, wes)
+
+		// Forget the site once its session ended on its own
+		ses.mu.Lock()
+		if ses.followers[site] == follower {
+			delete(ses.followers, site)
+		}
+		ses.mu.Unlock()
+	}()
+}
+
+func (ses *siteEventsService) follow(ctx context.Context, site string, wes WsEventsService) {
+	for {
+		aes, err := wes.RequestEvents(ctx)
+		if err != nil {
+			// Unfollowed or the session was closed, which is the normal end of a session
+			if ctx.Err() == nil {
+				logger.WarnContext(ctx, "Events session ended", "site", site, "error", err)
+			}
+			return
+		}
+
+		for i := range aes.Events {
+			aes.Events[i].Site = site
+		}
+		select {
+		case ses.events <- aes:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (ses *siteEventsService) Unfollow(site string) {
+	ses.mu.Lock()
+	follower, ok := ses.followers[site]
+	delete(ses.followers, site)
+	ses.mu.Unlock()
+
+	if ok {
+		follower.stop()
+		<-follower.done
+	}
+}
+
+func (ses *siteEventsService) following() bool {
+	ses.mu.Lock()
+	defer ses.mu.Unlock()
+	return len(ses.followers) > 0
+}
+
+// Returned when events are requested before an events session was started on any site of the user.
+var ErrNoEventsSession = errors.New("no events session started")
+
+func (ses *siteEventsService) RequestEvents(ctx context.Context) (*events.AnalyticsEvents, error) {
+	if !ses.following() && len(ses.events) == 0 {
+		return nil, ErrNoEventsSession
+	}
+
+	aes := events.NewAnalyticsEvents()
+	select {
+	case first := <-ses.events:
+		aes.AddAnalyticsEvents(first)
+	case <-ses.stopped:
+		return nil, errors.New("events session stopped")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// Return the batches of the other sites already waiting together with the first one
+	for {
+		select {
+		case next := <-ses.events:
+			aes.AddAnalyticsEvents(next)
+		default:
+			return aes, nil
+		}
+	}
+}
+
+func (ses *siteEventsService) Stop() {
+	ses.stopOnce.Do(func() { close(ses.stopped) })
+
+	ses.mu.Lock()
+	sites := make([]string, 0, len(ses.followers))
+	for site := range ses.followers {
+		sites = append(sites, site)
+	}
+	ses.mu.Unlock()
+
+	for _, site := range sites {
+		ses.Unfollow(site)
+	}
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"apigateway-webserver/src/pkg/constants"
+	"apigateway-webserver/src/pkg/logging"
+)
+
+// Name of the webserver in the traces, unless OTEL_SERVICE_NAME is set.
+const serviceName = "apigateway-webserver"
+
+// Exporters selected with the OTEL_TRACES_EXPORTER variable.
+const (
+	ExporterOtlp    = "otlp"
+	ExporterConsole = "console"
+	ExporterNone    = "none"
+)
+
+var tracer = otel.Tracer(serviceName)
+
+// Starts exporting the spans and propagating the trace context in the W3C headers. The spans are sent with OTLP over HTTP
+// when a collector endpoint is configured with the OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// variables, and written to the standard output otherwise. OTEL_TRACES_EXPORTER selects the exporter explicitly.
+// Returns the function flushing the spans left when the webserver stops.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporterName := os.Getenv(constants.OtelTracesExporterEnv)
+	if exporterName == "" {
+		exporterName = ExporterConsole
+		if os.Getenv(constants.OtelExporterOtlpEndpointEnv) != "" || os.Getenv(constants.OtelExporterOtlpTracesEndpointEnv) != "" {
+			exporterName = ExporterOtlp
+		}
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exporterName {
+	case ExporterOtlp:
+		// The endpoint, headers and TLS settings are read from the OTEL_EXPORTER_OTLP_* variables
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterConsole:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("environment variable %s: unknown exporter %q, expected %s, %s or %s",
+			constants.OtelTracesExporterEnv, exporterName, ExporterOtlp, ExporterConsole, ExporterNone)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating the %s trace exporter: %w", exporterName, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES replace the defaults
+	res, err = resource.Merge(res, resource.Environment())
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Starts a span, child of the span of the context if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Starts a span of a request sent to another service.
+func StartClient(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...), trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// Ends the span, marking it as failed with the error, without its secrets, when err isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		message := logging.RedactString(err.Error())
+		span.SetStatus(codes.Error, message)
+		span.AddEvent("exception", trace.WithAttributes(semconv.ExceptionMessage(message)))
+	}
+	span.End()
+}
+
+// Adds the trace context of the context to the headers of a request sent to another service.
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Records the status of a response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusResponseWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusResponseWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Lets http.ResponseController reach the connection, e.g. to lift the write deadline.
+func (sw *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Runs the handler of the given route in a server span, continuing the trace of the caller when it sent a trace context.
+func InstrumentHandler(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request.id", logging.RequestID(ctx)),
+			))
+		defer span.End()
+
+		sw := &statusResponseWriter{ResponseWriter: w}
+		handler.ServeHTTP(sw, r.WithContext(ctx))
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.status))
+		if sw.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package view
+
+import (
+	"context"
+	"html/template"
+	"path"
+)
+
+type cspNonceKey struct{}
+
+// Returns a context carrying the nonce the Content-Security-Policy of the response allows the inline scripts with.
+func WithCSPNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, cspNonceKey{}, nonce)
+}
+
+// Returns the nonce of the inline scripts of the response, empty when the response has no Content-Security-Policy.
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// Returns the functions of the templates for a request:
+//   - cspNonce: the nonce of the inline scripts, written as <script nonce="{{ cspNonce }}">
+func Funcs(ctx context.Context) template.FuncMap {
+	nonce := CSPNonce(ctx)
+	return template.FuncMap{
+		"cspNonce": func() string { return nonce },
+	}
+}
+
+// Parses a page template. Its functions are the ones of no request, to be replaced with Funcs before executing it.
+func ParseTemplate(templatePath string) (*template.Template, error) {
+	return template.New(path.Base(templatePath)).Funcs(Funcs(context.Background())).ParseFS(TemplateFS, templatePath)
+}